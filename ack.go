@@ -0,0 +1,295 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	errNotDown       = errors.New("target is not down")
+	errAlreadyAcked  = errors.New("outage is already acknowledged")
+	errOutageEnded   = errors.New("the outage this link was sent for has ended")
+	errInvalidAckSig = errors.New("invalid acknowledgement link")
+)
+
+// ackForm is the page acknowledgement links lead to. Following the link
+// only shows it, so that link previews and mail scanners don't acknowledge
+// the outage; it is acknowledged when the form is posted.
+var ackForm = htmltemplate.Must(htmltemplate.New("ack").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Acknowledge {{.Name}}</title></head>
+<body style="font-family: sans-serif">
+<h2>Acknowledge the outage of {{.Name}}</h2>
+<p>{{.URL}} is down since {{.Since}}. Acknowledging it stops reminders and escalations until it recovers.</p>
+<form method="post" action="ack">
+<input type="hidden" name="url" value="{{.URL}}">
+<input type="hidden" name="since" value="{{.SinceUnix}}">
+<input type="hidden" name="sig" value="{{.Sig}}">
+<p><label>Your name <input name="by" value="{{.By}}" required></label></p>
+<p><label>Comment <input name="comment"></label></p>
+<p><button type="submit">Acknowledge</button></p>
+</form>
+</body>
+</html>
+`))
+
+// ackConfig enables signed acknowledgement links in DOWN
+// notifications.
+type ackConfig struct {
+	baseURL  string // Externally reachable URL of the HTTP server on port 2112
+	secret   string // HMAC key of the links
+	apiToken string // Bearer token of POST /ack, which is disabled without it
+}
+
+type fileAcks struct {
+	BaseURL  string `yaml:"base_url" json:"base_url"`
+	Secret   string `yaml:"secret" json:"secret"`
+	APIToken string `yaml:"api_token" json:"api_token"`
+}
+
+func (c *ackConfig) applyFile(f fileAcks) {
+	overlay(&c.baseURL, f.BaseURL)
+	overlay(&c.secret, f.Secret)
+	overlay(&c.apiToken, f.APIToken)
+}
+
+func validateAcks(c ackConfig, errs *configErrors) {
+	if c.baseURL == "" {
+		return
+	}
+	if u, err := neturl.Parse(c.baseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs.add("acknowledgements.base_url", "invalid URL %q", c.baseURL)
+	}
+	if c.secret == "" {
+		errs.add("acknowledgements.secret", "required when acknowledgements.base_url is set")
+	}
+}
+
+// link returns the signed acknowledgement link for the outage of url that
+// started at since, or "" if links are not configured.
+func (c ackConfig) link(url string, since time.Time) string {
+	if c.baseURL == "" || c.secret == "" || since.IsZero() {
+		return ""
+	}
+	q := neturl.Values{
+		"url":   {url},
+		"since": {strconv.FormatInt(since.Unix(), 10)},
+		"sig":   {c.sign(url, since.Unix())},
+	}
+	return strings.TrimSuffix(c.baseURL, "/") + "/ack?" + q.Encode()
+}
+
+// sign returns the hex HMAC-SHA256 of the outage identified by url and since.
+func (c ackConfig) sign(url string, since int64) string {
+	mac := hmac.New(sha256.New, []byte(c.secret))
+	fmt.Fprintf(mac, "%s\n%d", url, since)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c ackConfig) verify(url string, since int64, sig string) bool {
+	return c.secret != "" && hmac.Equal([]byte(c.sign(url, since)), []byte(sig))
+}
+
+// authorized reports whether r carries the API token as a bearer token.
+func (c ackConfig) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && c.apiToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(c.apiToken)) == 1
+}
+
+// requireAPIToken rejects r with 401 unless it carries the API token. The
+// write endpoints on the metrics port are disabled without a token.
+func (s *Service) requireAPIToken(w http.ResponseWriter, r *http.Request) bool {
+	s.mu.Lock()
+	acks := s.config.acks
+	s.mu.Unlock()
+	if !acks.authorized(r) {
+		http.Error(w, "a valid API token is required (acknowledgements.api_token)", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// Acknowledgement records who took care of an outage.
+type Acknowledgement struct {
+	Name    string    `json:"name"`
+	URL     string    `json:"url"`
+	By      string    `json:"by"`
+	Comment string    `json:"comment,omitempty"`
+	At      time.Time `json:"at"`
+	Since   time.Time `json:"since"` // Start of the acknowledged outage
+}
+
+// acknowledge marks the current outage of t as acknowledged. A non-zero
+// since must match the start of the outage, so links of earlier outages
+// don't acknowledge a new one.
+func (s *Service) acknowledge(t target, by, comment string, since int64) (Acknowledgement, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	url := t.url
+	down := s.offlineMap[url]
+	if since != 0 && (!down || s.downSince[url].Unix() != since) {
+		return Acknowledgement{}, errOutageEnded
+	}
+	if !down {
+		return Acknowledgement{}, errNotDown
+	}
+	if ack, ok := s.acked[url]; ok {
+		return ack, errAlreadyAcked
+	}
+	name := t.name
+	if name == "" {
+		name = url
+	}
+	ack := Acknowledgement{Name: name, URL: url, By: by, Comment: comment, At: time.Now(), Since: s.downSince[url]}
+	s.acked[url] = ack
+	s.metrics.acknowledged.With(prometheus.Labels{"url": url}).Set(1)
+	log.Printf("Outage of %s acknowledged by %s", url, by)
+	return ack, nil
+}
+
+// clearAckLocked forgets the acknowledgement of url. Callers must hold s.mu.
+func (s *Service) clearAckLocked(url string) {
+	delete(s.acked, url)
+	s.metrics.acknowledged.Delete(prometheus.Labels{"url": url})
+}
+
+// handleAck serves acknowledgements: GET /ack shows the confirmation form
+// of a signed link, POST /ack acknowledges an outage with that form or, with
+// the API token, by target name or URL.
+func (s *Service) handleAck(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handleAckLink(w, r)
+	case http.MethodPost:
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+			s.handleAckForm(w, r)
+			return
+		}
+		s.handleAckAPI(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAckAPI acknowledges the outage of a target by name or URL. It
+// requires the API token.
+func (s *Service) handleAckAPI(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAPIToken(w, r) {
+		return
+	}
+	var req struct {
+		Target  string `json:"target"`
+		By      string `json:"by"`
+		Comment string `json:"comment"`
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid acknowledgement: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Target == "" || req.By == "" {
+		http.Error(w, "target and by are required", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	t, ok := findTarget(s.config.targets, req.Target)
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown target %q", req.Target), http.StatusNotFound)
+		return
+	}
+	ack, err := s.acknowledge(t, req.By, req.Comment, 0)
+	switch {
+	case errors.Is(err, errAlreadyAcked):
+		http.Error(w, fmt.Sprintf("%v by %s at %s", err, ack.By, ack.At.UTC().Format(time.RFC3339)), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		writeJSON(w, http.StatusOK, ack)
+	}
+}
+
+// signedAckTarget returns the target and outage start of the signed url,
+// since and sig parameters of r, writing the error response if they are
+// invalid.
+func (s *Service) signedAckTarget(w http.ResponseWriter, r *http.Request) (target, int64, bool) {
+	url := r.FormValue("url")
+	since, err := strconv.ParseInt(r.FormValue("since"), 10, 64)
+	s.mu.Lock()
+	acks := s.config.acks
+	t, ok := findTarget(s.config.targets, url)
+	s.mu.Unlock()
+	if err != nil || since == 0 || !acks.verify(url, since, r.FormValue("sig")) {
+		http.Error(w, errInvalidAckSig.Error(), http.StatusForbidden)
+		return target{}, 0, false
+	}
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown target %q", url), http.StatusNotFound)
+		return target{}, 0, false
+	}
+	return t, since, true
+}
+
+// handleAckLink shows the confirmation form of a signed link. The optional
+// by parameter fills in the name.
+func (s *Service) handleAckLink(w http.ResponseWriter, r *http.Request) {
+	t, since, ok := s.signedAckTarget(w, r)
+	if !ok {
+		return
+	}
+	name := t.name
+	if name == "" {
+		name = t.url
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	ackForm.Execute(w, map[string]any{
+		"Name":      name,
+		"URL":       t.url,
+		"Since":     time.Unix(since, 0).UTC().Format(time.RFC1123),
+		"SinceUnix": since,
+		"Sig":       r.FormValue("sig"),
+		"By":        r.FormValue("by"),
+	})
+}
+
+// handleAckForm acknowledges the outage of a signed link with the name
+// entered in its confirmation form.
+func (s *Service) handleAckForm(w http.ResponseWriter, r *http.Request) {
+	t, since, ok := s.signedAckTarget(w, r)
+	if !ok {
+		return
+	}
+	by := strings.TrimSpace(r.PostFormValue("by"))
+	if by == "" {
+		http.Error(w, "a name is required", http.StatusBadRequest)
+		return
+	}
+	ack, err := s.acknowledge(t, by, strings.TrimSpace(r.PostFormValue("comment")), since)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	switch {
+	case errors.Is(err, errAlreadyAcked):
+		fmt.Fprintf(w, "The outage of %s was already acknowledged by %s at %s.\n", ack.Name, ack.By, ack.At.UTC().Format(time.RFC1123))
+	case errors.Is(err, errOutageEnded):
+		http.Error(w, err.Error(), http.StatusGone)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		fmt.Fprintf(w, "Acknowledged the outage of %s (%s) as %s. Reminders and escalations are stopped until it recovers.\n", ack.Name, ack.URL, ack.By)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var ackTarget = target{name: "api", url: "https://api.com", reminderInterval: 30 * time.Minute}
+
+// enableAcks turns on reminders and acknowledgements.
+func enableAcks(s *Service) {
+	s.config.reminders = reminderConfig{interval: 30 * time.Minute}
+	s.config.acks = ackConfig{baseURL: "https://monitor.example.com/", secret: "s3cret", apiToken: "t0ken"}
+}
+
+func postAck(s *Service, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/ack", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer t0ken")
+	s.handleAck(rec, req)
+	return rec
+}
+
+func TestAcknowledgementStopsReminders(t *testing.T) {
+	tgt := ackTarget
+	s, rec := newRecordingService(tgt)
+	enableAcks(s)
+
+	s.handleSiteError(tgt, "returned status 503")
+	res := postAck(s, `{"target": "api", "by": "alice", "comment": "rolling back"}`)
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", res.Code, res.Body)
+	}
+	var ack Acknowledgement
+	json.NewDecoder(res.Body).Decode(&ack)
+	if ack.By != "alice" || ack.Comment != "rolling back" || ack.URL != tgt.url || ack.At.IsZero() {
+		t.Errorf("unexpected acknowledgement: %+v", ack)
+	}
+	if got := testutil.ToFloat64(s.metrics.acknowledged.With(prometheus.Labels{"url": tgt.url})); got != 1 {
+		t.Errorf("expected site_acknowledged 1, got %v", got)
+	}
+
+	backdateLastAlert(s, tgt.url, 31*time.Minute)
+	s.handleSiteError(tgt, "returned status 503")
+	if got := rec.received(); len(got) != 1 {
+		t.Fatalf("no reminders should be sent for an acknowledged outage, got %+v", got)
+	}
+
+	s.handleSiteRecovery(tgt)
+	got := rec.received()
+	if len(got) != 2 || got[1].State != StateUp || got[1].AckedBy != "alice" || got[1].AckedAt.IsZero() {
+		t.Fatalf("recovery notice should carry the acknowledgement, got %+v", got)
+	}
+	if _, body := plainMessage(got[1]); !strings.Contains(body, "acknowledged by alice") {
+		t.Errorf("recovery message should mention the acknowledgement: %q", body)
+	}
+	if _, text, _, _ := (alertTemplates{}).render(got[1]); !strings.Contains(text, "Acknowledged by alice") {
+		t.Errorf("recovery email should mention the acknowledgement: %q", text)
+	}
+	if testutil.CollectAndCount(s.metrics.acknowledged) != 0 || len(s.acked) != 0 {
+		t.Error("acknowledgement should be reset on recovery")
+	}
+}
+
+func TestAcknowledgementLink(t *testing.T) {
+	tgt := ackTarget
+	s, rec := newRecordingService(tgt)
+	enableAcks(s)
+
+	s.handleSiteError(tgt, "returned status 503")
+	down := rec.received()[0]
+	if !strings.HasPrefix(down.AckURL, "https://monitor.example.com/ack?") {
+		t.Fatalf("DOWN event should carry an acknowledgement link, got %q", down.AckURL)
+	}
+	_, text, html, _ := (alertTemplates{}).render(down)
+	if !strings.Contains(text, "Acknowledge: "+down.AckURL) || !strings.Contains(html, ">Acknowledge</a>") {
+		t.Errorf("DOWN email should contain the acknowledgement link:\n%s", text)
+	}
+
+	follow := func(link string) *httptest.ResponseRecorder {
+		u, _ := neturl.Parse(link)
+		res := httptest.NewRecorder()
+		s.handleAck(res, httptest.NewRequest(http.MethodGet, u.RequestURI(), nil))
+		return res
+	}
+	// confirm posts the confirmation form of link with the name by.
+	confirm := func(link, by string) *httptest.ResponseRecorder {
+		u, _ := neturl.Parse(link)
+		form := u.Query()
+		form.Set("by", by)
+		req := httptest.NewRequest(http.MethodPost, "/ack", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		res := httptest.NewRecorder()
+		s.handleAck(res, req)
+		return res
+	}
+
+	tampered := strings.Replace(down.AckURL, "api.com", "evil.com", 1)
+	if res := follow(tampered); res.Code != http.StatusForbidden {
+		t.Errorf("tampered link: expected 403, got %d", res.Code)
+	}
+	if res := confirm(tampered, "mallory"); res.Code != http.StatusForbidden {
+		t.Errorf("tampered form: expected 403, got %d", res.Code)
+	}
+	res := follow(down.AckURL + "&by=bob")
+	if res.Code != http.StatusOK || !strings.Contains(res.Body.String(), `<form method="post" action="ack">`) || !strings.Contains(res.Body.String(), `name="by" value="bob"`) {
+		t.Fatalf("expected the confirmation form with the name filled in, got %d: %s", res.Code, res.Body)
+	}
+	if len(s.acked) != 0 {
+		t.Fatal("following the link must not acknowledge the outage")
+	}
+	if res := confirm(down.AckURL, " "); res.Code != http.StatusBadRequest || len(s.acked) != 0 {
+		t.Errorf("a name is required, got %d: %s", res.Code, res.Body)
+	}
+	if res := confirm(down.AckURL, "bob"); res.Code != http.StatusOK || !strings.Contains(res.Body.String(), "as bob") {
+		t.Fatalf("expected the outage to be acknowledged, got %d: %s", res.Code, res.Body)
+	}
+	if res := confirm(down.AckURL, "carol"); res.Code != http.StatusOK || !strings.Contains(res.Body.String(), "already acknowledged by bob") {
+		t.Errorf("second confirmation should report the acknowledgement, got %d: %s", res.Code, res.Body)
+	}
+
+	backdateLastAlert(s, tgt.url, 31*time.Minute)
+	s.handleSiteError(tgt, "returned status 503")
+	if len(rec.received()) != 1 {
+		t.Error("no reminders should be sent for an acknowledged outage")
+	}
+
+	// Links of an outage that ended don't acknowledge the next one.
+	s.handleSiteRecovery(tgt)
+	s.handleSiteError(tgt, "returned status 503")
+	s.mu.Lock()
+	s.downSince[tgt.url] = s.downSince[tgt.url].Add(time.Minute)
+	s.mu.Unlock()
+	if res := confirm(down.AckURL, "bob"); res.Code != http.StatusGone {
+		t.Errorf("stale link: expected 410, got %d: %s", res.Code, res.Body)
+	}
+}
+
+func TestAcknowledgementAPIErrors(t *testing.T) {
+	tgt := ackTarget
+	s, _ := newRecordingService(tgt)
+	enableAcks(s)
+
+	for _, c := range []struct {
+		body string
+		want int
+	}{
+		{`{"target": "api"}`, http.StatusBadRequest},
+		{`{"target": "api", "by": "alice", "until": "tomorrow"}`, http.StatusBadRequest},
+		{`{"target": "nope", "by": "alice"}`, http.StatusNotFound},
+		{`{"target": "api", "by": "alice"}`, http.StatusConflict}, // Not down
+	} {
+		if res := postAck(s, c.body); res.Code != c.want {
+			t.Errorf("%s: expected %d, got %d: %s", c.body, c.want, res.Code, res.Body)
+		}
+	}
+
+	s.handleSiteError(tgt, "returned status 503")
+	postAck(s, `{"target": "https://api.com", "by": "alice"}`)
+	if res := postAck(s, `{"target": "api", "by": "bob"}`); res.Code != http.StatusConflict || !strings.Contains(res.Body.String(), "by alice") {
+		t.Errorf("expected a conflict naming the first acknowledgement, got %d: %s", res.Code, res.Body)
+	}
+
+	for _, auth := range []string{"", "Bearer wrong", "t0ken"} {
+		req := httptest.NewRequest(http.MethodPost, "/ack", strings.NewReader(`{"target": "api", "by": "mallory"}`))
+		req.Header.Set("Authorization", auth)
+		res := httptest.NewRecorder()
+		s.handleAck(res, req)
+		if res.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: expected 401, got %d", auth, res.Code)
+		}
+	}
+	s.config.acks.apiToken = ""
+	if res := postAck(s, `{"target": "api", "by": "alice"}`); res.Code != http.StatusUnauthorized {
+		t.Errorf("the API should be disabled without a token, got %d", res.Code)
+	}
+
+	res := httptest.NewRecorder()
+	s.handleAck(res, httptest.NewRequest(http.MethodDelete, "/ack", nil))
+	if res.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", res.Code)
+	}
+}
+
+func TestNoAcknowledgementLinkWithoutConfig(t *testing.T) {
+	tgt := ackTarget
+	s, rec := newRecordingService(tgt)
+	enableAcks(s)
+	s.config.acks = ackConfig{}
+
+	s.handleSiteError(tgt, "returned status 503")
+	if got := rec.received(); len(got) != 1 || got[0].AckURL != "" {
+		t.Fatalf("expected no acknowledgement link, got %+v", got)
+	}
+	if _, text, _, _ := (alertTemplates{}).render(rec.received()[0]); strings.Contains(text, "Acknowledge") {
+		t.Errorf("DOWN email should not mention acknowledgements: %q", text)
+	}
+}
+
+func TestLoadConfigAcknowledgements(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+acknowledgements:
+  base_url: https://monitor.example.com
+targets:
+  - name: api
+    url: https://api.example.com
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "CHECK_INTERVAL": "", "ALERT_THRESHOLD": "", "ACK_BASE_URL": "", "ACK_SECRET": "s3cret", "ACK_API_TOKEN": "t0ken"})
+	defer cleanup()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.acks.baseURL != "https://monitor.example.com" || cfg.acks.secret != "s3cret" || cfg.acks.apiToken != "t0ken" {
+		t.Errorf("acknowledgement settings not loaded: %+v", cfg.acks)
+	}
+
+	errs := &configErrors{}
+	validateAcks(ackConfig{baseURL: "monitor.example.com"}, errs)
+	msg := errs.Error()
+	for _, want := range []string{"acknowledgements.base_url: invalid URL", "acknowledgements.secret: required"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("missing %q in %s", want, msg)
+		}
+	}
+}
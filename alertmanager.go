@@ -0,0 +1,171 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAlertmanagerResend    = time.Minute
+	defaultAlertmanagerAlertName = "SiteDown"
+)
+
+var (
+	alertmanagerLabelName    = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	alertmanagerInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+// alertmanagerConfig holds the settings of a Prometheus Alertmanager notifier.
+type alertmanagerConfig struct {
+	url            string // Alertmanager base URL
+	headers        map[string]string
+	alertName      string
+	labels         map[string]string // Static labels added to every alert
+	resendInterval time.Duration
+}
+
+type fileAlertmanager struct {
+	URL            string            `yaml:"url" json:"url"`
+	Headers        map[string]string `yaml:"headers" json:"headers"`
+	AlertName      string            `yaml:"alertname" json:"alertname"`
+	Labels         map[string]string `yaml:"labels" json:"labels"`
+	ResendInterval string            `yaml:"resend_interval" json:"resend_interval"`
+}
+
+func parseAlertmanager(f fileAlertmanager, field string, errs *configErrors) alertmanagerConfig {
+	cfg := alertmanagerConfig{
+		url:            strings.TrimSuffix(f.URL, "/"),
+		headers:        f.Headers,
+		alertName:      f.AlertName,
+		labels:         f.Labels,
+		resendInterval: defaultAlertmanagerResend,
+	}
+	if cfg.alertName == "" {
+		cfg.alertName = defaultAlertmanagerAlertName
+	}
+	if f.ResendInterval != "" {
+		errs.duration(field+".resend_interval", f.ResendInterval, &cfg.resendInterval)
+	}
+	return cfg
+}
+
+func validateAlertmanager(cfg alertmanagerConfig, field string, errs *configErrors) {
+	requireURL(errs, field+".url", cfg.url, "alertmanager")
+	if cfg.resendInterval <= 0 {
+		errs.add(field+".resend_interval", "must be a positive duration")
+	}
+	for name := range cfg.labels {
+		if !alertmanagerLabelName.MatchString(name) {
+			errs.add(field+".labels."+name, "invalid label name %q", name)
+		}
+	}
+}
+
+// alertmanagerNotifier posts alerts to Alertmanager's /api/v2/alerts
+// endpoint. Alerts are re-posted while a target stays down so they don't
+// expire, and resolved with endsAt on recovery.
+type alertmanagerNotifier struct {
+	cfg    alertmanagerConfig
+	client *http.Client
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time // Last post per firing target URL
+}
+
+func newAlertmanagerNotifier(nc notifierConfig) *alertmanagerNotifier {
+	return &alertmanagerNotifier{
+		cfg:      nc.alertmanager,
+		client:   &http.Client{Timeout: nc.timeout},
+		lastSent: make(map[string]time.Time),
+	}
+}
+
+type alertmanagerAlert struct {
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+}
+
+func (n *alertmanagerNotifier) Notify(ev AlertEvent) error {
+	if ev.State == StateFlapping || ev.latencyOnly() {
+		// Alerts follow DOWN and UP, flapping and degradation leave them as they are.
+		return nil
+	}
+	n.mu.Lock()
+	if ev.State == StateUp {
+		delete(n.lastSent, ev.URL)
+	} else {
+		n.lastSent[ev.URL] = ev.At
+	}
+	n.mu.Unlock()
+	return n.post(ev)
+}
+
+// Repeat re-posts the alert of a target that is still down once the resend
+// interval has passed.
+func (n *alertmanagerNotifier) Repeat(ev AlertEvent) error {
+	n.mu.Lock()
+	due := ev.At.Sub(n.lastSent[ev.URL]) >= n.cfg.resendInterval
+	if due {
+		n.lastSent[ev.URL] = ev.At
+	}
+	n.mu.Unlock()
+	if !due {
+		return nil
+	}
+	return n.post(ev)
+}
+
+func (n *alertmanagerNotifier) post(ev AlertEvent) error {
+	return postJSON(n.client, n.cfg.url+"/api/v2/alerts", n.cfg.headers, []alertmanagerAlert{n.alert(ev)})
+}
+
+func (n *alertmanagerNotifier) alert(ev AlertEvent) alertmanagerAlert {
+	labels := map[string]string{}
+	for k, v := range ev.Labels {
+		labels[alertmanagerLabel(k)] = v
+	}
+	for k, v := range n.cfg.labels {
+		labels[k] = v
+	}
+	labels["alertname"] = n.cfg.alertName
+	labels["url"] = ev.URL
+	labels["target"] = ev.Name
+
+	title, body := plainMessage(ev)
+	a := alertmanagerAlert{
+		Labels: labels,
+		Annotations: map[string]string{
+			"summary":     title,
+			"description": body,
+			"reason":      ev.Reason,
+		},
+		StartsAt:     ev.Since,
+		GeneratorURL: ev.DashboardURL,
+	}
+	if a.StartsAt.IsZero() {
+		a.StartsAt = ev.At
+	}
+	if ev.State == StateUp {
+		a.EndsAt = ev.At
+	} else {
+		// Keep the alert firing for a few missed re-posts, covering targets
+		// checked less often than the resend interval.
+		a.EndsAt = ev.At.Add(4 * max(n.cfg.resendInterval, ev.target.interval))
+	}
+	return a
+}
+
+// alertmanagerLabel turns a target label name into a valid Alertmanager label name.
+func alertmanagerLabel(name string) string {
+	name = alertmanagerInvalidChars.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
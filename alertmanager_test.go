@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlertmanagerNotifierLifecycle(t *testing.T) {
+	am := newWebhookRecorder(t, http.StatusOK)
+	var paths []string
+	am.Config.Handler = recordPath(am.Config.Handler, &paths)
+	n := newAlertmanagerNotifier(notifierConfig{timeout: time.Second, alertmanager: alertmanagerConfig{
+		url:            am.URL,
+		alertName:      "SiteDown",
+		labels:         map[string]string{"severity": "page"},
+		resendInterval: time.Minute,
+	}})
+
+	down := testEvent(StateDown)
+	down.Labels = map[string]string{"team": "payments", "app.kubernetes.io/name": "shop"}
+	if err := n.Notify(down); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var alerts []alertmanagerAlert
+	am.next(t, &alerts)
+	if len(alerts) != 1 || len(paths) != 1 || paths[0] != "/api/v2/alerts" {
+		t.Fatalf("unexpected request %v: %+v", paths, alerts)
+	}
+	a := alerts[0]
+	want := map[string]string{
+		"alertname": "SiteDown", "url": "https://shop.example.com", "target": "checkout",
+		"team": "payments", "app_kubernetes_io_name": "shop", "severity": "page",
+	}
+	for k, v := range want {
+		if a.Labels[k] != v {
+			t.Errorf("label %s: expected %q, got %q", k, v, a.Labels[k])
+		}
+	}
+	if !a.StartsAt.Equal(down.Since) || !a.EndsAt.Equal(down.At.Add(4*time.Minute)) {
+		t.Errorf("unexpected firing window %s - %s", a.StartsAt, a.EndsAt)
+	}
+	if a.GeneratorURL != down.DashboardURL || a.Annotations["reason"] != "returned status 503" {
+		t.Errorf("unexpected alert: %+v", a)
+	}
+
+	// Still down: re-posted only once the resend interval has passed.
+	down.At = down.At.Add(30 * time.Second)
+	if err := n.Repeat(down); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	down.At = down.At.Add(30 * time.Second)
+	if err := n.Repeat(down); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	am.next(t, &alerts)
+	if len(paths) != 2 || !alerts[0].EndsAt.Equal(down.At.Add(4*time.Minute)) {
+		t.Errorf("expected one re-post extending endsAt, got %d requests: %+v", len(paths), alerts)
+	}
+
+	up := testEvent(StateUp)
+	if err := n.Notify(up); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	am.next(t, &alerts)
+	if !alerts[0].EndsAt.Equal(up.At) {
+		t.Errorf("expected endsAt %s on recovery, got %s", up.At, alerts[0].EndsAt)
+	}
+	if err := n.Repeat(testEvent(StateDown)); err != nil || len(paths) != 4 {
+		t.Errorf("expected repeat after recovery to post again, got %d requests (%v)", len(paths), err)
+	}
+}
+
+type repeatingNotifier struct {
+	recordingNotifier
+	repeats []AlertEvent
+}
+
+func (r *repeatingNotifier) Repeat(ev AlertEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.repeats = append(r.repeats, ev)
+	return nil
+}
+
+func TestHandleSiteErrorRepeatsWhileDown(t *testing.T) {
+	s := newTestService()
+	rn := &repeatingNotifier{}
+	s.notifiers = []notifierEntry{{name: "am", notifier: rn}}
+	tg := target{name: "api", url: "https://api.example.com", alertThreshold: 1}
+
+	s.handleSiteError(tg, "returned status 502")
+	s.handleSiteError(tg, "returned status 503")
+	s.handleSiteError(tg, "returned status 503")
+
+	if got := len(rn.received()); got != 1 {
+		t.Errorf("expected 1 DOWN notification, got %d", got)
+	}
+	if len(rn.repeats) != 2 || rn.repeats[1].FailureCount != 3 || rn.repeats[1].State != StateDown {
+		t.Errorf("unexpected repeats: %+v", rn.repeats)
+	}
+}
+
+func TestValidateAlertmanager(t *testing.T) {
+	errs := &configErrors{}
+	cfg := parseAlertmanager(fileAlertmanager{ResendInterval: "0s", Labels: map[string]string{"bad-name": "x"}}, "notifiers[0].alertmanager", errs)
+	validateAlertmanager(cfg, "notifiers[0].alertmanager", errs)
+	got := errs.Error()
+	for _, want := range []string{"alertmanager.url", "resend_interval: must be a positive duration", `invalid label name "bad-name"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in:\n%s", want, got)
+		}
+	}
+}
@@ -1,72 +1,580 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
-const defaultCheckDurationTime = 51
+const (
+	defaultCheckDurationTime = 51
+	defaultAlertThreshold    = 2
+	defaultRecoveryThreshold = 1
+	defaultRequestTimeout    = 10 * time.Second
+	defaultHistorySize       = 10
+	envFile                  = "config/.env"
+)
+
+// defaultConfigFiles are tried in order when CONFIG_FILE is not set.
+var defaultConfigFiles = []string{"config/config.yaml", "config/config.yml", "config/config.json"}
+
+// target is a single monitored endpoint with all defaults already applied.
+type target struct {
+	name              string
+	url               string
+	method            string
+	headers           map[string]string
+	timeout           time.Duration
+	interval          time.Duration
+	alertThreshold    int           // Number of consecutive failures before alerting
+	alertWindow       int           // Recent checks alertThreshold failures are counted in, 0 for consecutive failures
+	recoveryThreshold int           // Number of consecutive successes before a down target is up again
+	latencyWarning    time.Duration // Checks slower than this count towards DEGRADED, 0 disables
+	latencyCritical   time.Duration // Checks slower than this make DEGRADED critical, 0 disables
+	degradedThreshold int           // Number of consecutive slow checks before DEGRADED
+	labels            map[string]string
+	recipients        []string
+	dashboardURL      string        // Grafana dashboard linked from notifications
+	reminderInterval  time.Duration // Repeat DOWN notifications this often while down, 0 disables
+	parent            string        // Name or URL of the target this one depends on
+	escalationPolicy  string        // Name of the escalation policy, empty for none
+}
 
 type appConfig struct {
-	urls           []string
-	checkInterval  time.Duration
-	smtpServer     string
-	smtpPort       string
-	smtpUser       string
-	smtpPass       string
-	smtpTo         string
-	smtpFrom       string
-	alertThreshold int // Number of consecutive failures before alerting
+	targets           []target
+	checkInterval     time.Duration
+	timeout           time.Duration
+	smtpServer        string
+	smtpPort          string
+	smtpUser          string
+	smtpPass          string
+	smtpTo            []string
+	smtpCC            []string
+	smtpBCC           []string
+	emailRoutes       []emailRoute
+	smtpFrom          string
+	smtpTLS           string // auto, none, starttls or implicit
+	smtpCAFile        string
+	smtpSkipVerify    bool
+	smtpAuth          string // none, plain, login or cram-md5; empty picks plain if a user is set
+	smtpTimeout       time.Duration
+	alertThreshold    int // Number of consecutive failures before alerting
+	alertWindow       int // Recent checks alertThreshold failures are counted in, 0 for consecutive failures
+	recoveryThreshold int // Number of consecutive successes before a down target is up again
+	latencyWarning    time.Duration
+	latencyCritical   time.Duration
+	degradedThreshold int    // Number of consecutive slow checks before DEGRADED
+	configFile        string // Path of the loaded config file, empty if only env vars were used
+	dashboardURL      string
+	notifiers         []notifierConfig
+	templates         alertTemplates
+	historySize       int // Recent check results kept per target for notifications
+	outbox            outboxConfig
+	reminders         reminderConfig
+	grouping          groupingConfig
+	maintenance       []Silence // Maintenance windows from the config file
+	silencesPath      string    // File the silences created through the API are persisted to
+	acks              ackConfig
+	policies          []escalationPolicy
+	schedules         []onCallSchedule
+	people            map[string]OnCallPerson
+	flapping          flappingConfig
+	timings           timingsConfig
+}
+
+// fileConfig mirrors the layout of the YAML/JSON config file.
+type fileConfig struct {
+	Defaults    fileDefaults      `yaml:"defaults" json:"defaults"`
+	SMTP        fileSMTP          `yaml:"smtp" json:"smtp"`
+	Targets     []fileTarget      `yaml:"targets" json:"targets"`
+	Notifiers   []fileNotifier    `yaml:"notifiers" json:"notifiers"`
+	Templates   fileTemplates     `yaml:"templates" json:"templates"`
+	Outbox      fileOutbox        `yaml:"outbox" json:"outbox"`
+	Reminders   fileReminders     `yaml:"reminders" json:"reminders"`
+	Grouping    fileGrouping      `yaml:"grouping" json:"grouping"`
+	Maintenance []fileMaintenance `yaml:"maintenance" json:"maintenance"`
+	Silences    fileSilences      `yaml:"silences" json:"silences"`
+	Acks        fileAcks          `yaml:"acknowledgements" json:"acknowledgements"`
+	Policies    []filePolicy      `yaml:"escalation_policies" json:"escalation_policies"`
+	OnCall      fileOnCall        `yaml:"oncall" json:"oncall"`
+	Flapping    fileFlapping      `yaml:"flapping" json:"flapping"`
+	Timings     fileTimings       `yaml:"timings" json:"timings"`
+}
+
+type fileDefaults struct {
+	Method            string            `yaml:"method" json:"method"`
+	Headers           map[string]string `yaml:"headers" json:"headers"`
+	Timeout           string            `yaml:"timeout" json:"timeout"`
+	Interval          string            `yaml:"interval" json:"interval"`
+	AlertThreshold    *int              `yaml:"alert_threshold" json:"alert_threshold"`
+	AlertWindow       int               `yaml:"alert_window" json:"alert_window"`
+	RecoveryThreshold int               `yaml:"recovery_threshold" json:"recovery_threshold"`
+	LatencyWarning    string            `yaml:"latency_warning" json:"latency_warning"`
+	LatencyCritical   string            `yaml:"latency_critical" json:"latency_critical"`
+	DegradedThreshold int               `yaml:"degraded_threshold" json:"degraded_threshold"`
+	Labels            map[string]string `yaml:"labels" json:"labels"`
+	Recipients        []string          `yaml:"recipients" json:"recipients"`
+	DashboardURL      string            `yaml:"dashboard_url" json:"dashboard_url"`
+	History           *int              `yaml:"history" json:"history"`
+	EscalationPolicy  string            `yaml:"escalation_policy" json:"escalation_policy"`
+}
+
+type fileSMTP struct {
+	Server             string      `yaml:"server" json:"server"`
+	Port               string      `yaml:"port" json:"port"`
+	User               string      `yaml:"user" json:"user"`
+	Pass               string      `yaml:"pass" json:"pass"`
+	To                 addressList `yaml:"to" json:"to"`
+	CC                 addressList `yaml:"cc" json:"cc"`
+	BCC                addressList `yaml:"bcc" json:"bcc"`
+	From               string      `yaml:"from" json:"from"`
+	Routes             []fileRoute `yaml:"routes" json:"routes"`
+	TLS                string      `yaml:"tls" json:"tls"`
+	CAFile             string      `yaml:"ca_file" json:"ca_file"`
+	InsecureSkipVerify *bool       `yaml:"insecure_skip_verify" json:"insecure_skip_verify"`
+	Auth               string      `yaml:"auth" json:"auth"`
+	Timeout            string      `yaml:"timeout" json:"timeout"`
+}
+
+type fileTarget struct {
+	Name              string            `yaml:"name" json:"name"`
+	URL               string            `yaml:"url" json:"url"`
+	Method            string            `yaml:"method" json:"method"`
+	Headers           map[string]string `yaml:"headers" json:"headers"`
+	Timeout           string            `yaml:"timeout" json:"timeout"`
+	Interval          string            `yaml:"interval" json:"interval"`
+	AlertThreshold    *int              `yaml:"alert_threshold" json:"alert_threshold"`
+	AlertWindow       int               `yaml:"alert_window" json:"alert_window"`
+	RecoveryThreshold int               `yaml:"recovery_threshold" json:"recovery_threshold"`
+	LatencyWarning    string            `yaml:"latency_warning" json:"latency_warning"`
+	LatencyCritical   string            `yaml:"latency_critical" json:"latency_critical"`
+	DegradedThreshold int               `yaml:"degraded_threshold" json:"degraded_threshold"`
+	Labels            map[string]string `yaml:"labels" json:"labels"`
+	Recipients        []string          `yaml:"recipients" json:"recipients"`
+	DashboardURL      string            `yaml:"dashboard_url" json:"dashboard_url"`
+	ReminderInterval  string            `yaml:"reminder_interval" json:"reminder_interval"`
+	Parent            string            `yaml:"parent" json:"parent"`
+	EscalationPolicy  string            `yaml:"escalation_policy" json:"escalation_policy"`
 }
 
 func (s *Service) readConfig() {
-	err := godotenv.Load("config/.env")
+	cfg, err := loadConfig()
 	if err != nil {
-		log.Fatalf("Some error occured. Err: %s", err)
-	}
-	s.config.urls = strings.Split(os.Getenv("URLS"), ",")
-	interval := os.Getenv("CHECK_INTERVAL")
-	if interval == "" {
-		s.config.checkInterval = defaultCheckDurationTime * time.Second
-	} else {
-		dur, err := time.ParseDuration(interval)
-		if err != nil {
-			log.Fatalf("Invalid CHECK_INTERVAL: %s", err)
-		}
-		s.config.checkInterval = dur
-	}
-	s.config.smtpServer = os.Getenv("SMTP_SERVER")
-	s.config.smtpPort = os.Getenv("SMTP_PORT")
-	s.config.smtpUser = os.Getenv("SMTP_USER")
-	s.config.smtpPass = os.Getenv("SMTP_PASS")
-	s.config.smtpTo = os.Getenv("SMTP_TO")
-	s.config.smtpFrom = os.Getenv("SMTP_FROM")
-	// Load alert threshold
-	thresh := os.Getenv("ALERT_THRESHOLD")
-	if thresh == "" {
-		s.config.alertThreshold = 2
-	} else {
-		var val int
-		_, err := fmt.Sscanf(thresh, "%d", &val)
-		if err != nil || val < 1 {
-			s.config.alertThreshold = 2
-		} else {
-			s.config.alertThreshold = val
-		}
-	}
-	s.metrics.sites.Add(float64(len(s.config.urls)))
+		log.Fatalf("Invalid configuration: %s", err)
+	}
+	s.config = cfg
+	s.metrics.sites.Set(float64(len(s.config.targets)))
 
 	log.Println("Loaded configuration:")
-	log.Printf("  URLs: %v", s.config.urls)
+	if s.config.configFile != "" {
+		log.Printf("  Config file: %s", s.config.configFile)
+	}
+	for _, t := range s.config.targets {
+		log.Printf("  Target %q: %s %s (interval %v, timeout %v, threshold %d)", t.name, t.method, t.url, t.interval, t.timeout, t.alertThreshold)
+	}
 	log.Printf("  Check interval: %v", s.config.checkInterval)
 	log.Printf("  SMTP server: %s:%s", s.config.smtpServer, s.config.smtpPort)
-	log.Printf("  SMTP user: %s", s.config.smtpUser)
-	log.Printf("  SMTP to: %s", s.config.smtpTo)
+	log.Printf("  SMTP user: %s (auth %s, TLS %s)", s.config.smtpUser, smtpAuthMechanism(s.config), s.config.smtpTLS)
+	log.Printf("  SMTP to: %s", strings.Join(s.config.smtpTo, ", "))
+	if len(s.config.smtpCC) > 0 {
+		log.Printf("  SMTP cc: %s", strings.Join(s.config.smtpCC, ", "))
+	}
+	if len(s.config.smtpBCC) > 0 {
+		log.Printf("  SMTP bcc: %s", strings.Join(s.config.smtpBCC, ", "))
+	}
+	if len(s.config.emailRoutes) > 0 {
+		log.Printf("  Email routes: %d", len(s.config.emailRoutes))
+	}
 	log.Printf("  SMTP from: %s", s.config.smtpFrom)
 	log.Printf("  Alert threshold: %d", s.config.alertThreshold)
+	if s.config.reminders.interval > 0 {
+		log.Printf("  Reminder interval: %v", s.config.reminders.interval)
+	}
+	if s.config.acks.baseURL != "" {
+		log.Printf("  Acknowledgement links: %s/ack", strings.TrimSuffix(s.config.acks.baseURL, "/"))
+	}
+	if len(s.config.policies) > 0 {
+		log.Printf("  Escalation policies: %d, on-call schedules: %d", len(s.config.policies), len(s.config.schedules))
+	}
+	if f := s.config.flapping; f.window > 0 {
+		log.Printf("  Flap detection: %d checks, %g%%/%g%% state change", f.window, f.highThreshold, f.lowThreshold)
+	}
+	if b := s.config.timings.buckets; len(b) > 0 {
+		log.Printf("  Response time buckets: %v", b)
+	}
+}
+
+// loadConfig builds the configuration from the environment (optionally
+// populated by config/.env) and overlays the config file, if one exists.
+// All problems found are returned together as *configErrors.
+func loadConfig() (appConfig, error) {
+	env, err := readEnvFile(envFile)
+	if err != nil {
+		return appConfig{}, err
+	}
+	errs := &configErrors{}
+	cfg := configFromEnv(env, errs)
+	targetsFromFile := false
+
+	if path := configFilePath(env); path != "" {
+		cfg.configFile = path
+		errs.source = path
+		fc, ok := readConfigFile(path, errs)
+		if !ok {
+			return appConfig{}, errs
+		}
+		cfg.applyFile(fc, errs)
+		targetsFromFile = len(fc.Targets) > 0
+	}
+
+	validateConfig(cfg, targetsFromFile, errs)
+	if err := errs.err(); err != nil {
+		return appConfig{}, err
+	}
+	return cfg, nil
+}
+
+// envLookup resolves a variable from the process environment first and
+// falls back to the values read from the .env file.
+type envLookup map[string]string
+
+func (e envLookup) get(key string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return e[key]
+}
+
+func readEnvFile(path string) (envLookup, error) {
+	values, err := godotenv.Read(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return envLookup{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func configFromEnv(env envLookup, errs *configErrors) appConfig {
+	cfg := appConfig{
+		checkInterval:     defaultCheckDurationTime * time.Second,
+		timeout:           defaultRequestTimeout,
+		alertThreshold:    defaultAlertThreshold,
+		recoveryThreshold: defaultRecoveryThreshold,
+		degradedThreshold: defaultDegradedThreshold,
+		historySize:       defaultHistorySize,
+		outbox:            defaultOutboxConfig(),
+		silencesPath:      defaultSilencesPath,
+		flapping:          defaultFlappingConfig(),
+	}
+	overlay(&cfg.outbox.path, env.get("OUTBOX_PATH"))
+	overlay(&cfg.silencesPath, env.get("SILENCES_PATH"))
+	cfg.acks.baseURL = env.get("ACK_BASE_URL")
+	cfg.acks.secret = env.get("ACK_SECRET")
+	cfg.acks.apiToken = env.get("ACK_API_TOKEN")
+	if v := env.get("REMINDER_INTERVAL"); v != "" {
+		errs.duration("REMINDER_INTERVAL", v, &cfg.reminders.interval)
+	}
+	if interval := env.get("CHECK_INTERVAL"); interval != "" {
+		errs.duration("CHECK_INTERVAL", interval, &cfg.checkInterval)
+	}
+	cfg.smtpServer = env.get("SMTP_SERVER")
+	cfg.smtpPort = env.get("SMTP_PORT")
+	cfg.smtpUser = env.get("SMTP_USER")
+	cfg.smtpPass = env.get("SMTP_PASS")
+	cfg.smtpTo = splitAddresses(env.get("SMTP_TO"))
+	cfg.smtpCC = splitAddresses(env.get("SMTP_CC"))
+	cfg.smtpBCC = splitAddresses(env.get("SMTP_BCC"))
+	cfg.smtpFrom = env.get("SMTP_FROM")
+	cfg.smtpTLS = smtpTLSAuto
+	overlay(&cfg.smtpTLS, strings.ToLower(env.get("SMTP_TLS")))
+	cfg.smtpCAFile = env.get("SMTP_CA_FILE")
+	if v := env.get("SMTP_INSECURE_SKIP_VERIFY"); v != "" {
+		skip, err := strconv.ParseBool(v)
+		if err != nil {
+			errs.addEnv("SMTP_INSECURE_SKIP_VERIFY", "%q is not a boolean", v)
+		}
+		cfg.smtpSkipVerify = skip
+	}
+	cfg.smtpAuth = strings.ToLower(env.get("SMTP_AUTH"))
+	cfg.smtpTimeout = defaultSMTPTimeout
+	if v := env.get("SMTP_TIMEOUT"); v != "" {
+		errs.duration("SMTP_TIMEOUT", v, &cfg.smtpTimeout)
+	}
+	cfg.dashboardURL = env.get("DASHBOARD_URL")
+	if v := env.get("TIMING_BUCKETS"); v != "" {
+		cfg.timings.buckets = parseBuckets(strings.Split(v, ","), "TIMING_BUCKETS", errs)
+	}
+	// Load alert and recovery thresholds
+	envInt(env, errs, "ALERT_THRESHOLD", &cfg.alertThreshold)
+	envInt(env, errs, "ALERT_WINDOW", &cfg.alertWindow)
+	envInt(env, errs, "RECOVERY_THRESHOLD", &cfg.recoveryThreshold)
+	if urls := env.get("URLS"); urls != "" {
+		for _, url := range strings.Split(urls, ",") {
+			cfg.targets = append(cfg.targets, cfg.defaultTarget(strings.TrimSpace(url)))
+		}
+	}
+	return cfg
+}
+
+// envInt sets *dst to the number in the env variable name, if it is set.
+func envInt(env envLookup, errs *configErrors, name string, dst *int) {
+	v := env.get(name)
+	if v == "" {
+		return
+	}
+	val, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		errs.addEnv(name, "%q is not a number", v)
+		return
+	}
+	*dst = val
+}
+
+// defaultTarget returns a GET target for url using the global settings.
+func (c appConfig) defaultTarget(url string) target {
+	return target{
+		name:              url,
+		url:               url,
+		method:            "GET",
+		timeout:           c.timeout,
+		interval:          c.checkInterval,
+		alertThreshold:    c.alertThreshold,
+		alertWindow:       c.alertWindow,
+		recoveryThreshold: c.recoveryThreshold,
+		latencyWarning:    c.latencyWarning,
+		latencyCritical:   c.latencyCritical,
+		degradedThreshold: c.degradedThreshold,
+		dashboardURL:      c.dashboardURL,
+		reminderInterval:  c.reminders.interval,
+	}
+}
+
+func configFilePath(env envLookup) string {
+	if path := env.get("CONFIG_FILE"); path != "" {
+		return path
+	}
+	for _, path := range defaultConfigFiles {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// readConfigFile decodes the YAML or JSON config file. Unknown keys are
+// rejected. It reports false if the file could not be decoded at all.
+func readConfigFile(path string, errs *configErrors) (fileConfig, bool) {
+	var fc fileConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		errs.add("", "reading config file: %v", err)
+		return fc, false
+	}
+	// JSON is valid YAML, so the YAML parser can locate fields in both formats.
+	errs.lines = fieldLines(data)
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&fc)
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			errs.addLine(lineAt(data, syntaxErr.Offset), "", "%v", err)
+			return fc, false
+		}
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&fc)
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			// Type errors still decode the rest of the document.
+			for _, msg := range typeErr.Errors {
+				errs.addYAML(msg)
+			}
+			return fc, true
+		}
+	default:
+		errs.add("", "unsupported extension (use .yaml, .yml or .json)")
+		return fc, false
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		errs.add("", "parsing config file: %v", err)
+		return fc, false
+	}
+	return fc, true
+}
+
+// applyFile overlays the file settings on top of the env-based config.
+// Targets from the file replace URLS entirely; empty fields keep the env value.
+func (c *appConfig) applyFile(fc fileConfig, errs *configErrors) {
+	d := fc.Defaults
+	if d.Interval != "" {
+		errs.duration("defaults.interval", d.Interval, &c.checkInterval)
+	}
+	if d.Timeout != "" {
+		errs.duration("defaults.timeout", d.Timeout, &c.timeout)
+	}
+	if d.AlertThreshold != nil {
+		c.alertThreshold = *d.AlertThreshold
+	}
+	if d.AlertWindow != 0 {
+		c.alertWindow = d.AlertWindow
+	}
+	if d.RecoveryThreshold != 0 {
+		c.recoveryThreshold = d.RecoveryThreshold
+	}
+	if d.LatencyWarning != "" {
+		errs.duration("defaults.latency_warning", d.LatencyWarning, &c.latencyWarning)
+	}
+	if d.LatencyCritical != "" {
+		errs.duration("defaults.latency_critical", d.LatencyCritical, &c.latencyCritical)
+	}
+	if d.DegradedThreshold != 0 {
+		c.degradedThreshold = d.DegradedThreshold
+	}
+	overlay(&c.dashboardURL, d.DashboardURL)
+	if d.History != nil {
+		c.historySize = *d.History
+	}
+	overlay(&c.smtpServer, fc.SMTP.Server)
+	overlay(&c.smtpPort, fc.SMTP.Port)
+	overlay(&c.smtpUser, fc.SMTP.User)
+	overlay(&c.smtpPass, fc.SMTP.Pass)
+	if len(fc.SMTP.To) > 0 {
+		c.smtpTo = fc.SMTP.To
+	}
+	if len(fc.SMTP.CC) > 0 {
+		c.smtpCC = fc.SMTP.CC
+	}
+	if len(fc.SMTP.BCC) > 0 {
+		c.smtpBCC = fc.SMTP.BCC
+	}
+	c.emailRoutes = parseRoutes(fc.SMTP.Routes)
+	overlay(&c.smtpTLS, strings.ToLower(fc.SMTP.TLS))
+	overlay(&c.smtpCAFile, fc.SMTP.CAFile)
+	if fc.SMTP.InsecureSkipVerify != nil {
+		c.smtpSkipVerify = *fc.SMTP.InsecureSkipVerify
+	}
+	overlay(&c.smtpAuth, strings.ToLower(fc.SMTP.Auth))
+	if fc.SMTP.Timeout != "" {
+		errs.duration("smtp.timeout", fc.SMTP.Timeout, &c.smtpTimeout)
+	}
+	overlay(&c.smtpFrom, fc.SMTP.From)
+	c.notifiers = parseNotifiers(fc.Notifiers, errs)
+	c.templates = parseTemplates(fc.Templates, errs)
+	c.outbox.applyFile(fc.Outbox, errs)
+	c.reminders.applyFile(fc.Reminders, errs)
+	c.grouping.applyFile(fc.Grouping, errs)
+	c.maintenance = parseMaintenance(fc.Maintenance, errs)
+	overlay(&c.silencesPath, fc.Silences.Path)
+	c.acks.applyFile(fc.Acks)
+	c.policies = parsePolicies(fc.Policies, errs)
+	c.schedules, c.people = parseOnCall(fc.OnCall, errs)
+	c.flapping.applyFile(fc.Flapping)
+	c.timings.applyFile(fc.Timings, errs)
+
+	if len(fc.Targets) == 0 {
+		// Re-derive the env targets so they pick up the file defaults.
+		for i, t := range c.targets {
+			c.targets[i] = c.defaultTarget(t.url)
+			c.targets[i].applyDefaults(d)
+		}
+		return
+	}
+
+	c.targets = nil
+	for i, ft := range fc.Targets {
+		field := fmt.Sprintf("targets[%d]", i)
+		t := c.defaultTarget(ft.URL)
+		t.applyDefaults(d)
+		if ft.Name != "" {
+			t.name = ft.Name
+		}
+		if ft.Method != "" {
+			t.method = strings.ToUpper(ft.Method)
+		}
+		if ft.Timeout != "" {
+			errs.duration(field+".timeout", ft.Timeout, &t.timeout)
+		}
+		if ft.Interval != "" {
+			errs.duration(field+".interval", ft.Interval, &t.interval)
+		}
+		if ft.AlertThreshold != nil {
+			t.alertThreshold = *ft.AlertThreshold
+		}
+		if ft.AlertWindow != 0 {
+			t.alertWindow = ft.AlertWindow
+		}
+		if ft.RecoveryThreshold != 0 {
+			t.recoveryThreshold = ft.RecoveryThreshold
+		}
+		if ft.LatencyWarning != "" {
+			errs.duration(field+".latency_warning", ft.LatencyWarning, &t.latencyWarning)
+		}
+		if ft.LatencyCritical != "" {
+			errs.duration(field+".latency_critical", ft.LatencyCritical, &t.latencyCritical)
+		}
+		if ft.DegradedThreshold != 0 {
+			t.degradedThreshold = ft.DegradedThreshold
+		}
+		t.headers = mergeMaps(t.headers, ft.Headers)
+		t.labels = mergeMaps(t.labels, ft.Labels)
+		if len(ft.Recipients) > 0 {
+			t.recipients = ft.Recipients
+		}
+		overlay(&t.dashboardURL, ft.DashboardURL)
+		t.parent = ft.Parent
+		overlay(&t.escalationPolicy, ft.EscalationPolicy)
+		if ft.ReminderInterval != "" {
+			errs.duration(field+".reminder_interval", ft.ReminderInterval, &t.reminderInterval)
+		}
+		c.targets = append(c.targets, t)
+	}
+}
+
+func (t *target) applyDefaults(d fileDefaults) {
+	if d.Method != "" {
+		t.method = strings.ToUpper(d.Method)
+	}
+	t.headers = mergeMaps(nil, d.Headers)
+	t.labels = mergeMaps(nil, d.Labels)
+	t.recipients = d.Recipients
+	t.escalationPolicy = d.EscalationPolicy
+}
+
+func overlay(dst *string, val string) {
+	if val != "" {
+		*dst = val
+	}
+}
+
+// mergeMaps returns a copy of base with the entries of extra added on top.
+func mergeMaps(base, extra map[string]string) map[string]string {
+	if len(base) == 0 && len(extra) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(base)+len(extra))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range extra {
+		out[k] = v
+	}
+	return out
 }
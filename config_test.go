@@ -2,12 +2,26 @@ package main
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// TestMain configures SMTP as the alert channel, so that config tests pass
+// in any order without setting it up themselves.
+func TestMain(m *testing.M) {
+	setupEnv(map[string]string{
+		"SMTP_SERVER": "smtp.example.com",
+		"SMTP_PORT":   "587",
+		"SMTP_TO":     "to@example.com",
+		"SMTP_FROM":   "from@example.com",
+	})
+	os.Exit(m.Run())
+}
+
 func setupEnv(env map[string]string) func() {
 	old := make(map[string]string)
 	for k, v := range env {
@@ -36,11 +50,14 @@ func TestReadConfigLoadsFields(t *testing.T) {
 	defer cleanup()
 
 	s := &Service{}
-	s.metrics.sites = prometheus.NewCounter(prometheus.CounterOpts{Name: "test_sites"})
+	s.metrics.sites = prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_sites"})
 	s.readConfig()
 
-	if len(s.config.urls) != 2 || s.config.urls[0] != "https://a.com" || s.config.urls[1] != "https://b.com" {
-		t.Errorf("URLs not loaded correctly: %v", s.config.urls)
+	if len(s.config.targets) != 2 || s.config.targets[0].url != "https://a.com" || s.config.targets[1].url != "https://b.com" {
+		t.Errorf("URLs not loaded correctly: %v", s.config.targets)
+	}
+	if s.config.targets[0].interval != 42*time.Second || s.config.targets[0].alertThreshold != 5 {
+		t.Errorf("env targets should inherit global settings: %+v", s.config.targets[0])
 	}
 	if s.config.checkInterval != 42*time.Second {
 		t.Errorf("checkInterval not parsed: got %v", s.config.checkInterval)
@@ -57,7 +74,7 @@ func TestReadConfigLoadsFields(t *testing.T) {
 	if s.config.smtpPass != "pass" {
 		t.Errorf("smtpPass not loaded")
 	}
-	if s.config.smtpTo != "to@example.com" {
+	if len(s.config.smtpTo) != 1 || s.config.smtpTo[0] != "to@example.com" {
 		t.Errorf("smtpTo not loaded")
 	}
 	if s.config.smtpFrom != "from@example.com" {
@@ -76,7 +93,7 @@ func TestReadConfigDefaultInterval(t *testing.T) {
 	defer cleanup()
 
 	s := &Service{}
-	s.metrics.sites = prometheus.NewCounter(prometheus.CounterOpts{Name: "test_sites_default"})
+	s.metrics.sites = prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_sites_default"})
 	s.readConfig()
 
 	expected := defaultCheckDurationTime * time.Second
@@ -93,7 +110,7 @@ func TestReadConfigDefaultAlertThreshold(t *testing.T) {
 	defer cleanup()
 
 	s := &Service{}
-	s.metrics.sites = prometheus.NewCounter(prometheus.CounterOpts{Name: "test_sites_default_thresh"})
+	s.metrics.sites = prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_sites_default_thresh"})
 	s.readConfig()
 
 	if s.config.alertThreshold != 2 {
@@ -108,11 +125,163 @@ func TestReadConfigInvalidAlertThreshold(t *testing.T) {
 	})
 	defer cleanup()
 
-	s := &Service{}
-	s.metrics.sites = prometheus.NewCounter(prometheus.CounterOpts{Name: "test_sites_invalid_thresh"})
-	s.readConfig()
+	_, err := loadConfig()
+	if err == nil || !strings.Contains(err.Error(), "ALERT_THRESHOLD") {
+		t.Errorf("expected ALERT_THRESHOLD error, got %v", err)
+	}
+}
 
-	if s.config.alertThreshold != 2 {
-		t.Errorf("expected fallback alertThreshold 2, got %d", s.config.alertThreshold)
+func TestLoadConfigRecoveryThresholdAndWindow(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+defaults:
+  alert_window: 5
+targets:
+  - name: api
+    url: https://api.example.com
+    alert_threshold: 3
+    recovery_threshold: 2
+  - name: www
+    url: https://www.example.com
+    alert_window: 1
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "CHECK_INTERVAL": "", "ALERT_THRESHOLD": "", "RECOVERY_THRESHOLD": "4"})
+	defer cleanup()
+
+	_, err := loadConfig()
+	if err == nil || !strings.Contains(err.Error(), "targets[1].alert_window: must be at least the alert threshold 2, got 1") {
+		t.Fatalf("expected an alert_window error, got %v", err)
+	}
+
+	os.Setenv("CONFIG_FILE", writeConfigFile(t, "fixed.yaml", `
+defaults:
+  alert_window: 5
+targets:
+  - name: api
+    url: https://api.example.com
+    alert_threshold: 3
+    recovery_threshold: 2
+  - name: www
+    url: https://www.example.com
+`))
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	api, www := cfg.targets[0], cfg.targets[1]
+	if api.alertWindow != 5 || api.alertThreshold != 3 || api.recoveryThreshold != 2 {
+		t.Errorf("api thresholds not loaded: %+v", api)
+	}
+	if www.alertWindow != 5 || www.recoveryThreshold != 4 {
+		t.Errorf("www should use the defaults and RECOVERY_THRESHOLD: %+v", www)
+	}
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigYAMLFile(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+defaults:
+  interval: 30s
+  timeout: 5s
+  alert_threshold: 3
+  labels:
+    env: prod
+smtp:
+  server: smtp.file.com
+targets:
+  - name: api
+    url: https://api.example.com/health
+    method: head
+    headers:
+      Authorization: Bearer x
+    timeout: 2s
+    alert_threshold: 1
+    labels:
+      team: payments
+    recipients: [payments@example.com]
+  - url: https://www.example.com
+    interval: 2m
+`)
+	cleanup := setupEnv(map[string]string{
+		"CONFIG_FILE":     path,
+		"URLS":            "https://ignored.com",
+		"CHECK_INTERVAL":  "",
+		"ALERT_THRESHOLD": "",
+		"SMTP_SERVER":     "smtp.env.com",
+		"SMTP_PORT":       "587",
+		"SMTP_USER":       "",
+		"SMTP_PASS":       "",
+		"SMTP_TO":         "to@example.com",
+		"SMTP_FROM":       "from@example.com",
+	})
+	defer cleanup()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.configFile != path {
+		t.Errorf("configFile not recorded: %q", cfg.configFile)
+	}
+	if cfg.smtpServer != "smtp.file.com" || cfg.smtpFrom != "from@example.com" {
+		t.Errorf("file SMTP settings should override env and fall back to it: %s %s", cfg.smtpServer, cfg.smtpFrom)
+	}
+	if len(cfg.targets) != 2 {
+		t.Fatalf("expected 2 targets, got %d", len(cfg.targets))
+	}
+	api := cfg.targets[0]
+	if api.name != "api" || api.method != "HEAD" || api.timeout != 2*time.Second || api.interval != 30*time.Second || api.alertThreshold != 1 {
+		t.Errorf("api target not loaded correctly: %+v", api)
+	}
+	if api.headers["Authorization"] != "Bearer x" || api.labels["team"] != "payments" || api.labels["env"] != "prod" {
+		t.Errorf("api headers/labels not merged: %v %v", api.headers, api.labels)
+	}
+	if len(api.recipients) != 1 || api.recipients[0] != "payments@example.com" {
+		t.Errorf("api recipients not loaded: %v", api.recipients)
+	}
+	www := cfg.targets[1]
+	if www.name != "https://www.example.com" || www.method != "GET" || www.timeout != 5*time.Second || www.interval != 2*time.Minute || www.alertThreshold != 3 {
+		t.Errorf("www target defaults not applied: %+v", www)
+	}
+}
+
+func TestLoadConfigJSONFile(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{
+  "defaults": {"interval": "15s"},
+  "targets": [{"name": "shop", "url": "https://shop.example.com", "labels": {"team": "web"}}]
+}`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "CHECK_INTERVAL": "", "ALERT_THRESHOLD": ""})
+	defer cleanup()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.targets) != 1 || cfg.targets[0].name != "shop" || cfg.targets[0].interval != 15*time.Second {
+		t.Errorf("JSON targets not loaded correctly: %+v", cfg.targets)
+	}
+	if cfg.targets[0].alertThreshold != defaultAlertThreshold || cfg.targets[0].timeout != defaultRequestTimeout {
+		t.Errorf("JSON target defaults not applied: %+v", cfg.targets[0])
+	}
+}
+
+func TestLoadConfigFileInvalidDuration(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+targets:
+  - url: https://a.com
+    timeout: soon
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path})
+	defer cleanup()
+
+	if _, err := loadConfig(); err == nil {
+		t.Error("expected error for invalid timeout")
 	}
 }
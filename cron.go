@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed five-field cron expression
+// (minute hour day-of-month month day-of-week).
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64 // Bit sets of allowed values
+	domAny, dowAny                bool   // Field was "*"
+}
+
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// parseCron parses expressions like "0 22 * * 6" (Saturdays at 22:00).
+// Fields support *, lists, ranges and steps; Sunday is 0 or 7.
+func parseCron(expr string) (cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return cronSchedule{}, fmt.Errorf("expected 5 fields (minute hour day-of-month month day-of-week), got %d", len(fields))
+	}
+	var sets [5]uint64
+	for i, f := range fields {
+		set, err := parseCronField(f, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return cronSchedule{}, fmt.Errorf("%s: %w", cronFields[i].name, err)
+		}
+		sets[i] = set
+	}
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1 // 7 is Sunday as well
+	}
+	return cronSchedule{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domAny: fields[2] == "*", dowAny: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			rng, step = part[:i], n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			var err error
+			before, after, isRange := strings.Cut(rng, "-")
+			if lo, err = strconv.Atoi(before); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(after); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// matches reports whether the schedule fires in the minute of t, in t's
+// location. Like cron, restricted day-of-month and day-of-week fields match
+// if either does.
+func (c cronSchedule) matches(t time.Time) bool {
+	return c.minute&(1<<t.Minute()) != 0 && c.hour&(1<<t.Hour()) != 0 && c.matchesDay(t)
+}
+
+// matchesDay reports whether the schedule fires on the day of t.
+func (c cronSchedule) matchesDay(t time.Time) bool {
+	if c.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// activeWindow returns the start of the window of length d that contains
+// now, if the schedule fired within the last d. It steps back over days and
+// hours the schedule doesn't fire in, so long windows are cheap to check.
+func (c cronSchedule) activeWindow(now time.Time, d time.Duration, loc *time.Location) (time.Time, bool) {
+	now = now.In(loc)
+	for t := now.Truncate(time.Minute); now.Sub(t) < d; {
+		// Minutes since the start of the hour, so that the repeated hour at
+		// the end of daylight saving time is stepped over once per occurrence.
+		intoHour := time.Duration(t.Minute()) * time.Minute
+		if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc).Add(-time.Minute)
+			continue
+		}
+		if c.hour&(1<<t.Hour()) == 0 {
+			t = t.Add(-intoHour - time.Minute)
+			continue
+		}
+		earlier := c.minute & (2<<t.Minute() - 1)
+		if earlier == 0 {
+			t = t.Add(-intoHour - time.Minute)
+			continue
+		}
+		t = t.Add(-intoHour + time.Duration(bits.Len64(earlier)-1)*time.Minute)
+		if now.Sub(t) < d {
+			return t, true
+		}
+		break
+	}
+	return time.Time{}, false
+}
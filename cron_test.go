@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	cases := []struct {
+		expr string
+		at   time.Time
+		want bool
+	}{
+		{"0 22 * * 6", time.Date(2024, 6, 1, 22, 0, 0, 0, time.UTC), true}, // Saturday
+		{"0 22 * * 6", time.Date(2024, 6, 2, 22, 0, 0, 0, time.UTC), false},
+		{"0 22 * * 6", time.Date(2024, 6, 1, 22, 1, 0, 0, time.UTC), false},
+		{"*/15 * * * *", time.Date(2024, 6, 1, 3, 45, 0, 0, time.UTC), true},
+		{"*/15 * * * *", time.Date(2024, 6, 1, 3, 46, 0, 0, time.UTC), false},
+		{"30 2 1-7 * 0", time.Date(2024, 6, 2, 2, 30, 0, 0, time.UTC), true}, // Sunday, day 2
+		{"30 2 1 * 7", time.Date(2024, 6, 9, 2, 30, 0, 0, time.UTC), true},   // Sunday as 7, either day field matches
+		{"0 9-17/4 * 1,7 1-5", time.Date(2024, 7, 1, 13, 0, 0, 0, time.UTC), true},
+		{"0 9-17/4 * 1,7 1-5", time.Date(2024, 7, 1, 11, 0, 0, 0, time.UTC), false},
+	}
+	for _, c := range cases {
+		schedule, err := parseCron(c.expr)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.expr, err)
+		}
+		if got := schedule.matches(c.at); got != c.want {
+			t.Errorf("%s at %v: got %v, want %v", c.expr, c.at, got, c.want)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("expected an error for %q", expr)
+		}
+	}
+}
+
+func TestCronActiveWindowTimezone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("timezone data not available: %v", err)
+	}
+	schedule, _ := parseCron("0 22 * * 6") // Saturdays 22:00 Berlin time
+	start := time.Date(2024, 6, 1, 20, 0, 0, 0, time.UTC)
+
+	if got, ok := schedule.activeWindow(start.Add(90*time.Minute), 2*time.Hour, berlin); !ok || !got.Equal(start) {
+		t.Errorf("expected the window starting at %v, got %v %v", start, got, ok)
+	}
+	if _, ok := schedule.activeWindow(start.Add(2*time.Hour), 2*time.Hour, berlin); ok {
+		t.Error("window should end after its duration")
+	}
+	if _, ok := schedule.activeWindow(start, 2*time.Hour, time.UTC); ok {
+		t.Error("schedule should be evaluated in its timezone")
+	}
+}
+
+func TestCronActiveWindowMatchesMinuteScan(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("timezone data not available: %v", err)
+	}
+	// scan is the reference: the most recent matching minute within d.
+	scan := func(c cronSchedule, now time.Time, d time.Duration) (time.Time, bool) {
+		now = now.In(berlin)
+		for t := now.Truncate(time.Minute); now.Sub(t) < d; t = t.Add(-time.Minute) {
+			if c.matches(t) {
+				return t, true
+			}
+		}
+		return time.Time{}, false
+	}
+	for _, expr := range []string{"0 22 * * 6", "*/20 2 * * *", "30 2 1-7 * 0", "15,45 1-3 * 3,10 *", "0 0 1 * *"} {
+		schedule, err := parseCron(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range []time.Duration{time.Hour, 26 * time.Hour, 8 * 24 * time.Hour} {
+			// Spans the daylight saving time changes of 2024.
+			for _, from := range []time.Time{time.Date(2024, 3, 28, 0, 0, 0, 0, time.UTC), time.Date(2024, 10, 24, 0, 0, 0, 0, time.UTC)} {
+				for now := from.Add(30 * time.Second); now.Before(from.AddDate(0, 0, 8)); now = now.Add(97 * time.Minute) {
+					got, ok := schedule.activeWindow(now, d, berlin)
+					want, wantOK := scan(schedule, now, d)
+					if ok != wantOK || !got.Equal(want) {
+						t.Fatalf("%s at %v within %v: got %v %v, want %v %v", expr, now, d, got, ok, want, wantOK)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultDegradedThreshold = 2
+
+// Values of the site_state metric.
+const (
+	siteStateUp       = 0
+	siteStateDegraded = 1
+	siteStateDown     = 2
+)
+
+// Severities of DEGRADED events: the latest check was above the warning or
+// the critical latency.
+const (
+	severityWarning  = "warning"
+	severityCritical = "critical"
+)
+
+// latencyState tracks the slow checks of a target with a latency budget.
+type latencyState struct {
+	slow     int // Consecutive checks above the warning or critical latency
+	degraded bool
+	severity string // Severity of the latest DEGRADED notification
+	muted    bool   // The DEGRADED notification was muted by a silence
+	since    time.Time
+	reason   string // Reason of the latest slow check
+}
+
+// latencyReason describes a check that took longer than its budget.
+func latencyReason(latency, budget time.Duration, level string) string {
+	return fmt.Sprintf("responded in %v, above the %s latency of %v", latency.Round(time.Millisecond), level, budget)
+}
+
+// degradedThresholdFor returns the number of consecutive slow checks after
+// which t is degraded.
+func (s *Service) degradedThresholdFor(t target) int {
+	if t.degradedThreshold > 0 {
+		return t.degradedThreshold
+	}
+	return max(s.config.degradedThreshold, 1)
+}
+
+// latencySeverity returns the severity of a check of t that took latency
+// and the budget it exceeded, or "" if it was within the budgets.
+func latencySeverity(t target, latency time.Duration) (string, time.Duration) {
+	switch {
+	case t.latencyCritical > 0 && latency > t.latencyCritical:
+		return severityCritical, t.latencyCritical
+	case t.latencyWarning > 0 && latency > t.latencyWarning:
+		return severityWarning, t.latencyWarning
+	}
+	return "", 0
+}
+
+// handleLatency tracks the latency of a successful check of t against its
+// budgets. It notifies when t becomes degraded, when a degradation turns
+// critical and when t is fast again.
+func (s *Service) handleLatency(t target, latency time.Duration) {
+	url := t.url
+	silenced := s.isSilenced(t, time.Now())
+
+	s.mu.Lock()
+	st := s.latency[url]
+	if st == nil {
+		st = &latencyState{}
+		s.latency[url] = st
+	}
+	severity, budget := latencySeverity(t, latency)
+	slow := severity != ""
+	if slow {
+		st.slow++
+		st.reason = latencyReason(latency, budget, severity)
+	} else {
+		st.slow = 0
+	}
+	due := slow && !s.offlineMap[url] && st.slow >= s.degradedThresholdFor(t)
+	started := due && !st.degraded
+	escalated := due && st.degraded && severity == severityCritical && st.severity != severityCritical
+	ended := !slow && st.degraded
+	since, reason, slowChecks := st.since, st.reason, st.slow
+	muted := st.muted || silenced || s.isFlappingLocked(url)
+	switch {
+	case started:
+		// The target has been slow since the first check of the streak.
+		st.degraded, st.severity, st.muted, st.since = true, severity, muted, time.Now().Add(-time.Duration(st.slow-1)*t.interval)
+		since = st.since
+	case escalated:
+		// A silence that muted the start doesn't mute the escalation once it's over.
+		muted = silenced || s.isFlappingLocked(url)
+		st.severity, st.muted = severity, st.muted && muted
+	case ended:
+		delete(s.latency, url)
+	}
+	s.updateStateLocked(url)
+	s.mu.Unlock()
+
+	switch {
+	case (started || escalated) && muted:
+		log.Printf("Silenced DEGRADED (%s) notification for %s", severity, url)
+	case started || escalated:
+		ev := s.newAlertEvent(t, StateDegraded, reason)
+		ev.Since, ev.SlowChecks, ev.Severity = since, slowChecks, severity
+		s.dispatch(ev)
+	case ended && muted:
+		log.Printf("Silenced notification for %s responding normally again", url)
+	case ended:
+		ev := s.newAlertEvent(t, StateUp, reason)
+		ev.From, ev.Since = StateDegraded, since
+		s.dispatch(ev)
+	}
+}
+
+// resetLatencyLocked ends the slow streak of url after a failed check. A
+// target that is down is no longer degraded. Callers must hold s.mu.
+func (s *Service) resetLatencyLocked(url string) {
+	st := s.latency[url]
+	if st == nil {
+		return
+	}
+	st.slow = 0
+	if s.offlineMap[url] {
+		delete(s.latency, url)
+	}
+}
+
+// updateStateLocked sets site_state for url. Callers must hold s.mu.
+func (s *Service) updateStateLocked(url string) {
+	state := siteStateUp
+	if s.offlineMap[url] {
+		state = siteStateDown
+	} else if st := s.latency[url]; st != nil && st.degraded {
+		state = siteStateDegraded
+	}
+	s.metrics.state.With(prometheus.Labels{"url": url}).Set(float64(state))
+}
+
+// degradedTitle is the headline of DEGRADED events.
+func degradedTitle(ev AlertEvent) string {
+	if ev.Severity == severityCritical {
+		return fmt.Sprintf("🐢 DEGRADED (critical): %s", ev.Name)
+	}
+	return fmt.Sprintf("🐢 DEGRADED: %s", ev.Name)
+}
+
+// degradedSummary describes the latency of a DEGRADED event.
+func degradedSummary(ev AlertEvent) string {
+	return fmt.Sprintf("%s %s (%d slow checks in a row)", ev.URL, ev.Reason, ev.SlowChecks)
+}
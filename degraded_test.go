@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var checkoutTarget = target{name: "checkout", url: "https://shop.com/checkout", interval: time.Minute,
+	latencyWarning: 800 * time.Millisecond, latencyCritical: 3 * time.Second, degradedThreshold: 2}
+
+func TestDegradedState(t *testing.T) {
+	tgt := checkoutTarget
+	s, rec := newRecordingService(tgt)
+	state := s.metrics.state.With(prometheus.Labels{"url": tgt.url})
+
+	s.handleSiteRecovery(tgt)
+	s.handleLatency(tgt, 1200*time.Millisecond)
+	s.handleLatency(tgt, 300*time.Millisecond)
+	s.handleLatency(tgt, 1200*time.Millisecond)
+	if got := rec.received(); len(got) != 0 {
+		t.Fatalf("a slow check below the degraded threshold should not notify, got %+v", got)
+	}
+
+	s.handleLatency(tgt, 1500*time.Millisecond)
+	got := rec.received()
+	if len(got) != 1 || got[0].State != StateDegraded || got[0].SlowChecks != 2 || got[0].Severity != severityWarning {
+		t.Fatalf("expected a DEGRADED event after 2 slow checks, got %+v", got)
+	}
+	ev := got[0]
+	if ev.Reason != "responded in 1.5s, above the warning latency of 800ms" || ev.Since.IsZero() {
+		t.Errorf("unexpected DEGRADED event: %+v", ev)
+	}
+	if v := testutil.ToFloat64(state); v != siteStateDegraded {
+		t.Errorf("expected site_state %d, got %v", siteStateDegraded, v)
+	}
+	if title, body := plainMessage(ev); title != "🐢 DEGRADED: checkout" || !strings.Contains(body, "2 slow checks in a row") {
+		t.Errorf("unexpected DEGRADED message: %q %q", title, body)
+	}
+	if subject, text, _, err := (alertTemplates{}).render(ev); err != nil || !strings.HasPrefix(subject, "[🐢 DEGRADED] https://shop.com/checkout") || !strings.Contains(text, "slow checks in a row") {
+		t.Errorf("unexpected DEGRADED email: %q %q %v", subject, text, err)
+	}
+
+	s.handleLatency(tgt, 2*time.Second)
+	if len(rec.received()) != 1 {
+		t.Fatal("DEGRADED should be notified once")
+	}
+
+	s.handleLatency(tgt, 200*time.Millisecond)
+	got = rec.received()
+	if len(got) != 2 || got[1].State != StateUp || got[1].From != StateDegraded || !got[1].Since.Equal(ev.Since) {
+		t.Fatalf("expected an UP event ending the degradation, got %+v", got)
+	}
+	if title, _ := plainMessage(got[1]); title != "✅ UP: checkout is responding normally again" {
+		t.Errorf("unexpected title: %q", title)
+	}
+	if subject, _, _, _ := (alertTemplates{}).render(got[1]); subject != "[✅ UP] https://shop.com/checkout is responding normally again" {
+		t.Errorf("unexpected subject: %q", subject)
+	}
+	if v := testutil.ToFloat64(state); v != siteStateUp {
+		t.Errorf("expected site_state %d, got %v", siteStateUp, v)
+	}
+}
+
+func TestDegradedSupersededByDown(t *testing.T) {
+	tgt := checkoutTarget
+	s, rec := newRecordingService(tgt)
+	state := s.metrics.state.With(prometheus.Labels{"url": tgt.url})
+
+	for range 2 {
+		s.handleSiteRecovery(tgt)
+		s.handleLatency(tgt, time.Second)
+	}
+	s.handleSiteError(tgt, "returned status 500")
+	if v := testutil.ToFloat64(state); v != siteStateDown {
+		t.Errorf("expected site_state %d, got %v", siteStateDown, v)
+	}
+	if len(s.latency) != 0 {
+		t.Error("a down target should no longer be degraded")
+	}
+
+	s.handleSiteRecovery(tgt)
+	s.handleLatency(tgt, 100*time.Millisecond)
+	got := rec.received()
+	if len(got) != 3 || got[1].State != StateDown || got[2].State != StateUp || got[2].From != "" {
+		t.Fatalf("expected DEGRADED, DOWN and a regular recovery, got %+v", got)
+	}
+	if v := testutil.ToFloat64(state); v != siteStateUp {
+		t.Errorf("expected site_state %d, got %v", siteStateUp, v)
+	}
+}
+
+func TestDegradedSilenced(t *testing.T) {
+	tgt := checkoutTarget
+	s, rec := newRecordingService(tgt)
+	s.silences = newTestSilenceStore(t)
+	window := Silence{ID: "deploy", Targets: []string{"checkout"}, Start: time.Now().Add(-time.Minute), End: time.Now().Add(time.Hour)}
+	if err := window.compile(); err != nil {
+		t.Fatal(err)
+	}
+	s.silences.setStatic([]Silence{window})
+
+	for range 2 {
+		s.handleLatency(tgt, time.Second)
+	}
+	s.silences.setStatic(nil)
+	s.handleLatency(tgt, 100*time.Millisecond)
+	if got := rec.received(); len(got) != 0 {
+		t.Errorf("a muted degradation should not be notified when it ends, got %+v", got)
+	}
+}
+
+func TestDegradedCritical(t *testing.T) {
+	tgt := checkoutTarget
+	s, rec := newRecordingService(tgt)
+
+	s.handleLatency(tgt, time.Second)
+	s.handleLatency(tgt, 1500*time.Millisecond)
+	s.handleLatency(tgt, 4*time.Second)
+	got := rec.received()
+	if len(got) != 2 || got[0].Severity != severityWarning || got[1].State != StateDegraded || got[1].Severity != severityCritical {
+		t.Fatalf("expected a warning and then a critical DEGRADED event, got %+v", got)
+	}
+	ev := got[1]
+	if ev.Reason != "responded in 4s, above the critical latency of 3s" || !ev.Since.Equal(got[0].Since) || ev.SlowChecks != 3 {
+		t.Errorf("unexpected critical DEGRADED event: %+v", ev)
+	}
+	if title, _ := plainMessage(ev); title != "🐢 DEGRADED (critical): checkout" {
+		t.Errorf("unexpected title: %q", title)
+	}
+	if subject, _, _, _ := (alertTemplates{}).render(ev); !strings.HasPrefix(subject, "[🐢 DEGRADED (critical)] https://shop.com/checkout") {
+		t.Errorf("unexpected subject: %q", subject)
+	}
+
+	s.handleLatency(tgt, time.Second)
+	s.handleLatency(tgt, 5*time.Second)
+	if len(rec.received()) != 2 {
+		t.Error("a critical degradation should be notified once")
+	}
+	s.handleLatency(tgt, 100*time.Millisecond)
+	if got := rec.received(); len(got) != 3 || got[2].State != StateUp || got[2].From != StateDegraded {
+		t.Errorf("expected an UP event ending the degradation, got %+v", got)
+	}
+}
+
+func TestCheckSiteStatusCriticalLatency(t *testing.T) {
+	tgt := checkoutTarget
+	s, rec := newRecordingService(tgt)
+	tgt.latencyWarning, tgt.latencyCritical = 0, time.Millisecond
+	client := &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		time.Sleep(5 * time.Millisecond)
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	})}
+	for range 2 {
+		s.checkSiteStatus(tgt, client)
+	}
+
+	got := rec.received()
+	if len(got) != 1 || got[0].State != StateDegraded || got[0].Severity != severityCritical || !strings.Contains(got[0].Reason, "above the critical latency of 1ms") {
+		t.Fatalf("expected a critical DEGRADED event for checks above the critical latency, got %+v", got)
+	}
+	if h := got[0].History; len(h) != 2 || !h[0].OK || h[0].StatusCode != 200 {
+		t.Errorf("expected successful checks with status 200, got %+v", h)
+	}
+	if s.offlineMap[tgt.url] || s.failureCount[tgt.url] != 0 {
+		t.Error("a slow check must not count as a failure")
+	}
+	if v := testutil.ToFloat64(s.metrics.state.With(prometheus.Labels{"url": tgt.url})); v != siteStateDegraded {
+		t.Errorf("expected site_state %d, got %v", siteStateDegraded, v)
+	}
+
+	failing := &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})}
+	s.checkSiteStatus(tgt, failing)
+	if got := rec.received(); len(got) != 2 || got[1].State != StateDown {
+		t.Errorf("a real failure should still be DOWN, got %+v", got)
+	}
+}
+
+func TestLoadConfigLatencyBudgets(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+defaults:
+  latency_warning: 500ms
+  degraded_threshold: 3
+targets:
+  - name: checkout
+    url: https://shop.example.com/checkout
+    latency_warning: 800ms
+    latency_critical: 2s
+  - name: api
+    url: https://api.example.com
+    latency_critical: 400ms
+    degraded_threshold: -1
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "CHECK_INTERVAL": "", "ALERT_THRESHOLD": ""})
+	defer cleanup()
+
+	_, err := loadConfig()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		"targets[1].latency_critical: must be above the warning latency 500ms, got 400ms",
+		"targets[1].degraded_threshold: must be at least 1, got -1",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in %v", want, err)
+		}
+	}
+
+	errs := &configErrors{}
+	cfg := configFromEnv(envLookup{}, errs)
+	fc, _ := readConfigFile(path, errs)
+	cfg.applyFile(fc, errs)
+	checkout, api := cfg.targets[0], cfg.targets[1]
+	if checkout.latencyWarning != 800*time.Millisecond || checkout.latencyCritical != 2*time.Second || checkout.degradedThreshold != 3 {
+		t.Errorf("checkout budgets not loaded: %+v", checkout)
+	}
+	if api.latencyWarning != 500*time.Millisecond || api.latencyCritical != 400*time.Millisecond {
+		t.Errorf("api should use the default warning latency: %+v", api)
+	}
+}
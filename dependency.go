@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// findTarget returns the target whose name or URL is ref.
+func findTarget(targets []target, ref string) (target, bool) {
+	for _, t := range targets {
+		if t.name == ref || t.url == ref {
+			return t, true
+		}
+	}
+	return target{}, false
+}
+
+// validateDependencies checks that parents exist and don't form cycles.
+func validateDependencies(targets []target, errs *configErrors) {
+	for i, t := range targets {
+		if t.parent == "" {
+			continue
+		}
+		field := fmt.Sprintf("targets[%d].parent", i)
+		parent, ok := findTarget(targets, t.parent)
+		switch {
+		case !ok:
+			errs.add(field, "unknown target %q", t.parent)
+		case parent.url == t.url:
+			errs.add(field, "a target cannot be its own parent")
+		default:
+			seen := map[string]bool{t.url: true}
+			for p, ok := parent, true; ok && p.parent != ""; p, ok = findTarget(targets, p.parent) {
+				if seen[p.url] {
+					errs.add(field, "dependency cycle through %q", p.name)
+					break
+				}
+				seen[p.url] = true
+			}
+		}
+	}
+}
+
+// failingAncestorLocked returns the name of the topmost ancestor of t that
+// is offline or failing its checks, or "" if all of them are healthy.
+// Callers must hold s.mu.
+func (s *Service) failingAncestorLocked(t target) string {
+	failing := ""
+	seen := map[string]bool{t.url: true}
+	for ref := t.parent; ref != ""; {
+		p, ok := findTarget(s.config.targets, ref)
+		if !ok || seen[p.url] {
+			break
+		}
+		seen[p.url] = true
+		if s.offlineMap[p.url] || s.lastCheckFailedLocked(p.url) {
+			failing = p.name
+		}
+		ref = p.parent
+	}
+	return failing
+}
+
+// suppressLocked marks t as unreachable due to parent instead of alerting.
+// Callers must hold s.mu.
+func (s *Service) suppressLocked(t target, parent string) {
+	if s.suppressed[t.url] == parent {
+		return
+	}
+	log.Printf("Suppressing DOWN alert for %s: unreachable due to parent %s", t.url, parent)
+	s.clearSuppressionLocked(t.url)
+	s.suppressed[t.url] = parent
+	s.metrics.unreachable.With(prometheus.Labels{"url": t.url, "parent": parent}).Set(1)
+}
+
+// clearSuppressionLocked forgets that url was unreachable due to its
+// parent. Callers must hold s.mu.
+func (s *Service) clearSuppressionLocked(url string) {
+	if parent, ok := s.suppressed[url]; ok {
+		s.metrics.unreachable.Delete(prometheus.Labels{"url": url, "parent": parent})
+		delete(s.suppressed, url)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var (
+	vpnTarget = target{name: "vpn", url: "https://vpn.example.com"}
+	appTarget = target{name: "app", url: "https://app.example.com", parent: "vpn"}
+)
+
+func TestChildAlertSuppressedWhileParentDown(t *testing.T) {
+	vpn, app := vpnTarget, appTarget
+	s, rec := newRecordingService(vpn, app)
+
+	s.handleSiteError(vpn, "unreachable: timeout")
+	s.handleSiteError(app, "unreachable: timeout")
+	s.handleSiteError(app, "unreachable: timeout")
+	if got := rec.received(); len(got) != 1 || got[0].Name != "vpn" {
+		t.Fatalf("expected only the parent alert, got %+v", got)
+	}
+	if !s.offlineMap[app.url] {
+		t.Error("suppressed child should still be tracked as offline")
+	}
+	gauge := s.metrics.unreachable.With(prometheus.Labels{"url": app.url, "parent": "vpn"})
+	if got := testutil.ToFloat64(gauge); got != 1 {
+		t.Errorf("expected site_unreachable_due_to_parent 1, got %v", got)
+	}
+
+	s.handleSiteRecovery(app)
+	got := rec.received()
+	if len(got) != 2 || got[1].Name != "app" || got[1].State != StateUp || got[1].Parent != "vpn" {
+		t.Fatalf("recovery notice should be marked unreachable due to parent, got %+v", got)
+	}
+	if _, body := plainMessage(got[1]); !strings.Contains(body, "unreachable due to parent vpn") {
+		t.Errorf("recovery message should mention the parent: %q", body)
+	}
+	if testutil.CollectAndCount(s.metrics.unreachable) != 0 {
+		t.Error("unreachable series should be deleted on recovery")
+	}
+}
+
+func TestChildAlertsOnceParentRecovers(t *testing.T) {
+	vpn, app := vpnTarget, appTarget
+	s, rec := newRecordingService(vpn, app)
+
+	s.handleSiteError(vpn, "unreachable: timeout")
+	s.handleSiteError(app, "returned status 502")
+	s.handleSiteRecovery(vpn)
+	s.handleSiteError(app, "returned status 502")
+
+	got := rec.received()
+	if len(got) != 3 || got[2].Name != "app" || got[2].State != StateDown || got[2].Parent != "" {
+		t.Fatalf("child should alert once its parent is healthy, got %+v", got)
+	}
+}
+
+func TestChildSuppressedWhileParentFailingBelowThreshold(t *testing.T) {
+	vpn, app := vpnTarget, appTarget
+	s, rec := newRecordingService(vpn, app)
+	vpn.alertThreshold = 3
+	s.config.targets[0] = vpn
+
+	s.handleSiteError(vpn, "unreachable: timeout")
+	s.handleSiteError(app, "unreachable: timeout")
+	if len(rec.received()) != 0 {
+		t.Error("child should not alert while its parent is failing")
+	}
+}
+
+func TestGrandparentSuppressesChildren(t *testing.T) {
+	vpn, app := vpnTarget, appTarget
+	s, rec := newRecordingService(vpn, app)
+	lb := target{name: "lb", url: "https://lb.example.com", parent: "https://vpn.example.com"}
+	app.parent = "lb"
+	s.config.targets = []target{vpn, lb, app}
+
+	s.handleSiteError(vpn, "unreachable: timeout")
+	s.handleSiteError(lb, "unreachable: timeout")
+	s.handleSiteError(app, "unreachable: timeout")
+	if got := rec.received(); len(got) != 1 || s.suppressed[app.url] != "vpn" || s.suppressed[lb.url] != "vpn" {
+		t.Errorf("only the root should alert, got %+v suppressed %v", got, s.suppressed)
+	}
+}
+
+func TestValidateDependencies(t *testing.T) {
+	targets := []target{
+		{name: "a", url: "https://a.com", parent: "b"},
+		{name: "b", url: "https://b.com", parent: "https://a.com"},
+		{name: "c", url: "https://c.com", parent: "c"},
+		{name: "d", url: "https://d.com", parent: "nope"},
+		{name: "e", url: "https://e.com", parent: "a"},
+	}
+	errs := &configErrors{}
+	validateDependencies(targets, errs)
+	msg := errs.Error()
+	for _, want := range []string{
+		`targets[0].parent: dependency cycle`,
+		`targets[1].parent: dependency cycle`,
+		`targets[2].parent: a target cannot be its own parent`,
+		`targets[3].parent: unknown target "nope"`,
+		`targets[4].parent: dependency cycle`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("missing %q in %s", want, msg)
+		}
+	}
+}
+
+func TestLoadConfigParent(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+targets:
+  - name: vpn
+    url: https://vpn.example.com
+  - name: app
+    url: https://app.example.com
+    parent: vpn
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "CHECK_INTERVAL": "", "ALERT_THRESHOLD": ""})
+	defer cleanup()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.targets[1].parent != "vpn" {
+		t.Errorf("parent not loaded: %+v", cfg.targets[1])
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	discordColorDown     = 0xE01E5A
+	discordColorUp       = 0x2EB67D
+	discordColorFlapping = 0xECB22E
+	discordColorDegraded = 0xF2952F
+)
+
+// discordConfig holds the settings of a Discord webhook notifier.
+type discordConfig struct {
+	webhookURL string
+	username   string
+	avatarURL  string
+}
+
+type fileDiscord struct {
+	WebhookURL string `yaml:"webhook_url" json:"webhook_url"`
+	Username   string `yaml:"username" json:"username"`
+	AvatarURL  string `yaml:"avatar_url" json:"avatar_url"`
+}
+
+func parseDiscord(f fileDiscord) discordConfig {
+	return discordConfig{webhookURL: f.WebhookURL, username: f.Username, avatarURL: f.AvatarURL}
+}
+
+// discordNotifier posts embeds to a Discord channel webhook.
+type discordNotifier struct {
+	cfg    discordConfig
+	client *http.Client
+}
+
+func newDiscordNotifier(nc notifierConfig) *discordNotifier {
+	return &discordNotifier{cfg: nc.discord, client: &http.Client{Timeout: nc.timeout}}
+}
+
+type discordMessage struct {
+	Content   string         `json:"content,omitempty"` // Mentions only notify in the content
+	Username  string         `json:"username,omitempty"`
+	AvatarURL string         `json:"avatar_url,omitempty"`
+	Embeds    []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	URL         string         `json:"url,omitempty"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+	Timestamp   string         `json:"timestamp"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+func (n *discordNotifier) Notify(ev AlertEvent) error {
+	return postJSON(n.client, n.cfg.webhookURL, nil, n.message(ev))
+}
+
+// NotifyDigest posts d as a single embed, colored as DOWN if it contains
+// any DOWN event.
+func (n *discordNotifier) NotifyDigest(d AlertDigest) error {
+	title, body := digestMessage(d)
+	embed := discordEmbed{
+		Title:       title,
+		Description: body,
+		Color:       discordColorDown,
+		Timestamp:   d.At.UTC().Format(time.RFC3339),
+	}
+	if d.Count(StateDown) == 0 {
+		embed.Color = discordColorUp
+	}
+	return postJSON(n.client, n.cfg.webhookURL, nil, discordMessage{Username: n.cfg.username, AvatarURL: n.cfg.avatarURL, Embeds: []discordEmbed{embed}})
+}
+
+// message builds an embed colored by the state of ev.
+func (n *discordNotifier) message(ev AlertEvent) discordMessage {
+	embed := discordEmbed{
+		URL:       ev.DashboardURL,
+		Timestamp: ev.At.UTC().Format(time.RFC3339),
+	}
+	switch ev.State {
+	case StateFlapping:
+		embed.Title = flapTitle(ev)
+		embed.Color = discordColorFlapping
+		embed.Fields = []discordField{
+			{Name: "URL", Value: ev.URL},
+			{Name: "State change", Value: fmt.Sprintf("%.0f%%", ev.FlapRate), Inline: true},
+			{Name: "Last failure", Value: ev.Reason, Inline: true},
+		}
+	case StateDegraded:
+		embed.Title = degradedTitle(ev)
+		embed.Color = discordColorDegraded
+		embed.Fields = []discordField{
+			{Name: "URL", Value: ev.URL},
+			{Name: "Latency", Value: ev.Reason, Inline: true},
+			{Name: "Slow checks", Value: fmt.Sprint(ev.SlowChecks), Inline: true},
+		}
+	case StateUp:
+		if ev.From == StateDegraded {
+			embed.Title = upTitle(ev)
+			embed.Color = discordColorUp
+			embed.Fields = []discordField{
+				{Name: "URL", Value: ev.URL},
+				{Name: "Degraded for", Value: ev.Downtime().String(), Inline: true},
+				{Name: "Last slow check", Value: ev.Reason, Inline: true},
+			}
+			break
+		}
+		embed.Title = upTitle(ev)
+		embed.Color = discordColorUp
+		embed.Fields = []discordField{
+			{Name: "URL", Value: ev.URL},
+			{Name: "Outage duration", Value: ev.Downtime().String(), Inline: true},
+			{Name: "Outage reason", Value: outageReason(ev), Inline: true},
+		}
+		if ev.AckedBy != "" {
+			embed.Fields = append(embed.Fields, discordField{Name: "Acknowledged by", Value: fmt.Sprintf("%s at %s", ev.AckedBy, ev.AckedAt.Format(time.RFC1123))})
+		}
+	default:
+		embed.Title = downTitle(ev)
+		embed.Color = discordColorDown
+		embed.Fields = []discordField{
+			{Name: "URL", Value: ev.URL},
+			{Name: "Reason", Value: ev.Reason, Inline: true},
+			{Name: "Failures", Value: fmt.Sprint(ev.FailureCount), Inline: true},
+		}
+		if ev.AckURL != "" {
+			embed.Fields = append(embed.Fields, discordField{Name: "Acknowledge", Value: ev.AckURL})
+		}
+	}
+	msg := discordMessage{Username: n.cfg.username, AvatarURL: n.cfg.avatarURL, Embeds: []discordEmbed{embed}}
+	if ev.State == StateDown && ev.OnCall != nil {
+		msg.Content = fmt.Sprintf("On call (level %d): %s", ev.Level, ev.OnCall.Name)
+		if ev.OnCall.Discord != "" {
+			msg.Content = fmt.Sprintf("On call (level %d): <@%s>", ev.Level, ev.OnCall.Discord)
+		}
+	}
+	return msg
+}
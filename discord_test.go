@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDiscordNotifierDown(t *testing.T) {
+	hook := newWebhookRecorder(t, http.StatusNoContent)
+	n := newDiscordNotifier(notifierConfig{timeout: time.Second, discord: discordConfig{webhookURL: hook.URL, username: "monitor"}})
+
+	if err := n.Notify(testEvent(StateDown)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var msg discordMessage
+	hook.next(t, &msg)
+	if msg.Username != "monitor" || len(msg.Embeds) != 1 {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+	embed := msg.Embeds[0]
+	if embed.Title != "🚨 DOWN: checkout" || embed.Color != discordColorDown || embed.URL != "https://grafana.example.com/d/abc" {
+		t.Errorf("unexpected embed: %+v", embed)
+	}
+	if embed.Fields[1].Name != "Reason" || embed.Fields[1].Value != "returned status 503" {
+		t.Errorf("expected failure reason field, got %+v", embed.Fields)
+	}
+	if embed.Timestamp != "2024-06-01T12:42:00Z" {
+		t.Errorf("unexpected timestamp %q", embed.Timestamp)
+	}
+}
+
+func TestDiscordNotifierUp(t *testing.T) {
+	n := newDiscordNotifier(notifierConfig{})
+	embed := n.message(testEvent(StateUp)).Embeds[0]
+	if embed.Title != "✅ UP: checkout" || embed.Color != discordColorUp {
+		t.Errorf("unexpected embed: %+v", embed)
+	}
+	if embed.Fields[1].Name != "Outage duration" || embed.Fields[1].Value != "42m0s" {
+		t.Errorf("expected outage duration field, got %+v", embed.Fields)
+	}
+}
@@ -1,50 +1,146 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
-
-	"net/smtp"
+	"net/mail"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/jordan-wright/email"
 )
 
+// emailMessage is a rendered alert email.
+type emailMessage struct {
+	to      []string
+	cc      []string
+	bcc     []string
+	subject string
+	text    string
+	html    string // Optional HTML alternative to text
+}
+
 type EmailSender interface {
-	Send(subject, body string) error
+	Send(msg emailMessage) error
 }
 
 type SMTPSender struct {
+	mu  sync.Mutex
 	cfg appConfig
 }
 
-func (s *SMTPSender) Send(subject, body string) error {
+// setConfig replaces the SMTP settings used for subsequent emails.
+func (s *SMTPSender) setConfig(cfg appConfig) {
+	s.mu.Lock()
+	s.cfg = cfg
+	s.mu.Unlock()
+}
+
+func (s *SMTPSender) Send(msg emailMessage) error {
+	s.mu.Lock()
+	cfg := s.cfg
+	s.mu.Unlock()
+
 	e := email.NewEmail()
-	e.From = s.cfg.smtpFrom
-	e.To = []string{s.cfg.smtpTo}
-	e.Subject = subject
-	e.Text = []byte(body)
+	e.From = cfg.smtpFrom
+	e.To = msg.to
+	e.Cc = msg.cc
+	e.Bcc = msg.bcc
+	e.Subject = msg.subject
+	e.Text = []byte(msg.text)
+	if msg.html != "" {
+		e.HTML = []byte(msg.html)
+	}
 
-	auth := smtp.PlainAuth("", s.cfg.smtpUser, s.cfg.smtpPass, s.cfg.smtpServer)
-	addr := fmt.Sprintf("%s:%s", s.cfg.smtpServer, s.cfg.smtpPort)
-	return e.Send(addr, auth)
+	body, err := e.Bytes()
+	if err != nil {
+		return fmt.Errorf("building email: %w", err)
+	}
+	// The envelope takes bare addresses, without display names.
+	from, err := envelopeAddress(cfg.smtpFrom)
+	if err != nil {
+		return fmt.Errorf("sender: %w", err)
+	}
+	var rcpts []string
+	for _, list := range [][]string{msg.to, msg.cc, msg.bcc} {
+		for _, a := range list {
+			rcpt, err := envelopeAddress(a)
+			if err != nil {
+				return fmt.Errorf("recipient: %w", err)
+			}
+			rcpts = append(rcpts, rcpt)
+		}
+	}
+	return deliverSMTP(cfg, from, rcpts, body)
 }
 
-func (s *Service) sendSiteDownAlert(url, reason string) {
-	subject := fmt.Sprintf("[🚨 DOWN] %s (%s)", url, reason)
-	log.Printf("Sending email: subject='%s' to='%s' (reason: %s)", subject, s.config.smtpTo, reason)
-	if err := s.emailSender.Send(subject, fmt.Sprintf("%s: %s", url, reason)); err != nil {
-		log.Printf("Failed to send email: subject='%s' to='%s': %v", subject, s.config.smtpTo, err)
-	} else {
-		log.Printf("Alert sent: %s - %s", url, reason)
+// envelopeAddress returns the address part of a, e.g. ops@example.com for
+// "Ops <ops@example.com>".
+func envelopeAddress(a string) (string, error) {
+	addr, err := mail.ParseAddress(a)
+	if err != nil {
+		return "", err
+	}
+	return addr.Address, nil
+}
+
+// recipientsFor returns the email recipients of alerts for t.
+func (s *Service) recipientsFor(t target) emailRecipients {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return resolveRecipients(s.config, t)
+}
+
+// emailNotifier sends alert events through the service's EmailSender.
+type emailNotifier struct {
+	svc *Service
+}
+
+func (n *emailNotifier) Notify(ev AlertEvent) error {
+	if ev.State != StateDown && ev.State != StateUp && ev.State != StateFlapping && ev.State != StateDegraded {
+		return fmt.Errorf("unsupported state %q", ev.State)
+	}
+	n.svc.mu.Lock()
+	templates := n.svc.config.templates
+	n.svc.mu.Unlock()
+	subject, text, html, err := templates.render(ev)
+	if err != nil {
+		return err
+	}
+
+	rcpt := n.svc.recipientsFor(ev.target)
+	if ev.OnCall != nil && ev.OnCall.Email != "" && !slices.Contains(rcpt.to, ev.OnCall.Email) {
+		rcpt.to = append(slices.Clone(rcpt.to), ev.OnCall.Email)
+	}
+	to := strings.Join(rcpt.to, ",")
+	log.Printf("Sending email: subject='%s' to='%s' cc='%s' bcc=%d (state: %s)", subject, to, strings.Join(rcpt.cc, ","), len(rcpt.bcc), ev.State)
+	msg := emailMessage{to: rcpt.to, cc: rcpt.cc, bcc: rcpt.bcc, subject: subject, text: text, html: html}
+	if err := n.svc.emailSender.Send(msg); err != nil {
+		return fmt.Errorf("sending email to '%s': %w", to, err)
 	}
+	log.Printf("Email sent: %s", subject)
+	return nil
 }
 
-func (s *Service) sendSiteRecoveryAlert(url string) {
-	subject := fmt.Sprintf("[✅ UP] %s is back online", url)
-	log.Printf("Sending email: subject='%s' to='%s' (reason: recovery)", subject, s.config.smtpTo)
-	if err := s.emailSender.Send(subject, fmt.Sprintf("%s is back online", url)); err != nil {
-		log.Printf("Failed to send email: subject='%s' to='%s': %v", subject, s.config.smtpTo, err)
-	} else {
-		log.Printf("Recovery alert sent: %s is back online", url)
+// NotifyDigest sends the digest as a plain-text email to each set of
+// recipients, listing only the events routed to them.
+func (n *emailNotifier) NotifyDigest(d AlertDigest) error {
+	parts := d.partition(func(ev AlertEvent) string {
+		rcpt := n.svc.recipientsFor(ev.target)
+		return strings.Join(rcpt.to, ",") + ";" + strings.Join(rcpt.cc, ",") + ";" + strings.Join(rcpt.bcc, ",")
+	})
+	var errs []error
+	for _, part := range parts {
+		rcpt := n.svc.recipientsFor(part.Events()[0].target)
+		subject, text := digestMessage(part)
+		to := strings.Join(rcpt.to, ",")
+		log.Printf("Sending digest email: subject='%s' to='%s' (%d alerts)", subject, to, len(part.Events()))
+		msg := emailMessage{to: rcpt.to, cc: rcpt.cc, bcc: rcpt.bcc, subject: subject, text: text}
+		if err := n.svc.emailSender.Send(msg); err != nil {
+			errs = append(errs, fmt.Errorf("sending digest email to '%s': %w", to, err))
+		}
 	}
+	return errors.Join(errs...)
 }
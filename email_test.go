@@ -28,14 +28,22 @@ func TestSendSiteRecoveryAlertSubject(t *testing.T) {
 // For a real project, use an interface for sending and mock it in tests.
 
 type mockSender struct {
+	lastTo      []string
+	lastCC      []string
+	lastBCC     []string
 	lastSubject string
 	lastBody    string
+	lastHTML    string
 	calls       int
 }
 
-func (m *mockSender) Send(subject, body string) error {
-	m.lastSubject = subject
-	m.lastBody = body
+func (m *mockSender) Send(msg emailMessage) error {
+	m.lastTo = msg.to
+	m.lastCC = msg.cc
+	m.lastBCC = msg.bcc
+	m.lastSubject = msg.subject
+	m.lastBody = msg.text
+	m.lastHTML = msg.html
 	m.calls++
 	return nil
 }
@@ -44,13 +52,13 @@ func TestServiceSendSiteDownAlert(t *testing.T) {
 	mock := &mockSender{}
 	service := &Service{
 		config: appConfig{
-			smtpTo: "to@example.com",
+			smtpTo: []string{"to@example.com"},
 		},
 		emailSender: mock,
 	}
 	url := "https://example.com"
 	reason := "returned status 500"
-	service.sendSiteDownAlert(url, reason)
+	service.sendSiteDownAlert(target{url: url}, reason)
 	expectedSubject := "[🚨 DOWN] https://example.com (returned status 500)"
 	expectedBody := "https://example.com: returned status 500"
 	if mock.lastSubject != expectedSubject {
@@ -68,12 +76,12 @@ func TestServiceSendSiteRecoveryAlert(t *testing.T) {
 	mock := &mockSender{}
 	service := &Service{
 		config: appConfig{
-			smtpTo: "to@example.com",
+			smtpTo: []string{"to@example.com"},
 		},
 		emailSender: mock,
 	}
 	url := "https://example.com"
-	service.sendSiteRecoveryAlert(url)
+	service.sendSiteRecoveryAlert(target{url: url})
 	expectedSubject := "[✅ UP] https://example.com is back online"
 	expectedBody := "https://example.com is back online"
 	if mock.lastSubject != expectedSubject {
@@ -86,3 +94,22 @@ func TestServiceSendSiteRecoveryAlert(t *testing.T) {
 		t.Errorf("expected 1 call, got %d", mock.calls)
 	}
 }
+
+func TestServiceSendSiteDownAlertTargetRecipients(t *testing.T) {
+	mock := &mockSender{}
+	service := &Service{
+		config: appConfig{
+			smtpTo: []string{"to@example.com"},
+		},
+		emailSender: mock,
+	}
+	service.sendSiteDownAlert(target{url: "https://example.com", recipients: []string{"team@example.com", "lead@example.com"}}, "returned status 500")
+	if len(mock.lastTo) != 2 || mock.lastTo[0] != "team@example.com" || mock.lastTo[1] != "lead@example.com" {
+		t.Errorf("expected target recipients, got %v", mock.lastTo)
+	}
+
+	service.sendSiteRecoveryAlert(target{url: "https://example.com"})
+	if len(mock.lastTo) != 1 || mock.lastTo[0] != "to@example.com" {
+		t.Errorf("expected fallback to SMTP_TO, got %v", mock.lastTo)
+	}
+}
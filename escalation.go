@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// escalationPolicy notifies its levels one after another while an outage
+// is neither recovered nor acknowledged.
+type escalationPolicy struct {
+	name   string
+	levels []escalationLevel
+}
+
+type escalationLevel struct {
+	after     time.Duration // Time after the DOWN alert until this level is notified
+	notifiers []string
+	oncall    string // Schedule whose on-call person is notified
+}
+
+type filePolicy struct {
+	Name   string      `yaml:"name" json:"name"`
+	Levels []fileLevel `yaml:"levels" json:"levels"`
+}
+
+type fileLevel struct {
+	After     string   `yaml:"after" json:"after"`
+	Notifiers []string `yaml:"notifiers" json:"notifiers"`
+	OnCall    string   `yaml:"oncall" json:"oncall"`
+}
+
+// escalationState tracks the level an outage has reached.
+type escalationState struct {
+	level     int // 1-based
+	alertedAt time.Time
+}
+
+// parsePolicies converts the escalation_policies section of the config file.
+func parsePolicies(fps []filePolicy, errs *configErrors) []escalationPolicy {
+	var policies []escalationPolicy
+	for i, fp := range fps {
+		p := escalationPolicy{name: fp.Name}
+		for j, fl := range fp.Levels {
+			l := escalationLevel{notifiers: fl.Notifiers, oncall: fl.OnCall}
+			if fl.After != "" {
+				errs.duration(fmt.Sprintf("escalation_policies[%d].levels[%d].after", i, j), fl.After, &l.after)
+			}
+			p.levels = append(p.levels, l)
+		}
+		policies = append(policies, p)
+	}
+	return policies
+}
+
+func validatePolicies(cfg appConfig, errs *configErrors) {
+	seen := make(map[string]bool)
+	for i, p := range cfg.policies {
+		field := fmt.Sprintf("escalation_policies[%d]", i)
+		if p.name == "" {
+			errs.add(field+".name", "required")
+		} else if seen[p.name] {
+			errs.add(field+".name", "duplicate escalation policy name %q", p.name)
+		}
+		seen[p.name] = true
+		if len(p.levels) == 0 {
+			errs.add(field+".levels", "at least one level required")
+		}
+		for j, l := range p.levels {
+			lf := fmt.Sprintf("%s.levels[%d]", field, j)
+			switch {
+			case j == 0 && l.after != 0:
+				errs.add(lf+".after", "the first level is notified right away and cannot have a delay")
+			case j > 0 && l.after <= p.levels[j-1].after:
+				errs.add(lf+".after", "must be later than the previous level")
+			}
+			if len(l.notifiers) == 0 {
+				errs.add(lf+".notifiers", "at least one notifier required")
+			}
+			for k, name := range l.notifiers {
+				if !slices.ContainsFunc(cfg.notifiers, func(nc notifierConfig) bool { return nc.name == name }) {
+					errs.add(fmt.Sprintf("%s.notifiers[%d]", lf, k), "unknown notifier %q", name)
+				}
+			}
+			if l.oncall != "" && cfg.schedule(l.oncall) == nil {
+				errs.add(lf+".oncall", "unknown on-call schedule %q", l.oncall)
+			}
+		}
+	}
+	for i, t := range cfg.targets {
+		if t.escalationPolicy != "" && cfg.policy(t.escalationPolicy) == nil {
+			errs.add(fmt.Sprintf("targets[%d].escalation_policy", i), "unknown escalation policy %q", t.escalationPolicy)
+		}
+	}
+}
+
+// policy returns the escalation policy with the given name, or nil.
+func (c appConfig) policy(name string) *escalationPolicy {
+	for i := range c.policies {
+		if c.policies[i].name == name {
+			return &c.policies[i]
+		}
+	}
+	return nil
+}
+
+// levelOf returns the first level (1-based) notifying the named notifier,
+// or 0 if the policy doesn't use it.
+func (p *escalationPolicy) levelOf(notifier string) int {
+	for i, l := range p.levels {
+		if slices.Contains(l.notifiers, notifier) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// admits reports whether the named notifier receives ev. Notifiers of a
+// level only hear about outages that reached it, and escalations only go to
+// the notifiers listed on the level escalated to. Notifiers outside the
+// policy receive everything.
+func (p *escalationPolicy) admits(notifier string, ev AlertEvent) bool {
+	if p == nil {
+		return !ev.levelOnly
+	}
+	if ev.levelOnly {
+		return ev.Level <= len(p.levels) && slices.Contains(p.levels[ev.Level-1].notifiers, notifier)
+	}
+	return p.levelOf(notifier) <= ev.Level
+}
+
+// startEscalationLocked puts the outage of t on the first level of its
+// policy when the DOWN alert is sent. Callers must hold s.mu.
+func (s *Service) startEscalationLocked(t target, now time.Time) {
+	if s.config.policy(t.escalationPolicy) == nil {
+		delete(s.escalations, t.url)
+		return
+	}
+	s.escalations[t.url] = escalationState{level: 1, alertedAt: now}
+}
+
+// escalationDueLocked returns the level the outage of t escalates to at
+// now, or 0 if no escalation is due. Acknowledged outages don't escalate.
+// Callers must hold s.mu.
+func (s *Service) escalationDueLocked(t target, now time.Time) int {
+	p := s.config.policy(t.escalationPolicy)
+	st, ok := s.escalations[t.url]
+	if p == nil || !ok || st.level >= len(p.levels) {
+		return 0
+	}
+	if _, acked := s.acked[t.url]; acked {
+		return 0
+	}
+	if now.Sub(st.alertedAt) < p.levels[st.level].after {
+		return 0
+	}
+	st.level++
+	s.escalations[t.url] = st
+	return st.level
+}
+
+// onCallLocked returns the person on call at now for the highest reached
+// level of p that has an on-call schedule. Callers must hold s.mu.
+func (s *Service) onCallLocked(p *escalationPolicy, level int, now time.Time) *OnCallPerson {
+	if p == nil {
+		return nil
+	}
+	for i := min(level, len(p.levels)) - 1; i >= 0; i-- {
+		sched := s.config.schedule(p.levels[i].oncall)
+		if sched == nil {
+			continue
+		}
+		name := sched.onCallAt(now)
+		person := s.config.people[name]
+		person.Name = name
+		return &person
+	}
+	return nil
+}
+
+// sendEscalation notifies the level the outage of t just escalated to.
+func (s *Service) sendEscalation(t target, reason string, level int) {
+	ev := s.newAlertEvent(t, StateDown, reason)
+	ev.levelOnly = true
+	if ev.OnCall != nil {
+		log.Printf("Escalating outage of %s to level %d (on call: %s)", t.url, level, ev.OnCall.Name)
+	} else {
+		log.Printf("Escalating outage of %s to level %d", t.url, level)
+	}
+	s.dispatch(ev)
+}
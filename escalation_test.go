@@ -0,0 +1,253 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// backdateEscalation moves the DOWN alert of url back by d.
+func backdateEscalation(s *Service, url string, d time.Duration) {
+	s.mu.Lock()
+	st := s.escalations[url]
+	st.alertedAt = st.alertedAt.Add(-d)
+	s.escalations[url] = st
+	s.mu.Unlock()
+}
+
+type escalationRecorders struct {
+	team, lead, pager, other *recordingNotifier
+}
+
+// newEscalationService returns a recording service whose target wiki
+// escalates from the team notifier to lead and pager. The "rec" notifier
+// is outside the policy.
+func newEscalationService() (*Service, escalationRecorders, target) {
+	tgt := target{name: "wiki", url: "https://wiki.internal", escalationPolicy: "internal"}
+	s, other := newRecordingService(tgt)
+	r := escalationRecorders{&recordingNotifier{}, &recordingNotifier{}, &recordingNotifier{}, other}
+	s.notifiers = append([]notifierEntry{
+		{name: "team", notifier: r.team},
+		{name: "lead", notifier: r.lead},
+		{name: "pager", notifier: r.pager},
+	}, s.notifiers...)
+	s.config.people = map[string]OnCallPerson{
+		"alice": {Name: "alice", Email: "alice@example.com", Slack: "U0ALICE"},
+	}
+	s.config.schedules = []onCallSchedule{{
+		name: "platform", location: time.UTC, handoffDay: time.Monday, handoffH: 9,
+		start: time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC), rotation: []string{"alice"},
+	}}
+	s.config.policies = []escalationPolicy{{name: "internal", levels: []escalationLevel{
+		{notifiers: []string{"team"}},
+		{after: 15 * time.Minute, notifiers: []string{"lead"}, oncall: "platform"},
+		{after: 30 * time.Minute, notifiers: []string{"pager"}},
+	}}}
+	return s, r, tgt
+}
+
+func TestEscalationPolicyLevels(t *testing.T) {
+	s, r, tgt := newEscalationService()
+
+	s.handleSiteError(tgt, "returned status 502")
+	if got := r.team.received(); len(got) != 1 || got[0].Level != 1 || got[0].OnCall != nil {
+		t.Fatalf("level 1 should be notified right away, got %+v", got)
+	}
+	if len(r.other.received()) != 1 {
+		t.Error("notifiers outside the policy should receive the DOWN alert")
+	}
+	if len(r.lead.received())+len(r.pager.received()) != 0 {
+		t.Fatal("later levels should not be notified before their delay")
+	}
+
+	s.handleSiteError(tgt, "returned status 502")
+	if len(r.lead.received()) != 0 {
+		t.Fatal("level 2 should wait for its delay")
+	}
+
+	backdateEscalation(s, tgt.url, 16*time.Minute)
+	s.handleSiteError(tgt, "returned status 502")
+	got := r.lead.received()
+	if len(got) != 1 || got[0].State != StateDown || got[0].Level != 2 || got[0].OnCall == nil || got[0].OnCall.Name != "alice" {
+		t.Fatalf("level 2 should be notified with the on-call person, got %+v", got)
+	}
+	if len(r.team.received()) != 1 || len(r.other.received()) != 1 || len(r.pager.received()) != 0 {
+		t.Error("the escalation should only go to the notifiers of level 2")
+	}
+	if _, body := plainMessage(got[0]); !strings.Contains(body, "On call (level 2): alice") {
+		t.Errorf("message should name the on-call person: %q", body)
+	}
+
+	backdateEscalation(s, tgt.url, 15*time.Minute)
+	s.handleSiteError(tgt, "returned status 502")
+	if got := r.pager.received(); len(got) != 1 || got[0].Level != 3 || got[0].OnCall.Name != "alice" {
+		t.Fatalf("level 3 should be notified, got %+v", got)
+	}
+
+	s.handleSiteRecovery(tgt)
+	for name, rec := range map[string]*recordingNotifier{"team": r.team, "lead": r.lead, "pager": r.pager, "other": r.other} {
+		if got := rec.received(); got[len(got)-1].State != StateUp {
+			t.Errorf("%s should receive the recovery, got %+v", name, got)
+		}
+	}
+	if _, ok := s.escalations[tgt.url]; ok {
+		t.Error("escalation state should be reset on recovery")
+	}
+}
+
+func TestEscalationStopsWhenAcknowledged(t *testing.T) {
+	s, r, tgt := newEscalationService()
+
+	s.handleSiteError(tgt, "returned status 502")
+	if _, err := s.acknowledge(tgt, "bob", "", 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	backdateEscalation(s, tgt.url, time.Hour)
+	s.handleSiteError(tgt, "returned status 502")
+	if len(r.lead.received())+len(r.pager.received()) != 0 {
+		t.Error("acknowledged outages should not escalate")
+	}
+
+	s.handleSiteRecovery(tgt)
+	if len(r.lead.received()) != 0 {
+		t.Error("levels that were not reached should not receive the recovery")
+	}
+	if got := r.team.received(); len(got) != 2 || got[1].AckedBy != "bob" {
+		t.Errorf("level 1 should receive the acknowledged recovery, got %+v", got)
+	}
+}
+
+func TestEscalationRepeatsNotifierOnLaterLevel(t *testing.T) {
+	s, r, tgt := newEscalationService()
+	s.config.policies[0].levels[1].notifiers = []string{"team", "lead"}
+
+	s.handleSiteError(tgt, "returned status 502")
+	backdateEscalation(s, tgt.url, 16*time.Minute)
+	s.handleSiteError(tgt, "returned status 502")
+	if got := r.team.received(); len(got) != 2 || got[1].Level != 2 || got[1].OnCall == nil {
+		t.Fatalf("a notifier listed again on level 2 should receive the escalation, got %+v", got)
+	}
+	if len(r.other.received()) != 1 {
+		t.Error("notifiers outside the policy should not receive escalations")
+	}
+}
+
+func TestEscalationOnCallEmailAndSlack(t *testing.T) {
+	s, _, tgt := newEscalationService()
+	mock := &mockSender{}
+	s.emailSender = mock
+	s.config.smtpTo = []string{"team@example.com"}
+
+	s.mu.Lock()
+	s.offlineMap[tgt.url] = true
+	s.escalations[tgt.url] = escalationState{level: 2, alertedAt: time.Now()}
+	s.mu.Unlock()
+	ev := s.newAlertEvent(tgt, StateDown, "returned status 502")
+
+	if err := (&emailNotifier{svc: s}).Notify(ev); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(mock.lastTo, ",") != "team@example.com,alice@example.com" {
+		t.Errorf("on-call person should be added to the recipients, got %v", mock.lastTo)
+	}
+	if !strings.Contains(mock.lastBody, "On call (level 2): alice") {
+		t.Errorf("email should name the on-call person: %q", mock.lastBody)
+	}
+
+	msg := newSlackNotifier(notifierConfig{slack: slackConfig{}}).message(ev)
+	found := false
+	for _, f := range msg.Blocks[1].Fields {
+		found = found || strings.Contains(f.Text, "<@U0ALICE>")
+	}
+	if !found {
+		t.Errorf("Slack message should mention the on-call person: %+v", msg.Blocks[1].Fields)
+	}
+}
+
+func TestValidatePolicies(t *testing.T) {
+	cfg := appConfig{
+		notifiers: []notifierConfig{{name: "team"}},
+		schedules: []onCallSchedule{{name: "platform"}},
+		policies: []escalationPolicy{
+			{name: "a", levels: []escalationLevel{
+				{after: time.Minute, notifiers: []string{"team"}},
+				{after: time.Minute, notifiers: []string{"nope"}, oncall: "missing"},
+				{after: time.Hour},
+			}},
+			{name: "a"},
+		},
+		targets: []target{{url: "https://a.com", escalationPolicy: "b"}},
+	}
+	errs := &configErrors{}
+	validatePolicies(cfg, errs)
+	msg := errs.Error()
+	for _, want := range []string{
+		`escalation_policies[0].levels[0].after: the first level is notified right away`,
+		`escalation_policies[0].levels[1].after: must be later than the previous level`,
+		`escalation_policies[0].levels[1].notifiers[0]: unknown notifier "nope"`,
+		`escalation_policies[0].levels[1].oncall: unknown on-call schedule "missing"`,
+		`escalation_policies[0].levels[2].notifiers: at least one notifier required`,
+		`escalation_policies[1].name: duplicate escalation policy name "a"`,
+		`escalation_policies[1].levels: at least one level required`,
+		`targets[0].escalation_policy: unknown escalation policy "b"`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("missing %q in %s", want, msg)
+		}
+	}
+}
+
+func TestLoadConfigEscalationPolicies(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+defaults:
+  escalation_policy: internal
+notifiers:
+  - name: team
+    type: webhook
+    webhook:
+      url: https://hooks.example.com/team
+  - name: lead
+    type: webhook
+    webhook:
+      url: https://hooks.example.com/lead
+escalation_policies:
+  - name: internal
+    levels:
+      - notifiers: [team]
+      - after: 15m
+        notifiers: [lead]
+        oncall: platform
+oncall:
+  schedules:
+    - name: platform
+      timezone: Europe/Berlin
+      handoff: monday 09:00
+      start: 2024-06-03
+      rotation: [alice, bob]
+  people:
+    alice:
+      email: alice@example.com
+    bob:
+      slack: U0BOB
+targets:
+  - name: wiki
+    url: https://wiki.example.com
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "CHECK_INTERVAL": "", "ALERT_THRESHOLD": ""})
+	defer cleanup()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.targets[0].escalationPolicy != "internal" {
+		t.Errorf("default escalation policy not applied: %+v", cfg.targets[0])
+	}
+	p := cfg.policy("internal")
+	if p == nil || len(p.levels) != 2 || p.levels[1].after != 15*time.Minute || p.levels[1].oncall != "platform" {
+		t.Fatalf("escalation policy not loaded: %+v", cfg.policies)
+	}
+	if sched := cfg.schedule("platform"); sched == nil || sched.location.String() != "Europe/Berlin" || cfg.people["bob"].Slack != "U0BOB" {
+		t.Errorf("on-call schedule not loaded: %+v %+v", cfg.schedules, cfg.people)
+	}
+}
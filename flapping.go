@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultFlapHighThreshold = 20.0
+	defaultFlapLowThreshold  = 5.0
+)
+
+// flappingConfig controls the detection of targets alternating between
+// failing and succeeding, modeled after Nagios flap detection.
+type flappingConfig struct {
+	window        int     // Checks the state change is calculated over, 0 disables flap detection
+	highThreshold float64 // Percent state change at which a target starts flapping
+	lowThreshold  float64 // Percent state change below which a flapping target is stable again
+}
+
+type fileFlapping struct {
+	Window        int      `yaml:"window" json:"window"`
+	HighThreshold *float64 `yaml:"high_threshold" json:"high_threshold"`
+	LowThreshold  *float64 `yaml:"low_threshold" json:"low_threshold"`
+}
+
+func defaultFlappingConfig() flappingConfig {
+	return flappingConfig{highThreshold: defaultFlapHighThreshold, lowThreshold: defaultFlapLowThreshold}
+}
+
+func (c *flappingConfig) applyFile(f fileFlapping) {
+	if f.Window != 0 {
+		c.window = f.Window
+	}
+	if f.HighThreshold != nil {
+		c.highThreshold = *f.HighThreshold
+	}
+	if f.LowThreshold != nil {
+		c.lowThreshold = *f.LowThreshold
+	}
+}
+
+func validateFlapping(c flappingConfig, errs *configErrors) {
+	if c.window < 0 {
+		errs.add("flapping.window", "must not be negative, got %d", c.window)
+	}
+	if c.window <= 0 {
+		return
+	}
+	if c.window < 3 {
+		errs.add("flapping.window", "must be at least 3 checks, got %d", c.window)
+	}
+	if c.highThreshold <= 0 || c.highThreshold > 100 {
+		errs.add("flapping.high_threshold", "must be between 0 and 100, got %g", c.highThreshold)
+	}
+	if c.lowThreshold < 0 || c.lowThreshold >= c.highThreshold {
+		errs.add("flapping.low_threshold", "must be at least 0 and below flapping.high_threshold, got %g", c.lowThreshold)
+	}
+}
+
+// flapState holds the recent check results of a target for flap detection.
+type flapState struct {
+	results  []bool // Oldest first
+	rate     float64
+	flapping bool
+	ended    bool // Flapping ended with the latest check
+}
+
+// percentStateChange returns the weighted percentage of state changes in
+// results. Like Nagios, the newest change weighs 1.2 and the oldest 0.8.
+func percentStateChange(results []bool) float64 {
+	n := len(results)
+	if n < 2 {
+		return 0
+	}
+	var total float64
+	for i := 1; i < n; i++ {
+		if results[i] == results[i-1] {
+			continue
+		}
+		weight := 1.0
+		if n > 2 {
+			weight = 0.8 + 0.4*float64(i-1)/float64(n-2)
+		}
+		total += weight
+	}
+	return total * 100 / float64(n-1)
+}
+
+// trackFlappingLocked records the result of a check of url and reports
+// whether the target started or stopped flapping with it. Callers must hold
+// s.mu.
+func (s *Service) trackFlappingLocked(url string, ok bool) (started, ended bool) {
+	c := s.config.flapping
+	if c.window <= 0 {
+		if _, tracked := s.flaps[url]; tracked {
+			s.forgetFlappingLocked(url)
+		}
+		return false, false
+	}
+	st := s.flaps[url]
+	if st == nil {
+		st = &flapState{}
+		s.flaps[url] = st
+	}
+	st.results = append(st.results, ok)
+	if len(st.results) > c.window {
+		st.results = st.results[len(st.results)-c.window:]
+	}
+	st.ended = false
+	if len(st.results) < c.window {
+		return false, false
+	}
+	st.rate = percentStateChange(st.results)
+	switch {
+	case !st.flapping && st.rate >= c.highThreshold:
+		st.flapping = true
+		log.Printf("%s started flapping (%.1f%% state change)", url, st.rate)
+		s.metrics.flapping.With(prometheus.Labels{"url": url}).Set(1)
+		return true, false
+	case st.flapping && st.rate < c.lowThreshold:
+		st.flapping, st.ended = false, true
+		log.Printf("%s stopped flapping (%.1f%% state change)", url, st.rate)
+		s.metrics.flapping.With(prometheus.Labels{"url": url}).Set(0)
+		return false, true
+	}
+	return false, false
+}
+
+// isFlappingLocked reports whether url is flapping. Callers must hold s.mu.
+func (s *Service) isFlappingLocked(url string) bool {
+	st := s.flaps[url]
+	return st != nil && st.flapping
+}
+
+// forgetFlappingLocked drops the flap detection state of url. Callers must
+// hold s.mu.
+func (s *Service) forgetFlappingLocked(url string) {
+	delete(s.flaps, url)
+	s.metrics.flapping.Delete(prometheus.Labels{"url": url})
+}
+
+// sendFlapping notifies that t started flapping. Its individual transitions
+// are not notified until it is stable again.
+func (s *Service) sendFlapping(t target) {
+	s.mu.Lock()
+	reason := s.lastReason[t.url]
+	s.mu.Unlock()
+	s.dispatch(s.newAlertEvent(t, StateFlapping, reason))
+}
+
+// flapTitle is the headline of FLAPPING events.
+func flapTitle(ev AlertEvent) string {
+	return fmt.Sprintf("🔀 FLAPPING: %s", ev.Name)
+}
+
+// flapSummary describes a FLAPPING event in one sentence.
+func flapSummary(ev AlertEvent) string {
+	return fmt.Sprintf("%s is flapping between up and down (%.0f%% state change), notifications are paused until it is stable", ev.URL, ev.FlapRate)
+}
+
+// upTitle is the headline of UP events in chat notifiers.
+func upTitle(ev AlertEvent) string {
+	if ev.From == StateDegraded {
+		return fmt.Sprintf("✅ UP: %s is responding normally again", ev.Name)
+	}
+	if ev.FlapEnded {
+		return fmt.Sprintf("✅ STABLE: %s stopped flapping", ev.Name)
+	}
+	return fmt.Sprintf("✅ UP: %s", ev.Name)
+}
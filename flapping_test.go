@@ -0,0 +1,173 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPercentStateChange(t *testing.T) {
+	cases := []struct {
+		results []bool
+		want    float64
+	}{
+		{[]bool{true}, 0},
+		{[]bool{true, true, true, true, true}, 0},
+		{[]bool{true, false, true, false, true}, 100},
+		{[]bool{false, true, true, true, true}, 20}, // Oldest change weighs 0.8
+		{[]bool{true, true, true, true, false}, 30}, // Newest change weighs 1.2
+		{[]bool{true, true, false, true, true}, 50}, // 1.0667 + 0.9333
+	}
+	for _, c := range cases {
+		if got := percentStateChange(c.results); math.Abs(got-c.want) > 0.01 {
+			t.Errorf("%v: got %.2f, want %.2f", c.results, got, c.want)
+		}
+	}
+}
+
+var (
+	flappingTarget     = target{name: "api", url: "https://api.com"}
+	testFlappingConfig = flappingConfig{window: 6, highThreshold: 50, lowThreshold: 20}
+)
+
+func TestFlappingPausesTransitions(t *testing.T) {
+	tgt := flappingTarget
+	s, rec := newRecordingService(tgt)
+	s.config.flapping = testFlappingConfig
+	flapping := s.metrics.flapping.With(prometheus.Labels{"url": tgt.url})
+
+	for range 2 {
+		s.handleSiteError(tgt, "returned status 502")
+		s.handleSiteRecovery(tgt)
+	}
+	s.handleSiteError(tgt, "returned status 502")
+	if got := rec.received(); len(got) != 5 {
+		t.Fatalf("transitions should be notified until the window is full, got %d events", len(got))
+	}
+
+	s.handleSiteRecovery(tgt)
+	got := rec.received()
+	if len(got) != 6 || got[5].State != StateFlapping || got[5].FlapRate != 100 || got[5].Reason != "returned status 502" {
+		t.Fatalf("expected a FLAPPING event instead of the recovery, got %+v", got[len(got)-1])
+	}
+	if v := testutil.ToFloat64(flapping); v != 1 {
+		t.Errorf("expected site_flapping 1, got %v", v)
+	}
+	if title, body := plainMessage(got[5]); !strings.Contains(title, "FLAPPING: api") || !strings.Contains(body, "100% state change") {
+		t.Errorf("unexpected FLAPPING message: %q %q", title, body)
+	}
+	if subject, _, _, _ := (alertTemplates{}).render(got[5]); !strings.Contains(subject, "[🔀 FLAPPING] https://api.com") {
+		t.Errorf("unexpected FLAPPING subject: %q", subject)
+	}
+
+	s.handleSiteError(tgt, "returned status 502")
+	s.handleSiteRecovery(tgt)
+	if n := len(rec.received()); n != 6 {
+		t.Fatalf("transitions of a flapping target should not be notified, got %d events", n)
+	}
+
+	for range 4 {
+		s.handleSiteRecovery(tgt)
+	}
+	got = rec.received()
+	last := got[len(got)-1]
+	if len(got) != 7 || last.State != StateUp || !last.FlapEnded {
+		t.Fatalf("expected an UP event once the target is stable, got %+v", got)
+	}
+	if title, _ := plainMessage(last); !strings.Contains(title, "STABLE: api") {
+		t.Errorf("unexpected title: %q", title)
+	}
+	if v := testutil.ToFloat64(flapping); v != 0 {
+		t.Errorf("expected site_flapping 0, got %v", v)
+	}
+
+	s.handleSiteError(tgt, "returned status 502")
+	if got := rec.received(); len(got) != 8 || got[7].State != StateDown || got[7].FlapEnded {
+		t.Errorf("transitions should be notified again after flapping, got %+v", got[len(got)-1])
+	}
+}
+
+func TestFlappingEndsWhileDown(t *testing.T) {
+	tgt := flappingTarget
+	s, rec := newRecordingService(tgt)
+	s.config.flapping = testFlappingConfig
+
+	for range 3 {
+		s.handleSiteError(tgt, "returned status 502")
+		s.handleSiteRecovery(tgt)
+	}
+	if got := rec.received(); got[len(got)-1].State != StateFlapping {
+		t.Fatalf("expected the target to flap, got %+v", got)
+	}
+	n := len(rec.received())
+
+	for range 5 {
+		s.handleSiteError(tgt, "connection refused")
+	}
+	got := rec.received()
+	if len(got) != n+1 || got[n].State != StateDown || !got[n].FlapEnded || got[n].Reason != "connection refused" {
+		t.Fatalf("expected a DOWN event once the target stopped flapping, got %+v", got[n:])
+	}
+
+	s.handleSiteRecovery(tgt)
+	if got := rec.received(); len(got) != n+2 || got[n+1].State != StateUp || got[n+1].FlapEnded {
+		t.Errorf("expected a regular recovery, got %+v", got[n:])
+	}
+}
+
+func TestFlappingDisabled(t *testing.T) {
+	tgt := flappingTarget
+	s, rec := newRecordingService(tgt)
+	s.config.flapping = testFlappingConfig
+	s.config.flapping.window = 0
+
+	for range 5 {
+		s.handleSiteError(tgt, "returned status 502")
+		s.handleSiteRecovery(tgt)
+	}
+	for _, ev := range rec.received() {
+		if ev.State == StateFlapping {
+			t.Fatal("flap detection should be disabled without a window")
+		}
+	}
+	if len(rec.received()) != 10 || len(s.flaps) != 0 {
+		t.Errorf("every transition should be notified, got %d events", len(rec.received()))
+	}
+}
+
+func TestLoadConfigFlapping(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+flapping:
+  window: 21
+  high_threshold: 30
+targets:
+  - name: api
+    url: https://api.example.com
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "CHECK_INTERVAL": "", "ALERT_THRESHOLD": ""})
+	defer cleanup()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (flappingConfig{window: 21, highThreshold: 30, lowThreshold: defaultFlapLowThreshold}); cfg.flapping != want {
+		t.Errorf("got %+v, want %+v", cfg.flapping, want)
+	}
+
+	errs := &configErrors{}
+	validateFlapping(flappingConfig{window: 2, highThreshold: 120, lowThreshold: 130}, errs)
+	msg := errs.Error()
+	for _, want := range []string{
+		"flapping.window: must be at least 3 checks",
+		"flapping.high_threshold: must be between 0 and 100",
+		"flapping.low_threshold: must be at least 0 and below flapping.high_threshold",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("missing %q in %s", want, msg)
+		}
+	}
+}
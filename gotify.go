@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// gotifyConfig holds the settings of a Gotify notifier.
+type gotifyConfig struct {
+	server       string
+	token        string // Application token
+	priorityDown int
+	priorityUp   int
+}
+
+type fileGotify struct {
+	Server       string `yaml:"server" json:"server"`
+	Token        string `yaml:"token" json:"token"`
+	PriorityDown *int   `yaml:"priority_down" json:"priority_down"`
+	PriorityUp   *int   `yaml:"priority_up" json:"priority_up"`
+}
+
+func parseGotify(f fileGotify) gotifyConfig {
+	cfg := gotifyConfig{
+		server:       strings.TrimSuffix(f.Server, "/"),
+		token:        f.Token,
+		priorityDown: 8,
+		priorityUp:   5,
+	}
+	if f.PriorityDown != nil {
+		cfg.priorityDown = *f.PriorityDown
+	}
+	if f.PriorityUp != nil {
+		cfg.priorityUp = *f.PriorityUp
+	}
+	return cfg
+}
+
+func validateGotify(cfg gotifyConfig, field string, errs *configErrors) {
+	requireURL(errs, field+".server", cfg.server, "gotify")
+	if cfg.token == "" {
+		errs.add(field+".token", "required for gotify notifiers")
+	}
+	if cfg.priorityDown < 0 || cfg.priorityDown > 10 {
+		errs.add(field+".priority_down", "must be between 0 and 10")
+	}
+	if cfg.priorityUp < 0 || cfg.priorityUp > 10 {
+		errs.add(field+".priority_up", "must be between 0 and 10")
+	}
+}
+
+// gotifyNotifier pushes messages to a Gotify server.
+type gotifyNotifier struct {
+	cfg    gotifyConfig
+	client *http.Client
+}
+
+func newGotifyNotifier(nc notifierConfig) *gotifyNotifier {
+	return &gotifyNotifier{cfg: nc.gotify, client: &http.Client{Timeout: nc.timeout}}
+}
+
+type gotifyMessage struct {
+	Title    string         `json:"title"`
+	Message  string         `json:"message"`
+	Priority int            `json:"priority"`
+	Extras   map[string]any `json:"extras,omitempty"`
+}
+
+func (n *gotifyNotifier) Notify(ev AlertEvent) error {
+	return postJSON(n.client, n.cfg.server+"/message", map[string]string{"X-Gotify-Key": n.cfg.token}, n.message(ev))
+}
+
+// NotifyDigest pushes d as a single message with the DOWN priority if it
+// contains any DOWN event.
+func (n *gotifyNotifier) NotifyDigest(d AlertDigest) error {
+	title, body := digestMessage(d)
+	msg := gotifyMessage{Title: title, Message: body, Priority: n.cfg.priorityDown}
+	if d.Count(StateDown) == 0 {
+		msg.Priority = n.cfg.priorityUp
+	}
+	return postJSON(n.client, n.cfg.server+"/message", map[string]string{"X-Gotify-Key": n.cfg.token}, msg)
+}
+
+func (n *gotifyNotifier) message(ev AlertEvent) gotifyMessage {
+	title, body := plainMessage(ev)
+	msg := gotifyMessage{Title: title, Message: body, Priority: n.cfg.priorityDown}
+	if ev.State == StateUp {
+		msg.Priority = n.cfg.priorityUp
+	}
+	if ev.DashboardURL != "" {
+		msg.Extras = map[string]any{
+			"client::notification": map[string]any{"click": map[string]string{"url": ev.DashboardURL}},
+		}
+	}
+	return msg
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGotifyNotifier(t *testing.T) {
+	server := newWebhookRecorder(t, http.StatusOK)
+	n := newGotifyNotifier(notifierConfig{timeout: time.Second, gotify: parseGotify(fileGotify{
+		Server: server.URL, Token: "app-token",
+	})})
+
+	if err := n.Notify(testEvent(StateDown)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var msg gotifyMessage
+	server.next(t, &msg)
+	if h := <-server.headers; h.Get("X-Gotify-Key") != "app-token" {
+		t.Errorf("expected application token header, got %v", h)
+	}
+	if msg.Title != "🚨 DOWN: checkout" || msg.Priority != 8 {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+	if _, ok := msg.Extras["client::notification"]; !ok {
+		t.Errorf("expected click extras, got %+v", msg.Extras)
+	}
+
+	if up := n.message(testEvent(StateUp)); up.Priority != 5 {
+		t.Errorf("expected normal priority for UP, got %d", up.Priority)
+	}
+}
+
+func TestGotifyCustomPriorities(t *testing.T) {
+	down, up := 10, 2
+	cfg := parseGotify(fileGotify{Server: "https://gotify.example.com", Token: "x", PriorityDown: &down, PriorityUp: &up})
+	n := newGotifyNotifier(notifierConfig{gotify: cfg})
+	if p := n.message(testEvent(StateDown)).Priority; p != 10 {
+		t.Errorf("expected DOWN priority 10, got %d", p)
+	}
+	if p := n.message(testEvent(StateUp)).Priority; p != 2 {
+		t.Errorf("expected UP priority 2, got %d", p)
+	}
+
+	bad := 11
+	errs := &configErrors{}
+	validateGotify(parseGotify(fileGotify{PriorityDown: &bad}), "notifiers[0].gotify", errs)
+	got := errs.Error()
+	for _, want := range []string{"gotify.server", "gotify.token", "must be between 0 and 10"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	neturl "net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// groupByHost groups events by the host of the target URL instead of a label.
+const groupByHost = "host"
+
+// stormURL identifies the summary event of a storm in place of a target URL.
+const stormURL = "alert-storm"
+
+// groupingConfig controls how transitions are batched into digests.
+type groupingConfig struct {
+	window         time.Duration // 0 sends every event on its own
+	by             []string      // "host" or label names; empty puts all events in one group
+	stormThreshold int           // Events per window above which only a summary is sent, 0 disables
+}
+
+type fileGrouping struct {
+	Window         string   `yaml:"window" json:"window"`
+	By             []string `yaml:"by" json:"by"`
+	StormThreshold int      `yaml:"storm_threshold" json:"storm_threshold"`
+}
+
+func (c *groupingConfig) applyFile(f fileGrouping, errs *configErrors) {
+	if f.Window != "" {
+		errs.duration("grouping.window", f.Window, &c.window)
+	}
+	if len(f.By) > 0 {
+		c.by = f.By
+	}
+	if f.StormThreshold != 0 {
+		c.stormThreshold = f.StormThreshold
+	}
+}
+
+func validateGrouping(c groupingConfig, errs *configErrors) {
+	if c.window < 0 {
+		errs.add("grouping.window", "must not be negative")
+	}
+	if c.stormThreshold < 0 {
+		errs.add("grouping.storm_threshold", "must not be negative, got %d", c.stormThreshold)
+	}
+	if c.stormThreshold > 0 && c.window == 0 {
+		errs.add("grouping.storm_threshold", "requires grouping.window")
+	}
+	for i, key := range c.by {
+		if strings.TrimSpace(key) == "" {
+			errs.add(fmt.Sprintf("grouping.by[%d]", i), "must not be empty")
+		}
+	}
+}
+
+// AlertDigest batches the events of one grouping window. In a storm it
+// covers all groups and notifiers only send a summary.
+type AlertDigest struct {
+	Storm  bool         `json:"storm"`
+	Groups []AlertGroup `json:"groups"`
+	At     time.Time    `json:"at"`
+}
+
+// AlertGroup holds the events sharing a grouping key such as "team=payments".
+type AlertGroup struct {
+	Key    string       `json:"key"` // Empty when events are not grouped by anything
+	Events []AlertEvent `json:"events"`
+}
+
+// DigestNotifier is implemented by notifiers that can deliver a digest as a
+// single message. Other notifiers receive the events one by one, or a
+// single summary event in a storm unless they track targets.
+type DigestNotifier interface {
+	NotifyDigest(d AlertDigest) error
+}
+
+// digestNotifier returns n as a DigestNotifier if it can deliver digests in
+// its configuration. Webhooks with a custom template only render events.
+func digestNotifier(n Notifier) (DigestNotifier, bool) {
+	if w, ok := n.(*webhookNotifier); ok && w.cfg.template != nil {
+		return nil, false
+	}
+	dn, ok := n.(DigestNotifier)
+	return dn, ok
+}
+
+// tracksTargets reports whether n keeps an incident or alert per target URL
+// that only an UP of the same URL resolves. Such notifiers receive the
+// events of a storm one by one, since nothing would resolve a summary.
+func tracksTargets(n Notifier) bool {
+	switch n.(type) {
+	case *pagerDutyNotifier, *alertmanagerNotifier:
+		return true
+	}
+	return false
+}
+
+// Events returns the events of all groups.
+func (d AlertDigest) Events() []AlertEvent {
+	var out []AlertEvent
+	for _, g := range d.Groups {
+		out = append(out, g.Events...)
+	}
+	return out
+}
+
+// Count returns the number of events in state.
+func (d AlertDigest) Count(state AlertState) int {
+	n := 0
+	for _, ev := range d.Events() {
+		if ev.State == state {
+			n++
+		}
+	}
+	return n
+}
+
+// filter returns the digest reduced to the events keep accepts, dropping
+// empty groups.
+func (d AlertDigest) filter(keep func(AlertEvent) bool) AlertDigest {
+	out := AlertDigest{Storm: d.Storm, At: d.At}
+	for _, g := range d.Groups {
+		var events []AlertEvent
+		for _, ev := range g.Events {
+			if keep(ev) {
+				events = append(events, ev)
+			}
+		}
+		if len(events) > 0 {
+			out.Groups = append(out.Groups, AlertGroup{Key: g.Key, Events: events})
+		}
+	}
+	return out
+}
+
+// partition splits d by the key of its events, in order of first appearance.
+func (d AlertDigest) partition(key func(AlertEvent) string) []AlertDigest {
+	var keys []string
+	for _, ev := range d.Events() {
+		if k := key(ev); !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+	out := make([]AlertDigest, len(keys))
+	for i, k := range keys {
+		out[i] = d.filter(func(ev AlertEvent) bool { return key(ev) == k })
+	}
+	return out
+}
+
+// stormEvent summarizes the storm d as a single event for notifiers that
+// can't deliver digests. It is DOWN while any target in it went down, so
+// that the UP of a later all-clear storm resolves it.
+func stormEvent(d AlertDigest) AlertEvent {
+	down, up := d.Count(StateDown), d.Count(StateUp)
+	state := StateDown
+	if down == 0 {
+		state = StateUp
+	}
+	return AlertEvent{
+		Name:         "Alert storm",
+		URL:          stormURL,
+		State:        state,
+		Reason:       fmt.Sprintf("%d targets changed state (%d down, %d up)", len(d.Events()), down, up),
+		FailureCount: down,
+		Since:        d.At,
+		At:           d.At,
+		Labels:       map[string]string{"storm": "true"},
+	}
+}
+
+// groupKey returns the grouping key of ev, e.g. "team=payments, host=api.example.com".
+func (c groupingConfig) groupKey(ev AlertEvent) string {
+	parts := make([]string, len(c.by))
+	for i, key := range c.by {
+		value := ev.Labels[key]
+		if key == groupByHost {
+			if u, err := neturl.Parse(ev.URL); err == nil {
+				value = u.Hostname()
+			}
+		}
+		parts[i] = key + "=" + value
+	}
+	return strings.Join(parts, ", ")
+}
+
+// digests groups the events of a window. Above the storm threshold all
+// groups go into a single summary digest.
+func (c groupingConfig) digests(events []AlertEvent, at time.Time) []AlertDigest {
+	var groups []AlertGroup
+	for _, ev := range events {
+		key := c.groupKey(ev)
+		i := slices.IndexFunc(groups, func(g AlertGroup) bool { return g.Key == key })
+		if i < 0 {
+			groups = append(groups, AlertGroup{Key: key})
+			i = len(groups) - 1
+		}
+		groups[i].Events = append(groups[i].Events, ev)
+	}
+	if c.stormThreshold > 0 && len(events) > c.stormThreshold {
+		return []AlertDigest{{Storm: true, Groups: groups, At: at}}
+	}
+	out := make([]AlertDigest, len(groups))
+	for i, g := range groups {
+		out[i] = AlertDigest{Groups: []AlertGroup{g}, At: at}
+	}
+	return out
+}
+
+// dispatch sends ev right away, or holds it for the digest of the current
+// grouping window.
+func (s *Service) dispatch(ev AlertEvent) {
+	s.mu.Lock()
+	window := s.config.grouping.window
+	if window <= 0 {
+		s.mu.Unlock()
+		s.notify(ev)
+		return
+	}
+	s.grouped = append(s.grouped, ev)
+	if len(s.grouped) == 1 {
+		time.AfterFunc(window, s.flushGroups)
+	}
+	s.mu.Unlock()
+}
+
+// flushGroups sends the digests of the events held since the window opened.
+func (s *Service) flushGroups() {
+	s.mu.Lock()
+	events := s.grouped
+	s.grouped = nil
+	cfg := s.config.grouping
+	s.mu.Unlock()
+	if len(events) == 0 {
+		return
+	}
+	for _, d := range cfg.digests(events, time.Now()) {
+		if d.Storm {
+			log.Printf("Alert storm: %d events in %d groups, sending a summary", len(events), len(d.Groups))
+		}
+		s.notifyDigest(d)
+	}
+}
+
+// notifyDigest fans d out to all notifiers in parallel, each receiving only
+// the events it accepts. A single event is delivered as a normal alert.
+func (s *Service) notifyDigest(d AlertDigest) {
+	var wg sync.WaitGroup
+	for _, entry := range s.activeNotifiers() {
+		sub := d.filter(entry.accepts)
+		events := sub.Events()
+		if len(events) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(entry notifierEntry) {
+			defer wg.Done()
+			dn, ok := digestNotifier(entry.notifier)
+			switch {
+			case len(events) == 1 && !sub.Storm:
+				s.deliver(entry, events[0])
+			case ok:
+				err := dn.NotifyDigest(sub)
+				if err != nil {
+					log.Printf("Notifier %s failed for digest of %d alerts: %v", entry.name, len(events), err)
+				}
+				if s.outbox == nil {
+					break
+				}
+				if err != nil {
+					s.outbox.enqueueDigest(entry.name, sub, err)
+				} else {
+					s.outbox.supersede(entry.name, events...)
+				}
+			case sub.Storm && !tracksTargets(entry.notifier):
+				s.deliver(entry, stormEvent(sub))
+			default:
+				for _, ev := range events {
+					s.deliver(entry, ev)
+				}
+			}
+		}(entry)
+	}
+	wg.Wait()
+}
+
+// digestMessage renders d as a short title and text body. Storm digests
+// only list the number of events per group.
+func digestMessage(d AlertDigest) (title, body string) {
+	down, up := d.Count(StateDown), d.Count(StateUp)
+	var lines []string
+	if d.Storm {
+		title = fmt.Sprintf("⛈️ Alert storm: %d targets changed state (%d down, %d up)", len(d.Events()), down, up)
+		for _, g := range d.Groups {
+			gd := AlertDigest{Groups: []AlertGroup{g}}
+			key := g.Key
+			if key == "" {
+				key = "all targets"
+			}
+			lines = append(lines, fmt.Sprintf("%s: %d down, %d up", key, gd.Count(StateDown), gd.Count(StateUp)))
+		}
+		return title, strings.Join(lines, "\n")
+	}
+
+	var counts []string
+	if down > 0 {
+		counts = append(counts, fmt.Sprintf("🚨 %d DOWN", down))
+	}
+	if up > 0 {
+		counts = append(counts, fmt.Sprintf("✅ %d UP", up))
+	}
+	if flapping := d.Count(StateFlapping); flapping > 0 {
+		counts = append(counts, fmt.Sprintf("🔀 %d FLAPPING", flapping))
+	}
+	if degraded := d.Count(StateDegraded); degraded > 0 {
+		counts = append(counts, fmt.Sprintf("🐢 %d DEGRADED", degraded))
+	}
+	title = strings.Join(counts, ", ")
+	if len(d.Groups) == 1 && d.Groups[0].Key != "" {
+		title += " (" + d.Groups[0].Key + ")"
+	}
+	for _, ev := range d.Events() {
+		switch {
+		case ev.State == StateFlapping:
+			lines = append(lines, fmt.Sprintf("🔀 %s: %s is flapping (%.0f%% state change)", ev.Name, ev.URL, ev.FlapRate))
+		case ev.State == StateDegraded:
+			lines = append(lines, fmt.Sprintf("🐢 %s: %s %s", ev.Name, ev.URL, ev.Reason))
+		case ev.State == StateUp && ev.From == StateDegraded:
+			lines = append(lines, fmt.Sprintf("✅ %s: %s is responding normally again after %s", ev.Name, ev.URL, ev.Downtime()))
+		case ev.State == StateUp && ev.FlapEnded:
+			lines = append(lines, fmt.Sprintf("✅ %s: %s stopped flapping and is up", ev.Name, ev.URL))
+		case ev.State == StateUp:
+			line := fmt.Sprintf("✅ %s: %s is back online after %s", ev.Name, ev.URL, ev.Downtime())
+			if ev.Parent != "" {
+				line += fmt.Sprintf(" (was unreachable due to parent %s)", ev.Parent)
+			}
+			if ev.AckedBy != "" {
+				line += ", " + ackNote(ev)
+			}
+			lines = append(lines, line)
+		case ev.Reminder > 0:
+			lines = append(lines, fmt.Sprintf("🔁 %s: %s is still down after %s (%s)", ev.Name, ev.URL, ev.Downtime(), ev.Reason))
+		default:
+			lines = append(lines, fmt.Sprintf("🚨 %s: %s %s", ev.Name, ev.URL, ev.Reason))
+		}
+	}
+	return title, strings.Join(lines, "\n")
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// digestRecorder records events and digests.
+type digestRecorder struct {
+	recordingNotifier
+	mu      sync.Mutex
+	digests []AlertDigest
+	err     error
+}
+
+func (r *digestRecorder) NotifyDigest(d AlertDigest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.digests = append(r.digests, d)
+	return r.err
+}
+
+func (r *digestRecorder) receivedDigests() []AlertDigest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]AlertDigest(nil), r.digests...)
+}
+
+func groupEvent(name, url, team string, state AlertState) AlertEvent {
+	ev := testEvent(state)
+	ev.Name, ev.URL, ev.Labels = name, url, map[string]string{"team": team}
+	return ev
+}
+
+func TestGroupingDigests(t *testing.T) {
+	events := []AlertEvent{
+		groupEvent("shop", "https://shop.example.com", "web", StateDown),
+		groupEvent("api", "https://api.example.com/health", "payments", StateDown),
+		groupEvent("blog", "https://shop.example.com/blog", "web", StateUp),
+	}
+
+	byTeam := groupingConfig{by: []string{"team"}}
+	digests := byTeam.digests(events, time.Now())
+	if len(digests) != 2 || digests[0].Groups[0].Key != "team=web" || len(digests[0].Events()) != 2 || digests[1].Groups[0].Key != "team=payments" {
+		t.Fatalf("unexpected digests by team: %+v", digests)
+	}
+
+	byHost := groupingConfig{by: []string{groupByHost}}
+	if digests := byHost.digests(events, time.Now()); len(digests) != 2 || digests[0].Groups[0].Key != "host=shop.example.com" {
+		t.Fatalf("unexpected digests by host: %+v", digests)
+	}
+
+	storm := groupingConfig{by: []string{"team"}, stormThreshold: 2}
+	digests = storm.digests(events, time.Now())
+	if len(digests) != 1 || !digests[0].Storm || len(digests[0].Groups) != 2 {
+		t.Fatalf("expected a single storm summary, got %+v", digests)
+	}
+	title, body := digestMessage(digests[0])
+	if !strings.Contains(title, "3 targets changed state (2 down, 1 up)") {
+		t.Errorf("unexpected storm title: %q", title)
+	}
+	if body != "team=web: 1 down, 1 up\nteam=payments: 1 down, 0 up" {
+		t.Errorf("storm summary should only list counts per group: %q", body)
+	}
+}
+
+func TestDispatchGroupsEventsIntoDigests(t *testing.T) {
+	s := newTestService()
+	chat := &digestRecorder{}
+	pager := &recordingNotifier{}
+	s.notifiers = []notifierEntry{
+		{name: "chat", notifier: chat},
+		{name: "pager", notifier: pager},
+	}
+	s.config.grouping = groupingConfig{window: time.Hour, by: []string{"team"}}
+
+	s.dispatch(groupEvent("shop", "https://shop.example.com", "web", StateDown))
+	s.dispatch(groupEvent("blog", "https://blog.example.com", "web", StateDown))
+	s.dispatch(groupEvent("api", "https://api.example.com", "payments", StateDown))
+	if len(chat.receivedDigests()) != 0 || len(pager.received()) != 0 {
+		t.Fatal("events should be held until the window closes")
+	}
+
+	s.flushGroups()
+	digests := chat.receivedDigests()
+	if len(digests) != 1 || len(digests[0].Events()) != 2 {
+		t.Fatalf("expected one digest for team=web, got %+v", digests)
+	}
+	if got := chat.received(); len(got) != 1 || got[0].Name != "api" {
+		t.Errorf("single-event groups should be sent as normal alerts, got %+v", got)
+	}
+	if got := pager.received(); len(got) != 3 {
+		t.Errorf("notifiers without digest support should get every event, got %d", len(got))
+	}
+	title, body := digestMessage(digests[0])
+	if title != "🚨 2 DOWN (team=web)" || !strings.Contains(body, "🚨 blog: https://blog.example.com returned status 503") {
+		t.Errorf("unexpected digest message: %q %q", title, body)
+	}
+}
+
+func TestDigestRespectsNotifierFilters(t *testing.T) {
+	s := newTestService()
+	web := &digestRecorder{}
+	s.notifiers = []notifierEntry{{name: "web", filter: notifierFilter{labels: map[string]string{"team": "web"}}, notifier: web}}
+
+	s.notifyDigest(AlertDigest{Storm: true, Groups: []AlertGroup{
+		{Key: "team=web", Events: []AlertEvent{groupEvent("shop", "https://shop.example.com", "web", StateDown)}},
+		{Key: "team=payments", Events: []AlertEvent{groupEvent("api", "https://api.example.com", "payments", StateDown)}},
+	}})
+	digests := web.receivedDigests()
+	if len(digests) != 1 || len(digests[0].Groups) != 1 || digests[0].Groups[0].Key != "team=web" {
+		t.Errorf("digest should only contain the events matching the filter: %+v", digests)
+	}
+}
+
+func TestOutboxRetriesDigests(t *testing.T) {
+	s := newTestService()
+	chat := &digestRecorder{err: errors.New("connection refused")}
+	s.notifiers = []notifierEntry{{name: "chat", notifier: chat}}
+	s.outbox, _ = newTestOutbox(t, outboxConfig{path: filepath.Join(t.TempDir(), "outbox.json"), maxAttempts: 3, backoff: time.Minute, maxBackoff: time.Minute})
+
+	d := AlertDigest{Groups: []AlertGroup{{Events: []AlertEvent{
+		groupEvent("shop", "https://shop.example.com", "web", StateDown),
+		groupEvent("blog", "https://blog.example.com", "web", StateDown),
+	}}}}
+	s.notifyDigest(d)
+	chat.mu.Lock()
+	chat.err = nil
+	chat.mu.Unlock()
+
+	s.retryOutbox(time.Now().Add(2 * time.Minute))
+	if digests := chat.receivedDigests(); len(digests) != 2 || len(digests[1].Events()) != 2 {
+		t.Errorf("expected the digest to be redelivered, got %+v", digests)
+	}
+	if len(chat.received()) != 0 {
+		t.Error("a failed digest should not be retried as single events")
+	}
+}
+
+func TestWebhookDigestPayload(t *testing.T) {
+	hook := newWebhookRecorder(t, http.StatusOK)
+	n := newWebhookNotifier(notifierConfig{timeout: time.Second, webhook: webhookConfig{url: hook.URL, method: http.MethodPost, contentType: "application/json"}})
+	d := AlertDigest{Storm: true, Groups: []AlertGroup{{Key: "team=web", Events: []AlertEvent{
+		groupEvent("shop", "https://shop.example.com", "web", StateDown),
+		groupEvent("blog", "https://blog.example.com", "web", StateUp),
+	}}}}
+	if err := n.NotifyDigest(d); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var payload webhookDigestPayload
+	hook.next(t, &payload)
+	if payload.Type != "digest" || !payload.Storm || len(payload.Groups) != 1 {
+		t.Fatalf("unexpected digest payload: %+v", payload)
+	}
+	if g := payload.Groups[0]; g.Key != "team=web" || g.Down != 1 || g.Up != 1 || len(g.Events) != 0 {
+		t.Errorf("storm payload should only carry counts: %+v", g)
+	}
+}
+
+func TestStormSummaryForNotifiersWithoutDigests(t *testing.T) {
+	s := newTestService()
+	pager := &recordingNotifier{}
+	s.notifiers = []notifierEntry{{name: "pager", notifier: pager}}
+	at := time.Now()
+	s.notifyDigest(AlertDigest{Storm: true, At: at, Groups: []AlertGroup{
+		{Key: "team=web", Events: []AlertEvent{
+			groupEvent("shop", "https://shop.example.com", "web", StateDown),
+			groupEvent("blog", "https://blog.example.com", "web", StateUp),
+		}},
+		{Key: "team=payments", Events: []AlertEvent{groupEvent("api", "https://api.example.com", "payments", StateDown)}},
+	}})
+
+	got := pager.received()
+	if len(got) != 1 {
+		t.Fatalf("expected a single storm summary, got %d events", len(got))
+	}
+	ev := got[0]
+	if ev.URL != stormURL || ev.State != StateDown || ev.Reason != "3 targets changed state (2 down, 1 up)" || !ev.At.Equal(at) {
+		t.Errorf("unexpected storm summary: %+v", ev)
+	}
+	if event := (&pagerDutyNotifier{}).event(ev); event.Payload.Summary != "Alert storm is DOWN: 3 targets changed state (2 down, 1 up)" {
+		t.Errorf("unexpected PagerDuty summary: %q", event.Payload.Summary)
+	}
+}
+
+func TestStormEventsForNotifiersTrackingTargets(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var event pagerDutyEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		mu.Lock()
+		keys = append(keys, event.EventAction+" "+event.DedupKey)
+		mu.Unlock()
+		return &http.Response{StatusCode: http.StatusAccepted, Body: io.NopCloser(strings.NewReader("")), Header: http.Header{}}, nil
+	})}
+	s := newTestService()
+	s.notifiers = []notifierEntry{{name: "pagerduty", notifier: &pagerDutyNotifier{cfg: pagerDutyConfig{eventsURL: "https://events.example.com"}, client: client}}}
+	s.notifyDigest(AlertDigest{Storm: true, At: time.Now(), Groups: []AlertGroup{{Key: "team=web", Events: []AlertEvent{
+		groupEvent("shop", "https://shop.example.com", "web", StateDown),
+		groupEvent("blog", "https://blog.example.com", "web", StateUp),
+	}}}})
+
+	slices.Sort(keys)
+	want := []string{
+		"resolve " + pagerDutyDedupKey("https://blog.example.com"),
+		"trigger " + pagerDutyDedupKey("https://shop.example.com"),
+	}
+	if !slices.Equal(keys, want) {
+		t.Errorf("expected an event per target instead of a storm summary, got %v", keys)
+	}
+}
+
+func TestTemplateWebhookDigestRetriesFailedEvents(t *testing.T) {
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body, _ := io.ReadAll(r.Body); strings.Contains(string(body), "blog") {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer hook.Close()
+	errs := &configErrors{}
+	cfg := parseWebhook(fileWebhook{URL: hook.URL, Template: `{"text": {{ json .URL }}}`}, "webhook", errs)
+	if err := errs.err(); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestService()
+	s.notifiers = []notifierEntry{{name: "hook", notifier: newWebhookNotifier(notifierConfig{timeout: time.Second, webhook: cfg})}}
+	s.outbox, _ = newTestOutbox(t, outboxConfig{maxAttempts: 3, backoff: time.Minute, maxBackoff: time.Minute})
+
+	s.notifyDigest(AlertDigest{Groups: []AlertGroup{{Events: []AlertEvent{
+		groupEvent("shop", "https://shop.example.com", "web", StateDown),
+		groupEvent("blog", "https://blog.example.com", "web", StateDown),
+	}}}})
+	if p := s.outbox.pending; len(p) != 1 || p[0].Digest != nil || p[0].Event.URL != "https://blog.example.com" {
+		t.Errorf("expected only the failed event to be queued, got %+v", p)
+	}
+}
+
+func TestLoadConfigGrouping(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+grouping:
+  window: 30s
+  by: [team, host]
+  storm_threshold: 10
+targets:
+  - url: https://a.example.com
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "CHECK_INTERVAL": "", "ALERT_THRESHOLD": ""})
+	defer cleanup()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.grouping.window != 30*time.Second || len(cfg.grouping.by) != 2 || cfg.grouping.stormThreshold != 10 {
+		t.Errorf("grouping not loaded: %+v", cfg.grouping)
+	}
+
+	errs := &configErrors{}
+	validateGrouping(groupingConfig{stormThreshold: 5, by: []string{""}}, errs)
+	msg := errs.Error()
+	if !strings.Contains(msg, "grouping.storm_threshold: requires grouping.window") || !strings.Contains(msg, "grouping.by[0]: must not be empty") {
+		t.Errorf("unexpected validation result: %s", msg)
+	}
+}
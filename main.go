@@ -4,9 +4,11 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"os"
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -15,29 +17,92 @@ type Service struct {
 	metrics      appMetrics
 	config       appConfig
 	offlineMap   map[string]bool
-	failureCount map[string]int // Track consecutive failures
+	failureCount map[string]int           // Track consecutive failures
+	successCount map[string]int           // Consecutive successful checks
+	recentChecks map[string][]bool        // Results of the checks in the alert window, oldest first
+	downSince    map[string]time.Time     // First failure of the current failure streak
+	lastReason   map[string]string        // Reason of the most recent failure
+	lastStatus   map[string]int           // HTTP status of the latest check, 0 if unreachable
+	history      map[string][]CheckResult // Recent checks, oldest first
+	lastAlert    map[string]time.Time     // Last DOWN alert or reminder of the current outage
+	reminders    map[string]int           // Reminders sent for the current outage
+	suppressed   map[string]string        // Parent a down target is unreachable due to
 	mu           sync.Mutex
 	emailSender  EmailSender
+	notifiers    []notifierEntry
+	outbox       *alertOutbox // Failed deliveries to retry, nil to only log them
+	grouped      []AlertEvent // Events held for the digest of the current grouping window
+	silences     *silenceStore
+	silencedDown map[string]bool // Down targets whose DOWN alert was muted by a silence
+	acked        map[string]Acknowledgement
+	escalations  map[string]escalationState // Escalation level reached by outages of targets with a policy
+	flaps        map[string]*flapState      // Recent check results for flap detection
+	latency      map[string]*latencyState   // Slow checks of targets with a latency budget
+
+	runCtx   context.Context
+	monitors map[string]*monitorHandle // Running checks by URL
+	reloadMu sync.Mutex                // Serializes config reloads
 }
 
 func newService() *Service {
 	service := &Service{
 		offlineMap:   make(map[string]bool),
 		failureCount: make(map[string]int),
+		successCount: make(map[string]int),
+		recentChecks: make(map[string][]bool),
+		downSince:    make(map[string]time.Time),
+		lastReason:   make(map[string]string),
+		lastStatus:   make(map[string]int),
+		history:      make(map[string][]CheckResult),
+		lastAlert:    make(map[string]time.Time),
+		reminders:    make(map[string]int),
+		suppressed:   make(map[string]string),
+		silencedDown: make(map[string]bool),
+		acked:        make(map[string]Acknowledgement),
+		escalations:  make(map[string]escalationState),
+		flaps:        make(map[string]*flapState),
+		latency:      make(map[string]*latencyState),
+		monitors:     make(map[string]*monitorHandle),
 	}
 	service.initMetrics()
 	service.readConfig()
+	service.initTimingMetrics()
 	service.emailSender = &SMTPSender{cfg: service.config}
+	notifiers, err := service.buildNotifiers(service.config)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	service.notifiers = notifiers
+	service.outbox, err = newAlertOutbox(service.config.outbox, service.metrics)
+	if err != nil {
+		log.Fatalf("Loading alert outbox: %v", err)
+	}
+	service.silences, err = newSilenceStore(service.config.silencesPath)
+	if err != nil {
+		log.Fatalf("Loading silences: %v", err)
+	}
+	service.silences.setStatic(service.config.maintenance)
 	return service
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(runValidate(os.Args[2:], os.Stdout))
+	}
+
 	service := newService()
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 	service.recordMetrics(ctx)
+	go service.handleReloads(ctx)
+	go service.processOutbox(ctx)
+	go service.watchSilences(ctx)
 
 	http.Handle("/metrics", promhttp.Handler())
+	http.HandleFunc("/outbox/dead-letters", service.handleDeadLetters)
+	http.HandleFunc("/silences", service.handleSilences)
+	http.HandleFunc("/silences/", service.handleSilences)
+	http.HandleFunc("/ack", service.handleAck)
 	go func() {
 		if err := http.ListenAndServe(":2112", nil); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("HTTP server error: %v", err)
@@ -45,4 +110,5 @@ func main() {
 	}()
 	<-ctx.Done()
 	log.Println("Shutting down...")
+	service.flushGroups()
 }
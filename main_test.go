@@ -5,6 +5,9 @@ import (
 )
 
 func TestNewServiceInitializesFields(t *testing.T) {
+	cleanup := setupEnv(map[string]string{"URLS": "https://a.com", "CONFIG_FILE": ""})
+	defer cleanup()
+
 	s := newService()
 	if s.offlineMap == nil {
 		t.Error("offlineMap not initialized")
@@ -8,9 +8,21 @@ import (
 
 type appMetrics struct {
 	siteStatus   *prometheus.GaugeVec
-	sites        prometheus.Counter
+	sites        prometheus.Gauge
 	offlineSites prometheus.Gauge
 	errorCounter *prometheus.GaugeVec
+	unreachable  *prometheus.GaugeVec
+	silenced     *prometheus.GaugeVec
+	acknowledged *prometheus.GaugeVec
+	flapping     *prometheus.GaugeVec
+	state        *prometheus.GaugeVec
+
+	phaseDuration *prometheus.HistogramVec
+	phaseLast     *prometheus.GaugeVec
+
+	outboxDepth      prometheus.Gauge
+	deadLetters      prometheus.Gauge
+	deliveryFailures *prometheus.CounterVec
 }
 
 func (s *Service) initMetrics() {
@@ -22,7 +34,7 @@ func (s *Service) initMetrics() {
 		log.Fatal(err)
 	}
 
-	s.metrics.sites = prometheus.NewCounter(prometheus.CounterOpts{
+	s.metrics.sites = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "sites",
 		Help: "The number of monitored sites",
 	})
@@ -45,4 +57,68 @@ func (s *Service) initMetrics() {
 	if err := prometheus.Register(s.metrics.errorCounter); err != nil && err.Error() != "duplicate metrics collector for error counter for offline sites registration attempted" {
 		log.Fatal(err)
 	}
+
+	s.metrics.unreachable = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "site_unreachable_due_to_parent",
+		Help: "Set to 1 for down sites whose alerts are suppressed because a parent target is down",
+	}, []string{"url", "parent"})
+	if err := prometheus.Register(s.metrics.unreachable); err != nil && err.Error() != "duplicate metrics collector for site_unreachable_due_to_parent registration attempted" {
+		log.Fatal(err)
+	}
+
+	s.metrics.silenced = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "site_silenced",
+		Help: "Set to 1 while a maintenance window or silence mutes the notifications of a site",
+	}, []string{"url"})
+	if err := prometheus.Register(s.metrics.silenced); err != nil && err.Error() != "duplicate metrics collector for site_silenced registration attempted" {
+		log.Fatal(err)
+	}
+
+	s.metrics.acknowledged = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "site_acknowledged",
+		Help: "Set to 1 while the outage of a site is acknowledged",
+	}, []string{"url"})
+	if err := prometheus.Register(s.metrics.acknowledged); err != nil && err.Error() != "duplicate metrics collector for site_acknowledged registration attempted" {
+		log.Fatal(err)
+	}
+
+	s.metrics.flapping = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "site_flapping",
+		Help: "Set to 1 while a site alternates between failing and succeeding and its transitions are not notified",
+	}, []string{"url"})
+	if err := prometheus.Register(s.metrics.flapping); err != nil && err.Error() != "duplicate metrics collector for site_flapping registration attempted" {
+		log.Fatal(err)
+	}
+
+	s.metrics.state = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "site_state",
+		Help: "The state of a site: 0 = up, 1 = degraded, 2 = down",
+	}, []string{"url"})
+	if err := prometheus.Register(s.metrics.state); err != nil && err.Error() != "duplicate metrics collector for site_state registration attempted" {
+		log.Fatal(err)
+	}
+
+	s.metrics.outboxDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "alert_outbox_depth",
+		Help: "The number of failed alert deliveries waiting to be retried",
+	})
+	if err := prometheus.Register(s.metrics.outboxDepth); err != nil && err.Error() != "duplicate metrics collector for alert_outbox_depth registration attempted" {
+		log.Fatal(err)
+	}
+
+	s.metrics.deadLetters = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "alert_dead_letters",
+		Help: "The number of alert deliveries given up after all retries",
+	})
+	if err := prometheus.Register(s.metrics.deadLetters); err != nil && err.Error() != "duplicate metrics collector for alert_dead_letters registration attempted" {
+		log.Fatal(err)
+	}
+
+	s.metrics.deliveryFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "alert_delivery_failures_total",
+		Help: "Failed alert delivery attempts by notifier",
+	}, []string{"notifier"})
+	if err := prometheus.Register(s.metrics.deliveryFailures); err != nil && err.Error() != "duplicate metrics collector for alert_delivery_failures_total registration attempted" {
+		log.Fatal(err)
+	}
 }
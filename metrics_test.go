@@ -18,7 +18,7 @@ func (s *testService) initMetricsWithRegistry(reg prometheus.Registerer) {
 	}, []string{"url"})
 	reg.MustRegister(s.metrics.siteStatus)
 
-	s.metrics.sites = prometheus.NewCounter(prometheus.CounterOpts{
+	s.metrics.sites = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "sites_test",
 		Help: "Test: The number of monitored sites",
 	})
@@ -48,8 +48,8 @@ func TestInitMetricsFields(t *testing.T) {
 	if ts.metrics.sites == nil {
 		t.Errorf("sites not initialized")
 	} else {
-		if _, ok := ts.metrics.sites.(prometheus.Counter); !ok {
-			t.Errorf("sites does not implement prometheus.Counter")
+		if _, ok := ts.metrics.sites.(prometheus.Gauge); !ok {
+			t.Errorf("sites does not implement prometheus.Gauge")
 		}
 	}
 	if ts.metrics.offlineSites == nil {
@@ -3,112 +3,343 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
-	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// maxBodySize is how much of a response body a check reads. The rest is
+// not transferred, so huge responses don't stretch the total duration.
+const maxBodySize = 10 << 20
+
 func (s *Service) recordMetrics(ctx context.Context) {
-	go func() {
-		client := &http.Client{Timeout: 10 * time.Second}
-
-		for {
-			start := time.Now()
-			select {
-			case <-ctx.Done():
-				log.Println("Shutting down monitoring goroutine...")
-				return
-			default:
-			}
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	s.runCtx = ctx
+	s.syncTargets(s.config.targets)
+}
 
-			s.metrics.offlineSites.Set(0)
+// monitorTarget checks a single target on its own interval until ctx is done.
+func (s *Service) monitorTarget(ctx context.Context, t target) {
+	client := &http.Client{Timeout: t.timeout}
 
-			var wg sync.WaitGroup
-			for _, url := range s.config.urls {
-				wg.Add(1)
-				go func(url string) {
-					defer wg.Done()
-					s.checkSiteStatus(url, client)
-				}(url)
-			}
-			wg.Wait()
+	for {
+		start := time.Now()
+		s.checkSiteStatus(t, client)
 
-			elapsed := time.Since(start)
-			if elapsed < s.config.checkInterval {
-				time.Sleep(s.config.checkInterval - elapsed)
-			}
+		wait := t.interval - time.Since(start)
+		if wait < 0 {
+			wait = 0
+		}
+		select {
+		case <-ctx.Done():
+			log.Printf("Stopping monitoring of %s...", t.url)
+			return
+		case <-time.After(wait):
 		}
-	}()
+	}
 }
 
-func (s *Service) checkSiteStatus(url string, client *http.Client) {
+func (s *Service) checkSiteStatus(t target, client *http.Client) {
+	url := t.url
+	method := t.method
+	if method == "" {
+		method = http.MethodGet
+	}
+
 	// Create HTTP request
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
-		s.handleSiteError(url, fmt.Sprintf("unreachable: %v", err))
+		reason := fmt.Sprintf("unreachable: %v", err)
+		s.recordCheck(url, CheckResult{At: time.Now(), Error: reason})
+		s.handleSiteError(t, reason)
 		return
 	}
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
 
 	// Execute request
+	timer := &phaseTimer{}
+	req = timer.trace(req)
+	start := time.Now()
 	res, err := client.Do(req)
 	if err != nil {
-		s.handleSiteError(url, fmt.Sprintf("unreachable: %v", err))
+		reason := fmt.Sprintf("unreachable: %v", err)
+		s.recordCheck(url, CheckResult{At: start, Latency: time.Since(start), Error: reason})
+		s.handleSiteError(t, reason)
 		return
 	}
 	defer res.Body.Close()
+	// The total duration includes the transfer of the body
+	if _, err := io.Copy(io.Discard, io.LimitReader(res.Body, maxBodySize)); err != nil {
+		reason := fmt.Sprintf("failed reading the response body: %v", err)
+		s.recordCheck(url, CheckResult{At: start, Latency: time.Since(start), StatusCode: res.StatusCode, Error: reason})
+		s.handleSiteError(t, reason)
+		return
+	}
+	latency := time.Since(start)
+	s.observeTimings(url, timer.phases(latency))
 
 	// Check for nil response
 	if res == nil {
-		s.handleSiteError(url, "returned nil response")
+		s.handleSiteError(t, "returned nil response")
 		return
 	}
 
+	check := CheckResult{At: start, Latency: latency, StatusCode: res.StatusCode,
+		OK: res.StatusCode >= 200 && res.StatusCode < 300}
+	s.recordCheck(url, check)
+
 	// Update metrics
 	s.metrics.siteStatus.Delete(prometheus.Labels{"url": url})
 	s.metrics.siteStatus.With(prometheus.Labels{"url": url}).Set(float64(res.StatusCode))
 
 	// Check status code
 	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		s.handleSiteError(url, fmt.Sprintf("returned status %d", res.StatusCode))
+		s.handleSiteError(t, fmt.Sprintf("returned status %d", res.StatusCode))
 	} else {
 		// Site is healthy
 		s.metrics.errorCounter.With(prometheus.Labels{"url": url}).Set(0)
-		s.handleSiteRecovery(url)
+		s.handleSiteRecovery(t)
+		s.handleLatency(t, latency)
 	}
 }
 
-func (s *Service) handleSiteError(url, reason string) {
+func (s *Service) handleSiteError(t target, reason string) {
+	url := t.url
 	s.metrics.siteStatus.With(prometheus.Labels{"url": url}).Set(0)
-	s.metrics.offlineSites.Inc()
 	s.metrics.errorCounter.With(prometheus.Labels{"url": url}).Inc()
 
+	now := time.Now()
+	silenced := s.isSilenced(t, now)
+	s.updateSilenced(t, silenced)
+
 	s.mu.Lock()
+	flapStarted, flapEnded := s.trackFlappingLocked(url, false)
+	flapping := s.isFlappingLocked(url)
+	s.recordOutcomeLocked(t, false)
 	alreadyOffline := s.offlineMap[url]
 	s.failureCount[url]++
-	shouldAlert := !alreadyOffline && s.failureCount[url] >= s.config.alertThreshold
-	if shouldAlert {
+	s.successCount[url] = 0
+	if s.failureCount[url] == 1 {
+		s.downSince[url] = now
+	}
+	s.lastReason[url] = reason
+	reachedThreshold := !alreadyOffline && s.failuresLocked(t) >= s.thresholdFor(t)
+	if reachedThreshold {
 		s.offlineMap[url] = true
 	}
+	_, wasSuppressed := s.suppressed[url]
+	wasSilenced := s.silencedDown[url]
+	shouldAlert, remind, escalate := false, false, 0
+	if reachedThreshold || wasSuppressed || wasSilenced || (flapEnded && alreadyOffline) {
+		if parent := s.failingAncestorLocked(t); parent != "" {
+			s.suppressLocked(t, parent)
+		} else if silenced {
+			if !wasSilenced {
+				log.Printf("Silenced DOWN alert for %s", url)
+			}
+			s.silencedDown[url] = true
+		} else if !flapping {
+			// Alert when reaching the threshold, or once the parent is
+			// healthy again, the silence ended or the target stopped
+			// flapping while t is still down. Transitions of flapping
+			// targets are only reported once they are stable again.
+			s.clearSuppressionLocked(url)
+			delete(s.silencedDown, url)
+			shouldAlert = true
+			s.lastAlert[url] = now
+			s.reminders[url] = 0
+			s.startEscalationLocked(t, now)
+		}
+	} else if alreadyOffline && !silenced && !flapping {
+		escalate = s.escalationDueLocked(t, now)
+		remind = s.reminderDueLocked(t, now)
+	}
+	_, suppressed := s.suppressed[url]
+	quiet := suppressed || silenced || s.silencedDown[url]
+	s.resetLatencyLocked(url)
+	s.updateStateLocked(url)
+	s.updateOfflineSitesLocked()
 	s.mu.Unlock()
 
-	if shouldAlert {
-		s.sendSiteDownAlert(url, reason)
+	if flapStarted {
+		if !quiet {
+			s.sendFlapping(t)
+		}
+	} else if shouldAlert {
+		s.sendSiteDownAlert(t, reason)
+	} else if alreadyOffline && !quiet && !flapping {
+		s.notifyStillDown(t, reason)
+		if escalate > 0 {
+			s.sendEscalation(t, reason, escalate)
+		}
+		if remind {
+			s.sendReminder(t, reason)
+		}
 	}
 }
 
-func (s *Service) handleSiteRecovery(url string) {
+func (s *Service) handleSiteRecovery(t target) {
+	url := t.url
+	silenced := s.isSilenced(t, time.Now())
+	s.updateSilenced(t, silenced)
+
 	s.mu.Lock()
+	flapStarted, flapEnded := s.trackFlappingLocked(url, true)
+	flapping := s.isFlappingLocked(url)
+	s.recordOutcomeLocked(t, true)
+	s.successCount[url]++
+	successes, needed := s.successCount[url], s.recoveryThresholdFor(t)
 	wasOffline := s.offlineMap[url]
-	if wasOffline {
+	// A down target stays down until enough consecutive checks succeeded.
+	recovering := wasOffline && successes < needed
+	if wasOffline && !recovering {
 		s.offlineMap[url] = false
 	}
+	// With an alert window, failures are remembered until they left the
+	// window, so they count towards the next alert.
+	keepFailures := recovering || (!wasOffline && s.failuresLocked(t) > 0)
+	// No recovery notice for an outage whose DOWN alert was muted. An outage
+	// alerted before a silence started still gets its recovery notice, so
+	// that incidents and chat threads are closed.
+	downMuted := s.silencedDown[url]
+	muted := silenced || downMuted
+	s.updateStateLocked(url)
+	s.mu.Unlock()
+
+	switch {
+	case flapping:
+		if flapStarted && !muted {
+			s.sendFlapping(t)
+		}
+	case recovering:
+		log.Printf("%s succeeded %d of %d checks needed to recover", url, successes, needed)
+	case wasOffline && downMuted:
+		log.Printf("Silenced recovery notice for %s", url)
+	case wasOffline || (flapEnded && !muted):
+		s.sendSiteRecoveryAlert(t)
+	}
+	if keepFailures {
+		return
+	}
+
+	s.mu.Lock()
 	s.failureCount[url] = 0 // Reset failure count on recovery
+	delete(s.recentChecks, url)
+	delete(s.downSince, url)
+	delete(s.lastReason, url)
+	delete(s.lastAlert, url)
+	delete(s.reminders, url)
+	s.clearSuppressionLocked(url)
+	delete(s.silencedDown, url)
+	s.clearAckLocked(url)
+	delete(s.escalations, url)
+	s.updateOfflineSitesLocked()
 	s.mu.Unlock()
+}
+
+// CheckResult is the outcome of a single check, kept in the target's history.
+type CheckResult struct {
+	At         time.Time     `json:"at"`
+	Latency    time.Duration `json:"latency_ns"`
+	StatusCode int           `json:"status_code"` // 0 if there was no response
+	OK         bool          `json:"ok"`          // 2xx response
+	Error      string        `json:"error,omitempty"`
+}
+
+// recordCheck stores the status and history of a completed check.
+func (s *Service) recordCheck(url string, res CheckResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastStatus[url] = res.StatusCode
+	size := s.config.historySize
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	h := append(s.history[url], res)
+	if len(h) > size {
+		h = h[len(h)-size:]
+	}
+	s.history[url] = h
+}
+
+// thresholdFor returns the target's alert threshold, falling back to the
+// global one for targets that don't set it.
+func (s *Service) thresholdFor(t target) int {
+	if t.alertThreshold > 0 {
+		return t.alertThreshold
+	}
+	return s.config.alertThreshold
+}
+
+// recoveryThresholdFor returns the number of consecutive successful checks
+// after which a down target is up again.
+func (s *Service) recoveryThresholdFor(t target) int {
+	if t.recoveryThreshold > 0 {
+		return t.recoveryThreshold
+	}
+	return max(s.config.recoveryThreshold, 1)
+}
+
+// alertWindowFor returns the number of recent checks the failures are
+// counted in, 0 for consecutive failures.
+func (s *Service) alertWindowFor(t target) int {
+	if t.alertWindow > 0 {
+		return t.alertWindow
+	}
+	return s.config.alertWindow
+}
+
+// recordOutcomeLocked keeps the result of a check for the alert window of
+// t. Callers must hold s.mu.
+func (s *Service) recordOutcomeLocked(t target, ok bool) {
+	window := s.alertWindowFor(t)
+	if window <= 0 {
+		delete(s.recentChecks, t.url)
+		return
+	}
+	checks := append(s.recentChecks[t.url], ok)
+	if len(checks) > window {
+		checks = checks[len(checks)-window:]
+	}
+	s.recentChecks[t.url] = checks
+}
 
-	if wasOffline {
-		s.sendSiteRecoveryAlert(url)
+// failuresLocked returns the failures counted against the alert threshold
+// of t: the failed checks in its alert window, or else the consecutive
+// failures. Callers must hold s.mu.
+func (s *Service) failuresLocked(t target) int {
+	if s.alertWindowFor(t) <= 0 {
+		return s.failureCount[t.url]
+	}
+	n := 0
+	for _, ok := range s.recentChecks[t.url] {
+		if !ok {
+			n++
+		}
+	}
+	return n
+}
+
+// lastCheckFailedLocked reports whether the latest check of url failed.
+// Callers must hold s.mu.
+func (s *Service) lastCheckFailedLocked(url string) bool {
+	return s.failureCount[url] > 0 && s.successCount[url] == 0
+}
+
+// updateOfflineSitesLocked sets offline_sites to the number of targets whose
+// last check failed. Callers must hold s.mu.
+func (s *Service) updateOfflineSitesLocked() {
+	failing := 0
+	for url := range s.failureCount {
+		if s.lastCheckFailedLocked(url) {
+			failing++
+		}
 	}
+	s.metrics.offlineSites.Set(float64(failing))
 }
@@ -2,8 +2,11 @@ package main
 
 import (
 	"errors"
+	"io"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -36,9 +39,9 @@ type mockEmailSender struct {
 	calls       int
 }
 
-func (m *mockEmailSender) Send(subject, body string) error {
-	m.lastSubject = subject
-	m.lastBody = body
+func (m *mockEmailSender) Send(msg emailMessage) error {
+	m.lastSubject = msg.subject
+	m.lastBody = msg.text
 	m.calls++
 	return nil
 }
@@ -48,20 +51,52 @@ func newTestService() *Service {
 	s := &Service{
 		offlineMap:   make(map[string]bool),
 		failureCount: make(map[string]int),
+		successCount: make(map[string]int),
+		recentChecks: make(map[string][]bool),
+		downSince:    make(map[string]time.Time),
+		lastReason:   make(map[string]string),
+		lastStatus:   make(map[string]int),
+		history:      make(map[string][]CheckResult),
+		lastAlert:    make(map[string]time.Time),
+		reminders:    make(map[string]int),
+		suppressed:   make(map[string]string),
+		silencedDown: make(map[string]bool),
+		acked:        make(map[string]Acknowledgement),
+		escalations:  make(map[string]escalationState),
+		flaps:        make(map[string]*flapState),
+		latency:      make(map[string]*latencyState),
 		emailSender:  &mockEmailSender{},
 	}
 	s.metrics.siteStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_site_status", Help: ""}, []string{"url"})
 	s.metrics.offlineSites = prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_offline_sites", Help: ""})
 	s.metrics.errorCounter = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_error_counter", Help: ""}, []string{"url"})
+	s.metrics.unreachable = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_unreachable", Help: ""}, []string{"url", "parent"})
+	s.metrics.silenced = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_silenced", Help: ""}, []string{"url"})
+	s.metrics.acknowledged = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_acknowledged", Help: ""}, []string{"url"})
+	s.metrics.flapping = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_flapping", Help: ""}, []string{"url"})
+	s.metrics.state = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_state", Help: ""}, []string{"url"})
+	s.metrics.phaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_phase_duration", Help: ""}, []string{"url", "phase"})
+	s.metrics.phaseLast = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_phase_last", Help: ""}, []string{"url", "phase"})
 	return s
 }
 
+// newRecordingService returns a test service monitoring targets that alerts
+// on the first failure and records its events in a notifier named "rec".
+func newRecordingService(targets ...target) (*Service, *recordingNotifier) {
+	s := newTestService()
+	rec := &recordingNotifier{}
+	s.notifiers = []notifierEntry{{name: "rec", notifier: rec}}
+	s.config.alertThreshold = 1
+	s.config.targets = targets
+	return s, rec
+}
+
 func TestCheckSiteStatus_Error(t *testing.T) {
 	s := newTestService()
 	client := &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
 		return nil, errors.New("fail")
 	})}
-	s.checkSiteStatus("https://fail.com", client)
+	s.checkSiteStatus(target{url: "https://fail.com"}, client)
 	if !s.offlineMap["https://fail.com"] {
 		t.Errorf("offlineMap not set for error")
 	}
@@ -77,7 +112,7 @@ func TestCheckSiteStatus_Recovery(t *testing.T) {
 	client := &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
 		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
 	})}
-	s.checkSiteStatus("https://ok.com", client)
+	s.checkSiteStatus(target{url: "https://ok.com"}, client)
 	if s.offlineMap["https://ok.com"] {
 		t.Errorf("offlineMap not reset on recovery")
 	}
@@ -94,13 +129,13 @@ func TestCheckSiteStatus_Threshold(t *testing.T) {
 		return nil, errors.New("fail")
 	})}
 	for i := 0; i < 2; i++ {
-		s.checkSiteStatus("https://fail.com", client)
+		s.checkSiteStatus(target{url: "https://fail.com"}, client)
 	}
 	me := s.emailSender.(*mockEmailSender)
 	if me.calls != 0 {
 		t.Errorf("expected no alert before threshold, got %d", me.calls)
 	}
-	s.checkSiteStatus("https://fail.com", client)
+	s.checkSiteStatus(target{url: "https://fail.com"}, client)
 	if me.calls != 1 {
 		t.Errorf("expected alert at threshold, got %d", me.calls)
 	}
@@ -115,19 +150,144 @@ func TestCheckSiteStatus_ThresholdResetOnRecovery(t *testing.T) {
 	okClient := &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
 		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
 	})}
-	s.checkSiteStatus("https://fail.com", failClient)
-	s.checkSiteStatus("https://fail.com", failClient)
+	s.checkSiteStatus(target{url: "https://fail.com"}, failClient)
+	s.checkSiteStatus(target{url: "https://fail.com"}, failClient)
 	me := s.emailSender.(*mockEmailSender)
 	if me.calls != 1 {
 		t.Errorf("expected alert at threshold, got %d", me.calls)
 	}
 	// Now recover
-	s.checkSiteStatus("https://fail.com", okClient)
+	s.checkSiteStatus(target{url: "https://fail.com"}, okClient)
 	if s.failureCount["https://fail.com"] != 0 {
 		t.Errorf("expected failureCount reset on recovery, got %d", s.failureCount["https://fail.com"])
 	}
 }
 
+func TestCheckSiteStatus_TargetSettings(t *testing.T) {
+	s := newTestService()
+	s.config.alertThreshold = 1
+	var gotMethod, gotHeader string
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		gotMethod = r.Method
+		gotHeader = r.Header.Get("X-Token")
+		return &http.Response{StatusCode: 503, Body: http.NoBody}, nil
+	})}
+	tgt := target{url: "https://api.com", method: "HEAD", headers: map[string]string{"X-Token": "secret"}, alertThreshold: 2}
+	s.checkSiteStatus(tgt, client)
+	if gotMethod != "HEAD" || gotHeader != "secret" {
+		t.Errorf("expected HEAD with X-Token header, got %s %q", gotMethod, gotHeader)
+	}
+	me := s.emailSender.(*mockEmailSender)
+	if me.calls != 0 {
+		t.Errorf("expected target threshold to override global one, got %d alerts", me.calls)
+	}
+	s.checkSiteStatus(tgt, client)
+	if me.calls != 1 {
+		t.Errorf("expected alert at target threshold, got %d", me.calls)
+	}
+}
+
+func TestRecoveryThreshold(t *testing.T) {
+	tgt := target{url: "https://lb.com", recoveryThreshold: 3}
+	s, rec := newRecordingService()
+
+	s.handleSiteError(tgt, "returned status 502")
+	since := s.downSince[tgt.url]
+	s.handleSiteRecovery(tgt)
+	s.handleSiteRecovery(tgt)
+	s.handleSiteError(tgt, "returned status 502")
+	if got := rec.received(); len(got) != 1 || !s.offlineMap[tgt.url] {
+		t.Fatalf("a failure before the recovery threshold should keep the target down, got %+v", got)
+	}
+	if !s.downSince[tgt.url].Equal(since) || s.failureCount[tgt.url] != 2 {
+		t.Errorf("the outage should continue, got since %v and %d failures", s.downSince[tgt.url], s.failureCount[tgt.url])
+	}
+
+	for range 2 {
+		s.handleSiteRecovery(tgt)
+	}
+	if len(rec.received()) != 1 {
+		t.Fatal("no recovery notice before the recovery threshold")
+	}
+	s.handleSiteRecovery(tgt)
+	got := rec.received()
+	if len(got) != 2 || got[1].State != StateUp || got[1].FailureCount != 2 || !got[1].Since.Equal(since) {
+		t.Fatalf("expected the recovery after 3 successes, got %+v", got)
+	}
+	if s.offlineMap[tgt.url] || s.failureCount[tgt.url] != 0 {
+		t.Error("failure state should be reset on recovery")
+	}
+}
+
+func TestAlertWindow(t *testing.T) {
+	s, rec := newRecordingService()
+	s.config.alertThreshold = 3
+	s.config.alertWindow = 5
+	tgt := target{url: "https://lb.com", alertThreshold: 3}
+
+	// F S F S F: 3 of the last 5 checks failed.
+	s.handleSiteError(tgt, "returned status 502")
+	since := s.downSince[tgt.url]
+	s.handleSiteRecovery(tgt)
+	s.handleSiteError(tgt, "returned status 502")
+	s.handleSiteRecovery(tgt)
+	if len(rec.received()) != 0 {
+		t.Fatal("no alert below the threshold")
+	}
+	s.handleSiteError(tgt, "returned status 503")
+	got := rec.received()
+	if len(got) != 1 || got[0].State != StateDown || got[0].FailureCount != 3 || !got[0].Since.Equal(since) {
+		t.Fatalf("expected a DOWN alert for 3 failures in 5 checks, got %+v", got)
+	}
+
+	s.handleSiteRecovery(tgt)
+	if got := rec.received(); len(got) != 2 || got[1].State != StateUp {
+		t.Fatalf("expected a recovery, got %+v", got)
+	}
+	if len(s.recentChecks[tgt.url]) != 0 || s.failureCount[tgt.url] != 0 {
+		t.Error("the alert window should start over after a recovery")
+	}
+
+	// Failures that left the window don't count.
+	for _, ok := range []bool{false, true, true, true, true, false, false} {
+		if ok {
+			s.handleSiteRecovery(tgt)
+		} else {
+			s.handleSiteError(tgt, "returned status 502")
+		}
+	}
+	if len(rec.received()) != 2 {
+		t.Errorf("expected no alert for 2 failures in the window, got %+v", rec.received())
+	}
+}
+
 type roundTripFunc func(*http.Request) (*http.Response, error)
 
 func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestCheckSiteStatusRecordsHistory(t *testing.T) {
+	s := newTestService()
+	s.config.historySize = 2
+	s.config.alertThreshold = 5
+	statuses := []int{200, 503, 500}
+	client := &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		code := statuses[0]
+		statuses = statuses[1:]
+		return &http.Response{StatusCode: code, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})}
+	tg := target{url: "https://api.example.com"}
+	for range 3 {
+		s.checkSiteStatus(tg, client)
+	}
+
+	ev := s.newAlertEvent(tg, StateDown, "returned status 500")
+	if len(ev.History) != 2 {
+		t.Fatalf("expected 2 history entries, got %+v", ev.History)
+	}
+	if ev.History[0].StatusCode != 500 || ev.History[1].StatusCode != 503 || ev.History[0].OK {
+		t.Errorf("expected newest failed checks first, got %+v", ev.History)
+	}
+	if ev.StatusCode != 500 {
+		t.Errorf("expected status code 500, got %d", ev.StatusCode)
+	}
+}
@@ -0,0 +1,582 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultNotifierTimeout = 10 * time.Second
+
+// AlertState is the state a target transitioned into.
+type AlertState string
+
+const (
+	StateDown     AlertState = "down"
+	StateUp       AlertState = "up"
+	StateFlapping AlertState = "flapping" // The target alternates between down and up
+	StateDegraded AlertState = "degraded" // The target responds slower than its warning latency
+)
+
+// AlertEvent describes a state transition of a target. Its exported fields
+// are also available to notifier templates.
+type AlertEvent struct {
+	Name         string            `json:"name"`
+	URL          string            `json:"url"`
+	State        AlertState        `json:"state"`
+	Reason       string            `json:"reason"`            // Failure reason; for recoveries the reason of the outage
+	FailureCount int               `json:"failure_count"`     // Consecutive failures, for recoveries the count before recovering
+	StatusCode   int               `json:"status_code"`       // HTTP status of the latest check, 0 if it got no response
+	History      []CheckResult     `json:"history"`           // Recent checks, newest first
+	Reminder     int               `json:"reminder"`          // Reminders sent for the outage, 0 for the first DOWN alert
+	Escalated    bool              `json:"escalated"`         // The outage reached reminders.escalate_after
+	Parent       string            `json:"parent,omitempty"`  // Parent target the target was unreachable due to
+	AckURL       string            `json:"ack_url,omitempty"` // Signed link acknowledging the outage, for unacknowledged DOWN events
+	AckedBy      string            `json:"acked_by,omitempty"`
+	AckedAt      time.Time         `json:"acked_at,omitzero"`
+	Level        int               `json:"level,omitempty"`       // Escalation level reached by the outage, 0 without a policy
+	OnCall       *OnCallPerson     `json:"oncall,omitempty"`      // On-call person of the highest reached level with a schedule
+	FlapRate     float64           `json:"flap_rate,omitempty"`   // Percent state change over the flap detection window
+	FlapEnded    bool              `json:"flap_ended,omitempty"`  // The target stopped flapping, the event reports its current state
+	SlowChecks   int               `json:"slow_checks,omitempty"` // Consecutive checks above the warning latency, for DEGRADED events
+	From         AlertState        `json:"from,omitempty"`        // State an UP event leaves if it is not down, i.e. degraded
+	Severity     string            `json:"severity,omitempty"`    // warning or critical, for DEGRADED events
+	Since        time.Time         `json:"since"`
+	At           time.Time         `json:"at"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	DashboardURL string            `json:"dashboard_url,omitempty"`
+
+	target    target
+	policy    *escalationPolicy
+	levelOnly bool // Escalation to Level, only for the notifiers of that level
+}
+
+// Downtime returns how long the target has been failing at the time of the event.
+func (e AlertEvent) Downtime() time.Duration {
+	if e.Since.IsZero() {
+		return 0
+	}
+	return e.At.Sub(e.Since).Round(time.Second)
+}
+
+// latencyOnly reports whether e is about the degradation of a target that
+// is not down: a DEGRADED event or the UP event ending it.
+func (e AlertEvent) latencyOnly() bool {
+	return e.State == StateDegraded || e.From == StateDegraded
+}
+
+// Notifier delivers alert events to a single channel.
+type Notifier interface {
+	Notify(ev AlertEvent) error
+}
+
+// Repeater is implemented by notifiers that want to hear about every failed
+// check of a target that is already down, e.g. to keep an alert from expiring.
+type Repeater interface {
+	Repeat(ev AlertEvent) error
+}
+
+// notifierConfig is a configured notification channel.
+type notifierConfig struct {
+	name         string
+	kind         string
+	enabled      bool
+	filter       notifierFilter
+	timeout      time.Duration // HTTP timeout of webhook based notifiers
+	slack        slackConfig
+	pagerduty    pagerDutyConfig
+	webhook      webhookConfig
+	teams        teamsConfig
+	discord      discordConfig
+	telegram     telegramConfig
+	ntfy         ntfyConfig
+	gotify       gotifyConfig
+	alertmanager alertmanagerConfig
+}
+
+// notifierFilter restricts the events a notifier receives. Empty fields match everything.
+type notifierFilter struct {
+	states  []AlertState
+	targets []string          // Target names
+	labels  map[string]string // All labels must match
+}
+
+func (f notifierFilter) matches(ev AlertEvent) bool {
+	if len(f.states) > 0 && !slices.Contains(f.states, ev.State) {
+		return false
+	}
+	if len(f.targets) > 0 && !slices.Contains(f.targets, ev.Name) {
+		return false
+	}
+	for k, v := range f.labels {
+		if ev.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// notifierEntry pairs a notifier with its name and filter.
+type notifierEntry struct {
+	name       string
+	filter     notifierFilter
+	escalation bool // Only receives escalated outages, see reminders.escalate_to
+	notifier   Notifier
+}
+
+type fileNotifier struct {
+	Name         string           `yaml:"name" json:"name"`
+	Type         string           `yaml:"type" json:"type"`
+	Enabled      *bool            `yaml:"enabled" json:"enabled"`
+	Filter       fileFilter       `yaml:"filter" json:"filter"`
+	Timeout      string           `yaml:"timeout" json:"timeout"`
+	Slack        fileSlack        `yaml:"slack" json:"slack"`
+	PagerDuty    filePagerDuty    `yaml:"pagerduty" json:"pagerduty"`
+	Webhook      fileWebhook      `yaml:"webhook" json:"webhook"`
+	Teams        fileTeams        `yaml:"teams" json:"teams"`
+	Discord      fileDiscord      `yaml:"discord" json:"discord"`
+	Telegram     fileTelegram     `yaml:"telegram" json:"telegram"`
+	Ntfy         fileNtfy         `yaml:"ntfy" json:"ntfy"`
+	Gotify       fileGotify       `yaml:"gotify" json:"gotify"`
+	Alertmanager fileAlertmanager `yaml:"alertmanager" json:"alertmanager"`
+}
+
+type fileFilter struct {
+	States  []string          `yaml:"states" json:"states"`
+	Targets []string          `yaml:"targets" json:"targets"`
+	Labels  map[string]string `yaml:"labels" json:"labels"`
+}
+
+// parseNotifiers converts the notifiers section of the config file.
+func parseNotifiers(fns []fileNotifier, errs *configErrors) []notifierConfig {
+	var out []notifierConfig
+	for i, fn := range fns {
+		field := fmt.Sprintf("notifiers[%d]", i)
+		nc := notifierConfig{
+			name:    fn.Name,
+			kind:    strings.ToLower(fn.Type),
+			enabled: fn.Enabled == nil || *fn.Enabled,
+			filter: notifierFilter{
+				targets: fn.Filter.Targets,
+				labels:  fn.Filter.Labels,
+			},
+			timeout: defaultNotifierTimeout,
+		}
+		switch nc.kind {
+		case "slack":
+			nc.slack = parseSlack(fn.Slack)
+		case "pagerduty":
+			nc.pagerduty = parsePagerDuty(fn.PagerDuty)
+		case "webhook":
+			nc.webhook = parseWebhook(fn.Webhook, field+".webhook", errs)
+		case "teams":
+			nc.teams = teamsConfig{webhookURL: fn.Teams.WebhookURL}
+		case "discord":
+			nc.discord = parseDiscord(fn.Discord)
+		case "telegram":
+			nc.telegram = parseTelegram(fn.Telegram)
+		case "ntfy":
+			nc.ntfy = parseNtfy(fn.Ntfy)
+		case "gotify":
+			nc.gotify = parseGotify(fn.Gotify)
+		case "alertmanager":
+			nc.alertmanager = parseAlertmanager(fn.Alertmanager, field+".alertmanager", errs)
+		}
+		if fn.Timeout != "" {
+			errs.duration(field+".timeout", fn.Timeout, &nc.timeout)
+		}
+		if nc.name == "" {
+			nc.name = nc.kind
+		}
+		for j, st := range fn.Filter.States {
+			state := AlertState(strings.ToLower(st))
+			if state != StateDown && state != StateUp && state != StateFlapping && state != StateDegraded {
+				errs.add(fmt.Sprintf("%s.filter.states[%d]", field, j), "unknown state %q (use down, up, flapping or degraded)", st)
+			}
+			nc.filter.states = append(nc.filter.states, state)
+		}
+		out = append(out, nc)
+	}
+	return out
+}
+
+// validateNotifiers checks notifier names, types and filters against the targets.
+func validateNotifiers(cfg appConfig, errs *configErrors) {
+	names := make(map[string]bool)
+	for _, t := range cfg.targets {
+		names[t.name] = true
+	}
+	seen := make(map[string]bool)
+	for i, nc := range cfg.notifiers {
+		field := fmt.Sprintf("notifiers[%d]", i)
+		if seen[nc.name] {
+			errs.add(field+".name", "duplicate notifier name %q", nc.name)
+		}
+		seen[nc.name] = true
+		switch nc.kind {
+		case "email":
+			if nc.enabled && cfg.smtpServer == "" {
+				errs.add(field, "email notifier requires SMTP settings")
+			}
+		case "slack":
+			validateSlack(nc.slack, field+".slack", names, errs)
+		case "pagerduty":
+			validatePagerDuty(nc.pagerduty, field+".pagerduty", names, errs)
+		case "webhook":
+			validateWebhook(nc.webhook, field+".webhook", errs)
+		case "teams":
+			requireURL(errs, field+".teams.webhook_url", nc.teams.webhookURL, "teams")
+		case "discord":
+			requireURL(errs, field+".discord.webhook_url", nc.discord.webhookURL, "discord")
+		case "telegram":
+			validateTelegram(nc.telegram, field+".telegram", errs)
+		case "ntfy":
+			validateNtfy(nc.ntfy, field+".ntfy", errs)
+		case "gotify":
+			validateGotify(nc.gotify, field+".gotify", errs)
+		case "alertmanager":
+			validateAlertmanager(nc.alertmanager, field+".alertmanager", errs)
+		case "":
+			errs.add(field+".type", "missing notifier type")
+		default:
+			errs.add(field+".type", "unknown notifier type %q", nc.kind)
+		}
+		for j, name := range nc.filter.targets {
+			if !names[name] {
+				errs.add(fmt.Sprintf("%s.filter.targets[%d]", field, j), "unknown target %q", name)
+			}
+		}
+	}
+}
+
+// buildNotifiers creates the enabled notifiers of cfg. Without a notifiers
+// section, alerts are sent by email.
+func (s *Service) buildNotifiers(cfg appConfig) ([]notifierEntry, error) {
+	if len(cfg.notifiers) == 0 {
+		return []notifierEntry{{name: "email", notifier: &emailNotifier{svc: s}}}, nil
+	}
+	entries := []notifierEntry{}
+	for _, nc := range cfg.notifiers {
+		if !nc.enabled {
+			continue
+		}
+		var n Notifier
+		switch nc.kind {
+		case "email":
+			n = &emailNotifier{svc: s}
+		case "slack":
+			n = newSlackNotifier(nc)
+		case "pagerduty":
+			n = newPagerDutyNotifier(nc)
+		case "webhook":
+			n = newWebhookNotifier(nc)
+		case "teams":
+			n = newTeamsNotifier(nc)
+		case "discord":
+			n = newDiscordNotifier(nc)
+		case "telegram":
+			n = newTelegramNotifier(nc)
+		case "ntfy":
+			n = newNtfyNotifier(nc)
+		case "gotify":
+			n = newGotifyNotifier(nc)
+		case "alertmanager":
+			n = newAlertmanagerNotifier(nc)
+		default:
+			return nil, fmt.Errorf("notifier %s: unknown type %q", nc.name, nc.kind)
+		}
+		entries = append(entries, notifierEntry{
+			name:       nc.name,
+			filter:     nc.filter,
+			escalation: slices.Contains(cfg.reminders.escalateTo, nc.name),
+			notifier:   n,
+		})
+	}
+	return entries, nil
+}
+
+// activeNotifiers returns the notifiers events are fanned out to.
+func (s *Service) activeNotifiers() []notifierEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.notifiers == nil && s.emailSender != nil {
+		return []notifierEntry{{name: "email", notifier: &emailNotifier{svc: s}}}
+	}
+	return s.notifiers
+}
+
+// accepts reports whether the entry receives ev. Escalation notifiers only
+// receive events of escalated outages.
+func (e notifierEntry) accepts(ev AlertEvent) bool {
+	return e.filter.matches(ev) && (!e.escalation || ev.Escalated) && ev.policy.admits(e.name, ev)
+}
+
+// notify fans ev out to all accepting notifiers in parallel and waits for them.
+func (s *Service) notify(ev AlertEvent) {
+	var wg sync.WaitGroup
+	for _, entry := range s.activeNotifiers() {
+		if !entry.accepts(ev) {
+			continue
+		}
+		wg.Add(1)
+		go func(entry notifierEntry) {
+			defer wg.Done()
+			s.deliver(entry, ev)
+		}(entry)
+	}
+	wg.Wait()
+}
+
+// deliver sends ev to a single notifier and queues it for a retry if that fails.
+// A delivered event supersedes the queued older events of its target.
+func (s *Service) deliver(entry notifierEntry, ev AlertEvent) {
+	err := entry.notifier.Notify(ev)
+	if err != nil {
+		log.Printf("Notifier %s failed for %s (%s): %v", entry.name, ev.URL, ev.State, err)
+	}
+	if s.outbox == nil {
+		return
+	}
+	if err != nil {
+		s.outbox.enqueue(entry.name, ev, err)
+	} else {
+		s.outbox.supersede(entry.name, ev)
+	}
+}
+
+// notifyStillDown passes a DOWN event for a target that was already down to
+// the matching notifiers implementing Repeater.
+func (s *Service) notifyStillDown(t target, reason string) {
+	var ev AlertEvent
+	for _, entry := range s.activeNotifiers() {
+		r, ok := entry.notifier.(Repeater)
+		if !ok {
+			continue
+		}
+		if ev.At.IsZero() {
+			ev = s.newAlertEvent(t, StateDown, reason)
+		}
+		if !entry.accepts(ev) {
+			continue
+		}
+		if err := r.Repeat(ev); err != nil {
+			log.Printf("Notifier %s failed to repeat alert for %s: %v", entry.name, ev.URL, err)
+		}
+	}
+}
+
+// newAlertEvent builds an event for t from the current failure state.
+func (s *Service) newAlertEvent(t target, state AlertState, reason string) AlertEvent {
+	s.mu.Lock()
+	since := s.downSince[t.url]
+	failures := s.failureCount[t.url]
+	status := s.lastStatus[t.url]
+	recent := s.history[t.url]
+	reminders := s.reminders[t.url]
+	parent := s.suppressed[t.url]
+	escalated := s.config.reminders.escalated(reminders)
+	ack, acked := s.acked[t.url]
+	now := time.Now()
+	policy := s.config.policy(t.escalationPolicy)
+	level := s.escalations[t.url].level
+	onCall := s.onCallLocked(policy, level, now)
+	var flapRate float64
+	var flapEnded bool
+	if st := s.flaps[t.url]; st != nil && (st.flapping || st.ended) {
+		flapRate, flapEnded = st.rate, st.ended
+	}
+	var ackURL string
+	if state == StateDown && !acked {
+		ackURL = s.config.acks.link(t.url, since)
+	}
+	history := make([]CheckResult, len(recent))
+	for i, res := range recent {
+		history[len(recent)-1-i] = res
+	}
+	s.mu.Unlock()
+	name := t.name
+	if name == "" {
+		name = t.url
+	}
+	return AlertEvent{
+		Name:         name,
+		URL:          t.url,
+		State:        state,
+		Reason:       reason,
+		FailureCount: failures,
+		StatusCode:   status,
+		History:      history,
+		Reminder:     reminders,
+		Escalated:    escalated,
+		Parent:       parent,
+		AckURL:       ackURL,
+		AckedBy:      ack.By,
+		AckedAt:      ack.At,
+		Level:        level,
+		OnCall:       onCall,
+		FlapRate:     flapRate,
+		FlapEnded:    flapEnded,
+		Since:        since,
+		At:           now,
+		Labels:       t.labels,
+		DashboardURL: t.dashboardURL,
+		target:       t,
+		policy:       policy,
+	}
+}
+
+func (s *Service) sendSiteDownAlert(t target, reason string) {
+	s.dispatch(s.newAlertEvent(t, StateDown, reason))
+}
+
+// sendSiteRecoveryAlert must be called before the failure state of t is
+// reset, so that the event describes the outage that just ended.
+func (s *Service) sendSiteRecoveryAlert(t target) {
+	s.mu.Lock()
+	reason := s.lastReason[t.url]
+	s.mu.Unlock()
+	s.dispatch(s.newAlertEvent(t, StateUp, reason))
+}
+
+// plainMessage renders ev as a short title and text body for push notifiers.
+func plainMessage(ev AlertEvent) (title, body string) {
+	if ev.State == StateFlapping {
+		body := flapSummary(ev)
+		if ev.Reason != "" {
+			body += fmt.Sprintf(" (last failure: %s)", ev.Reason)
+		}
+		return flapTitle(ev), body
+	}
+	if ev.State == StateDegraded {
+		return degradedTitle(ev), degradedSummary(ev)
+	}
+	if ev.State == StateUp && ev.From == StateDegraded {
+		return upTitle(ev), fmt.Sprintf("%s is responding normally again after %s degraded (%s)", ev.URL, ev.Downtime(), ev.Reason)
+	}
+	if ev.State == StateUp && ev.FlapEnded {
+		return upTitle(ev), fmt.Sprintf("%s stopped flapping and is up (%.0f%% state change)", ev.URL, ev.FlapRate)
+	}
+	if ev.State == StateUp {
+		body := fmt.Sprintf("%s is back online after %s (outage reason: %s)", ev.URL, ev.Downtime(), outageReason(ev))
+		if ev.AckedBy != "" {
+			body += ", " + ackNote(ev)
+		}
+		return fmt.Sprintf("✅ UP: %s", ev.Name), body
+	}
+	title = fmt.Sprintf("🚨 DOWN: %s", ev.Name)
+	body = fmt.Sprintf("%s %s (%d failed checks since %s)", ev.URL, ev.Reason, ev.FailureCount, ev.Since.Format(time.RFC1123))
+	if ev.Reminder > 0 {
+		title = fmt.Sprintf("🔁 STILL DOWN: %s", ev.Name)
+		body = fmt.Sprintf("%s is still down after %s: %s (%d failed checks since %s)", ev.URL, ev.Downtime(), ev.Reason, ev.FailureCount, ev.Since.Format(time.RFC1123))
+	}
+	if ev.OnCall != nil {
+		body += fmt.Sprintf("\nOn call (level %d): %s", ev.Level, ev.OnCall.Name)
+	}
+	return title, body
+}
+
+// outageReason returns the reason of the outage a recovery ends, noting
+// when the target was only unreachable due to its parent.
+func outageReason(ev AlertEvent) string {
+	if ev.Parent != "" {
+		return fmt.Sprintf("unreachable due to parent %s, %s", ev.Parent, ev.Reason)
+	}
+	return ev.Reason
+}
+
+// ackNote describes the acknowledgement of the outage ev belongs to.
+func ackNote(ev AlertEvent) string {
+	return fmt.Sprintf("acknowledged by %s at %s", ev.AckedBy, ev.AckedAt.UTC().Format(time.RFC1123))
+}
+
+// downTitle is the headline of DOWN events in chat notifiers.
+func downTitle(ev AlertEvent) string {
+	if ev.Reminder > 0 {
+		return fmt.Sprintf("🔁 STILL DOWN: %s (for %s)", ev.Name, ev.Downtime())
+	}
+	return fmt.Sprintf("🚨 DOWN: %s", ev.Name)
+}
+
+// requireURL records a problem if raw is not an absolute http(s) URL.
+func requireURL(errs *configErrors, field, raw, kind string) {
+	if raw == "" {
+		errs.add(field, "required for %s notifiers", kind)
+	} else if u, err := neturl.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs.add(field, "invalid URL")
+	}
+}
+
+// postJSON sends payload as JSON to url and treats non-2xx responses as errors.
+func postJSON(client *http.Client, url string, headers map[string]string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encoding payload: %w", err)
+	}
+	return post(client, url, "application/json", headers, body)
+}
+
+// post sends body to url and treats non-2xx responses as errors.
+func post(client *http.Client, url, contentType string, headers map[string]string, body []byte) error {
+	return send(client, http.MethodPost, url, contentType, headers, body)
+}
+
+// send is post with a configurable HTTP method.
+func send(client *http.Client, method, url, contentType string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return &httpStatusError{url: redactURL(url), status: res.StatusCode, body: strings.TrimSpace(string(msg))}
+	}
+	return nil
+}
+
+// httpStatusError is returned by post for non-2xx responses.
+type httpStatusError struct {
+	url    string
+	status int
+	body   string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("%s returned status %d: %s", e.url, e.status, e.body)
+}
+
+// retryable reports whether a delivery error is worth retrying: network
+// errors, rate limiting and server errors are, other client errors are not.
+func retryable(err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.status == http.StatusTooManyRequests || statusErr.status >= 500
+	}
+	return true
+}
+
+// redactURL strips the path and query of webhook URLs, which usually contain secrets.
+func redactURL(raw string) string {
+	u, err := neturl.Parse(raw)
+	if err != nil {
+		return "webhook"
+	}
+	return u.Scheme + "://" + u.Host
+}
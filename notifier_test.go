@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []AlertEvent
+	err    error
+}
+
+func (r *recordingNotifier) Notify(ev AlertEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+	return r.err
+}
+
+func (r *recordingNotifier) received() []AlertEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]AlertEvent(nil), r.events...)
+}
+
+func TestNotifierFilterMatches(t *testing.T) {
+	ev := AlertEvent{Name: "api", State: StateDown, Labels: map[string]string{"team": "payments", "env": "prod"}}
+	cases := []struct {
+		name   string
+		filter notifierFilter
+		want   bool
+	}{
+		{"empty", notifierFilter{}, true},
+		{"state", notifierFilter{states: []AlertState{StateDown}}, true},
+		{"other state", notifierFilter{states: []AlertState{StateUp}}, false},
+		{"target", notifierFilter{targets: []string{"web", "api"}}, true},
+		{"other target", notifierFilter{targets: []string{"web"}}, false},
+		{"labels", notifierFilter{labels: map[string]string{"team": "payments"}}, true},
+		{"other labels", notifierFilter{labels: map[string]string{"team": "payments", "env": "dev"}}, false},
+	}
+	for _, c := range cases {
+		if got := c.filter.matches(ev); got != c.want {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestNotifyFansOutToMatchingNotifiers(t *testing.T) {
+	s := newTestService()
+	all := &recordingNotifier{err: errors.New("boom")}
+	downOnly := &recordingNotifier{}
+	payments := &recordingNotifier{}
+	s.notifiers = []notifierEntry{
+		{name: "all", notifier: all},
+		{name: "down", filter: notifierFilter{states: []AlertState{StateDown}}, notifier: downOnly},
+		{name: "payments", filter: notifierFilter{labels: map[string]string{"team": "payments"}}, notifier: payments},
+	}
+	s.config.alertThreshold = 2
+	tgt := target{name: "api", url: "https://api.com", labels: map[string]string{"team": "web"}}
+	failClient := &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 502, Body: http.NoBody}, nil
+	})}
+	okClient := &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	})}
+
+	s.checkSiteStatus(tgt, failClient)
+	s.checkSiteStatus(tgt, failClient)
+	s.checkSiteStatus(tgt, okClient)
+
+	events := all.received()
+	if len(events) != 2 {
+		t.Fatalf("expected DOWN and UP events despite notifier error, got %d", len(events))
+	}
+	down, up := events[0], events[1]
+	if down.State != StateDown || down.Name != "api" || down.Reason != "returned status 502" || down.FailureCount != 2 {
+		t.Errorf("unexpected DOWN event: %+v", down)
+	}
+	if down.Since.IsZero() || down.At.Before(down.Since) {
+		t.Errorf("DOWN event timestamps not set: since=%v at=%v", down.Since, down.At)
+	}
+	if up.State != StateUp || up.Reason != "returned status 502" || up.FailureCount != 2 || !up.Since.Equal(down.Since) {
+		t.Errorf("UP event should describe the outage: %+v", up)
+	}
+	if up.Labels["team"] != "web" {
+		t.Errorf("labels not passed on: %v", up.Labels)
+	}
+	if got := downOnly.received(); len(got) != 1 || got[0].State != StateDown {
+		t.Errorf("state filter not applied: %+v", got)
+	}
+	if got := payments.received(); len(got) != 0 {
+		t.Errorf("label filter not applied: %+v", got)
+	}
+	if s.failureCount[tgt.url] != 0 || !s.downSince[tgt.url].IsZero() {
+		t.Errorf("failure state not reset after recovery")
+	}
+}
+
+func TestAlertEventDowntime(t *testing.T) {
+	since := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	ev := AlertEvent{Since: since, At: since.Add(90*time.Minute + 300*time.Millisecond)}
+	if got := ev.Downtime(); got != 90*time.Minute {
+		t.Errorf("expected 1h30m0s, got %v", got)
+	}
+	if got := (AlertEvent{At: since}).Downtime(); got != 0 {
+		t.Errorf("expected 0 without start time, got %v", got)
+	}
+}
+
+func TestBuildNotifiers(t *testing.T) {
+	s := newTestService()
+	entries, err := s.buildNotifiers(appConfig{})
+	if err != nil || len(entries) != 1 || entries[0].name != "email" {
+		t.Fatalf("expected default email notifier, got %+v (%v)", entries, err)
+	}
+
+	entries, err = s.buildNotifiers(appConfig{notifiers: []notifierConfig{
+		{name: "mail", kind: "email", enabled: false},
+	}})
+	if err != nil || entries == nil || len(entries) != 0 {
+		t.Errorf("expected no active notifiers when all are disabled, got %+v (%v)", entries, err)
+	}
+}
+
+func TestLoadConfigNotifiers(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `smtp:
+  server: smtp.example.com
+  port: "587"
+  from: monitor@example.com
+  to: ops@example.com
+targets:
+  - name: api
+    url: https://api.example.com
+notifiers:
+  - name: ops-mail
+    type: email
+    filter:
+      states: [down]
+      targets: [api]
+      labels:
+        team: payments
+  - type: email
+    enabled: false
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "ALERT_THRESHOLD": "", "CHECK_INTERVAL": ""})
+	defer cleanup()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.notifiers) != 2 {
+		t.Fatalf("expected 2 notifiers, got %d", len(cfg.notifiers))
+	}
+	n := cfg.notifiers[0]
+	if n.name != "ops-mail" || n.kind != "email" || !n.enabled || len(n.filter.states) != 1 || n.filter.states[0] != StateDown ||
+		n.filter.targets[0] != "api" || n.filter.labels["team"] != "payments" {
+		t.Errorf("notifier not parsed correctly: %+v", n)
+	}
+	if cfg.notifiers[1].name != "email" || cfg.notifiers[1].enabled {
+		t.Errorf("disabled notifier not parsed correctly: %+v", cfg.notifiers[1])
+	}
+}
+
+func TestValidateNotifiers(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `targets:
+  - name: api
+    url: https://api.example.com
+notifiers:
+  - name: a
+    type: pigeon
+  - name: a
+    type: email
+    filter:
+      states: [sideways]
+      targets: [web]
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "ALERT_THRESHOLD": "", "CHECK_INTERVAL": "", "SMTP_SERVER": "", "SMTP_PORT": "", "SMTP_TO": "", "SMTP_FROM": ""})
+	defer cleanup()
+
+	_, err := loadConfig()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		":6: notifiers[0].type: unknown notifier type \"pigeon\"",
+		":7: notifiers[1].name: duplicate notifier name \"a\"",
+		":7: notifiers[1]: email notifier requires SMTP settings",
+		":10: notifiers[1].filter.states[0]: unknown state \"sideways\"",
+		":11: notifiers[1].filter.targets[0]: unknown target \"web\"",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in:\n%v", want, err)
+		}
+	}
+}
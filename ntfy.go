@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+const defaultNtfyServer = "https://ntfy.sh"
+
+var ntfyPriorities = map[string]bool{"min": true, "low": true, "default": true, "high": true, "urgent": true}
+
+// ntfyConfig holds the settings of an ntfy notifier.
+type ntfyConfig struct {
+	server       string
+	topic        string
+	token        string
+	priorityDown string
+	priorityUp   string
+}
+
+type fileNtfy struct {
+	Server       string `yaml:"server" json:"server"`
+	Topic        string `yaml:"topic" json:"topic"`
+	Token        string `yaml:"token" json:"token"`
+	PriorityDown string `yaml:"priority_down" json:"priority_down"`
+	PriorityUp   string `yaml:"priority_up" json:"priority_up"`
+}
+
+func parseNtfy(f fileNtfy) ntfyConfig {
+	cfg := ntfyConfig{
+		server:       strings.TrimSuffix(f.Server, "/"),
+		topic:        f.Topic,
+		token:        f.Token,
+		priorityDown: f.PriorityDown,
+		priorityUp:   f.PriorityUp,
+	}
+	if cfg.server == "" {
+		cfg.server = defaultNtfyServer
+	}
+	if cfg.priorityDown == "" {
+		cfg.priorityDown = "high"
+	}
+	if cfg.priorityUp == "" {
+		cfg.priorityUp = "default"
+	}
+	return cfg
+}
+
+func validateNtfy(cfg ntfyConfig, field string, errs *configErrors) {
+	requireURL(errs, field+".server", cfg.server, "ntfy")
+	if cfg.topic == "" {
+		errs.add(field+".topic", "required for ntfy notifiers")
+	}
+	if !ntfyPriorities[cfg.priorityDown] {
+		errs.add(field+".priority_down", "unknown priority %q (use min, low, default, high or urgent)", cfg.priorityDown)
+	}
+	if !ntfyPriorities[cfg.priorityUp] {
+		errs.add(field+".priority_up", "unknown priority %q (use min, low, default, high or urgent)", cfg.priorityUp)
+	}
+}
+
+// ntfyNotifier publishes messages to an ntfy topic.
+type ntfyNotifier struct {
+	cfg    ntfyConfig
+	client *http.Client
+}
+
+func newNtfyNotifier(nc notifierConfig) *ntfyNotifier {
+	return &ntfyNotifier{cfg: nc.ntfy, client: &http.Client{Timeout: nc.timeout}}
+}
+
+func (n *ntfyNotifier) Notify(ev AlertEvent) error {
+	title, body := plainMessage(ev)
+	headers := map[string]string{
+		"Title":    title,
+		"Priority": n.cfg.priorityDown,
+		"Tags":     "rotating_light",
+	}
+	switch ev.State {
+	case StateUp:
+		headers["Priority"] = n.cfg.priorityUp
+		headers["Tags"] = "white_check_mark"
+	case StateFlapping:
+		headers["Tags"] = "twisted_rightwards_arrows"
+	case StateDegraded:
+		headers["Tags"] = "turtle"
+	}
+	if ev.DashboardURL != "" {
+		headers["Click"] = ev.DashboardURL
+	}
+	if n.cfg.token != "" {
+		headers["Authorization"] = "Bearer " + n.cfg.token
+	}
+	return post(n.client, n.cfg.server+"/"+n.cfg.topic, "text/plain; charset=utf-8", headers, []byte(body))
+}
+
+// NotifyDigest publishes d as a single message with the DOWN priority if
+// it contains any DOWN event.
+func (n *ntfyNotifier) NotifyDigest(d AlertDigest) error {
+	title, body := digestMessage(d)
+	headers := map[string]string{
+		"Title":    title,
+		"Priority": n.cfg.priorityDown,
+		"Tags":     "rotating_light",
+	}
+	if d.Count(StateDown) == 0 {
+		headers["Priority"] = n.cfg.priorityUp
+		headers["Tags"] = "white_check_mark"
+	}
+	if n.cfg.token != "" {
+		headers["Authorization"] = "Bearer " + n.cfg.token
+	}
+	return post(n.client, n.cfg.server+"/"+n.cfg.topic, "text/plain; charset=utf-8", headers, []byte(body))
+}
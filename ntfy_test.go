@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNtfyNotifierPriorities(t *testing.T) {
+	server := newWebhookRecorder(t, http.StatusOK)
+	var paths []string
+	server.Config.Handler = recordPath(server.Config.Handler, &paths)
+	n := newNtfyNotifier(notifierConfig{timeout: time.Second, ntfy: parseNtfy(fileNtfy{
+		Server: server.URL + "/", Topic: "shop-alerts", Token: "tk_secret",
+	})})
+
+	tests := []struct {
+		state    AlertState
+		priority string
+		title    string
+	}{
+		{StateDown, "high", "🚨 DOWN: checkout"},
+		{StateUp, "default", "✅ UP: checkout"},
+	}
+	for _, tt := range tests {
+		if err := n.Notify(testEvent(tt.state)); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.state, err)
+		}
+		body := string(<-server.bodies)
+		h := <-server.headers
+		if h.Get("Priority") != tt.priority || h.Get("Title") != tt.title {
+			t.Errorf("%s: unexpected priority %q / title %q", tt.state, h.Get("Priority"), h.Get("Title"))
+		}
+		if h.Get("Authorization") != "Bearer tk_secret" || h.Get("Click") != "https://grafana.example.com/d/abc" {
+			t.Errorf("%s: unexpected headers %v", tt.state, h)
+		}
+		if !strings.HasPrefix(body, "https://shop.example.com") {
+			t.Errorf("%s: unexpected body %q", tt.state, body)
+		}
+	}
+	if len(paths) != 2 || paths[0] != "/shop-alerts" {
+		t.Errorf("unexpected request paths %v", paths)
+	}
+}
+
+func TestValidateNtfy(t *testing.T) {
+	errs := &configErrors{}
+	validateNtfy(parseNtfy(fileNtfy{PriorityDown: "loud"}), "notifiers[0].ntfy", errs)
+	got := errs.Error()
+	for _, want := range []string{"notifiers[0].ntfy.topic", `unknown priority "loud"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// onCallSchedule is a weekly rotation that hands off on the same weekday
+// and time every week, in the schedule's timezone.
+type onCallSchedule struct {
+	name       string
+	location   *time.Location
+	handoffDay time.Weekday
+	handoffH   int
+	handoffM   int
+	start      time.Time // Date of the handoff to the first person of the rotation
+	rotation   []string  // People in the order of their shifts
+}
+
+// OnCallPerson is a member of an on-call rotation and how to reach them.
+type OnCallPerson struct {
+	Name    string `json:"name"`
+	Email   string `json:"email,omitempty"`   // Added to the recipients of alert emails
+	Slack   string `json:"slack,omitempty"`   // Slack member ID, mentioned in Slack messages
+	Discord string `json:"discord,omitempty"` // Discord user ID, mentioned in Discord messages
+}
+
+type fileOnCall struct {
+	Schedules []fileSchedule        `yaml:"schedules" json:"schedules"`
+	People    map[string]filePerson `yaml:"people" json:"people"`
+}
+
+type fileSchedule struct {
+	Name     string   `yaml:"name" json:"name"`
+	Timezone string   `yaml:"timezone" json:"timezone"`
+	Handoff  string   `yaml:"handoff" json:"handoff"` // e.g. "monday 09:00"
+	Start    string   `yaml:"start" json:"start"`     // e.g. "2024-06-03"
+	Rotation []string `yaml:"rotation" json:"rotation"`
+}
+
+type filePerson struct {
+	Email   string `yaml:"email" json:"email"`
+	Slack   string `yaml:"slack" json:"slack"`
+	Discord string `yaml:"discord" json:"discord"`
+}
+
+// parseOnCall converts the oncall section of the config file.
+func parseOnCall(f fileOnCall, errs *configErrors) ([]onCallSchedule, map[string]OnCallPerson) {
+	var schedules []onCallSchedule
+	for i, fs := range f.Schedules {
+		field := fmt.Sprintf("oncall.schedules[%d]", i)
+		sched := onCallSchedule{name: fs.Name, location: time.UTC, rotation: fs.Rotation}
+		if fs.Timezone != "" {
+			loc, err := time.LoadLocation(fs.Timezone)
+			if err != nil {
+				errs.add(field+".timezone", "unknown timezone %q", fs.Timezone)
+			} else {
+				sched.location = loc
+			}
+		}
+		day, h, m, handoffErr := parseHandoff(fs.Handoff)
+		if fs.Handoff == "" {
+			errs.add(field+".handoff", "required")
+		} else if handoffErr != nil {
+			errs.add(field+".handoff", "%v", handoffErr)
+		}
+		sched.handoffDay, sched.handoffH, sched.handoffM = day, h, m
+		if fs.Start == "" {
+			errs.add(field+".start", "required")
+		} else if start, err := time.ParseInLocation(time.DateOnly, fs.Start, sched.location); err != nil {
+			errs.add(field+".start", "invalid date %q, expected YYYY-MM-DD", fs.Start)
+		} else if handoffErr == nil && start.Weekday() != day {
+			errs.add(field+".start", "%s is a %s, not the handoff day %s", fs.Start, start.Weekday(), day)
+		} else {
+			sched.start = start
+		}
+		schedules = append(schedules, sched)
+	}
+
+	people := make(map[string]OnCallPerson, len(f.People))
+	for name, fp := range f.People {
+		people[name] = OnCallPerson{Name: name, Email: fp.Email, Slack: fp.Slack, Discord: fp.Discord}
+	}
+	return schedules, people
+}
+
+// parseHandoff parses a weekday and time of day like "monday 09:00".
+func parseHandoff(s string) (day time.Weekday, hour, minute int, err error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return 0, 0, 0, fmt.Errorf("expected a weekday and time like \"monday 09:00\", got %q", s)
+	}
+	found := false
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if f := strings.ToLower(fields[0]); f == name || f == name[:3] {
+			day, found = d, true
+		}
+	}
+	if !found {
+		return 0, 0, 0, fmt.Errorf("unknown weekday %q", fields[0])
+	}
+	at, err := time.Parse("15:04", fields[1])
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid time %q, expected HH:MM", fields[1])
+	}
+	return day, at.Hour(), at.Minute(), nil
+}
+
+func validateOnCall(cfg appConfig, errs *configErrors) {
+	seen := make(map[string]bool)
+	for i, sched := range cfg.schedules {
+		field := fmt.Sprintf("oncall.schedules[%d]", i)
+		if sched.name == "" {
+			errs.add(field+".name", "required")
+		} else if seen[sched.name] {
+			errs.add(field+".name", "duplicate schedule name %q", sched.name)
+		}
+		seen[sched.name] = true
+		if len(sched.rotation) == 0 {
+			errs.add(field+".rotation", "at least one person required")
+		}
+		for j, name := range sched.rotation {
+			if _, ok := cfg.people[name]; !ok {
+				errs.add(fmt.Sprintf("%s.rotation[%d]", field, j), "unknown person %q", name)
+			}
+		}
+	}
+	for name, p := range cfg.people {
+		if p.Email == "" {
+			continue
+		}
+		if _, err := mail.ParseAddress(p.Email); err != nil {
+			errs.add(fmt.Sprintf("oncall.people.%s.email", name), "invalid email address %q", p.Email)
+		}
+	}
+}
+
+// onCallAt returns the name of the person on call at now.
+func (sched onCallSchedule) onCallAt(now time.Time) string {
+	if len(sched.rotation) == 0 {
+		return ""
+	}
+	t := now.In(sched.location)
+	back := (int(t.Weekday()) - int(sched.handoffDay) + 7) % 7
+	handoff := time.Date(t.Year(), t.Month(), t.Day()-back, sched.handoffH, sched.handoffM, 0, 0, sched.location)
+	if handoff.After(t) {
+		handoff = handoff.AddDate(0, 0, -7)
+	}
+	// Count whole weeks on the calendar, so DST changes don't shift shifts.
+	days := civilDays(handoff) - civilDays(sched.start)
+	n := len(sched.rotation)
+	return sched.rotation[((days/7)%n+n)%n]
+}
+
+// civilDays returns the number of days since the Unix epoch of t's date.
+func civilDays(t time.Time) int {
+	return int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// schedule returns the on-call schedule with the given name, or nil.
+func (c appConfig) schedule(name string) *onCallSchedule {
+	for i := range c.schedules {
+		if c.schedules[i].name == name {
+			return &c.schedules[i]
+		}
+	}
+	return nil
+}
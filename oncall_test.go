@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOnCallRotation(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("timezone data not available: %v", err)
+	}
+	sched := onCallSchedule{
+		name: "platform", location: berlin, handoffDay: time.Monday, handoffH: 9,
+		start: time.Date(2024, 6, 3, 0, 0, 0, 0, berlin), rotation: []string{"alice", "bob", "carol"},
+	}
+	cases := []struct {
+		at   time.Time
+		want string
+	}{
+		{time.Date(2024, 6, 3, 9, 0, 0, 0, berlin), "alice"},
+		{time.Date(2024, 6, 10, 8, 59, 0, 0, berlin), "alice"}, // Before the handoff
+		{time.Date(2024, 6, 10, 7, 0, 0, 0, time.UTC), "bob"},  // 09:00 in Berlin
+		{time.Date(2024, 6, 16, 23, 0, 0, 0, berlin), "bob"},
+		{time.Date(2024, 6, 17, 9, 0, 0, 0, berlin), "carol"},
+		{time.Date(2024, 6, 24, 9, 0, 0, 0, berlin), "alice"},
+		{time.Date(2024, 10, 28, 9, 30, 0, 0, berlin), "alice"}, // After the switch to winter time
+		{time.Date(2024, 5, 31, 12, 0, 0, 0, berlin), "carol"},  // Before the start the rotation runs backwards
+	}
+	for _, c := range cases {
+		if got := sched.onCallAt(c.at); got != c.want {
+			t.Errorf("at %v: got %s, want %s", c.at, got, c.want)
+		}
+	}
+}
+
+func TestParseOnCallErrors(t *testing.T) {
+	errs := &configErrors{}
+	schedules, people := parseOnCall(fileOnCall{
+		Schedules: []fileSchedule{
+			{Name: "a", Timezone: "Mars/Olympus", Handoff: "someday 9am", Start: "June 3rd", Rotation: []string{"alice"}},
+			{Name: "b", Handoff: "monday 09:00", Start: "2024-06-04", Rotation: []string{"dave"}},
+			{Name: "b"},
+		},
+		People: map[string]filePerson{"alice": {Email: "not an address"}},
+	}, errs)
+	validateOnCall(appConfig{schedules: schedules, people: people}, errs)
+	msg := errs.Error()
+	for _, want := range []string{
+		`oncall.schedules[0].timezone: unknown timezone "Mars/Olympus"`,
+		`oncall.schedules[0].handoff: unknown weekday "someday"`,
+		`oncall.schedules[0].start: invalid date "June 3rd"`,
+		`oncall.schedules[1].start: 2024-06-04 is a Tuesday, not the handoff day Monday`,
+		`oncall.schedules[1].rotation[0]: unknown person "dave"`,
+		`oncall.schedules[2].name: duplicate schedule name "b"`,
+		`oncall.schedules[2].handoff: required`,
+		`oncall.schedules[2].start: required`,
+		`oncall.schedules[2].rotation: at least one person required`,
+		`oncall.people.alice.email: invalid email address`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("missing %q in %s", want, msg)
+		}
+	}
+}
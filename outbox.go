@@ -0,0 +1,503 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultOutboxPath        = "data/outbox.json"
+	defaultOutboxMaxAttempts = 10
+	defaultOutboxBackoff     = 30 * time.Second
+	defaultOutboxMaxBackoff  = 30 * time.Minute
+	outboxPollInterval       = time.Second
+)
+
+// outboxConfig controls how failed deliveries are retried.
+type outboxConfig struct {
+	path        string // JSON file the outbox is persisted to
+	maxAttempts int    // Attempts before a delivery is dead-lettered
+	backoff     time.Duration
+	maxBackoff  time.Duration
+}
+
+type fileOutbox struct {
+	Path        string `yaml:"path" json:"path"`
+	MaxAttempts int    `yaml:"max_attempts" json:"max_attempts"`
+	Backoff     string `yaml:"backoff" json:"backoff"`
+	MaxBackoff  string `yaml:"max_backoff" json:"max_backoff"`
+}
+
+func defaultOutboxConfig() outboxConfig {
+	return outboxConfig{
+		path:        defaultOutboxPath,
+		maxAttempts: defaultOutboxMaxAttempts,
+		backoff:     defaultOutboxBackoff,
+		maxBackoff:  defaultOutboxMaxBackoff,
+	}
+}
+
+func (c *outboxConfig) applyFile(f fileOutbox, errs *configErrors) {
+	overlay(&c.path, f.Path)
+	if f.MaxAttempts != 0 {
+		c.maxAttempts = f.MaxAttempts
+	}
+	if f.Backoff != "" {
+		errs.duration("outbox.backoff", f.Backoff, &c.backoff)
+	}
+	if f.MaxBackoff != "" {
+		errs.duration("outbox.max_backoff", f.MaxBackoff, &c.maxBackoff)
+	}
+}
+
+func validateOutbox(c outboxConfig, errs *configErrors) {
+	if c.maxAttempts < 1 {
+		errs.add("outbox.max_attempts", "must be at least 1, got %d", c.maxAttempts)
+	}
+	if c.backoff <= 0 {
+		errs.add("outbox.backoff", "must be a positive duration")
+	}
+	if c.maxBackoff < c.backoff {
+		errs.add("outbox.max_backoff", "must not be shorter than outbox.backoff")
+	}
+}
+
+// outboxItem is a delivery of an event to one notifier that failed and is
+// waiting to be retried, or was given up on.
+type outboxItem struct {
+	ID          string       `json:"id"`
+	Notifier    string       `json:"notifier"`
+	Event       AlertEvent   `json:"event,omitzero"`
+	Digest      *AlertDigest `json:"digest,omitempty"` // Set instead of Event for digests
+	Attempts    int          `json:"attempts"`
+	CreatedAt   time.Time    `json:"created_at"`
+	NextAttempt time.Time    `json:"next_attempt,omitzero"`
+	LastError   string       `json:"last_error"`
+}
+
+// String describes the delivery for log messages.
+func (item *outboxItem) String() string {
+	if item.Digest != nil {
+		return fmt.Sprintf("digest of %d alerts", len(item.Digest.Events()))
+	}
+	return fmt.Sprintf("%s alert for %s", item.Event.State, item.Event.URL)
+}
+
+// eventAt returns the time of the event, or of the digest.
+func (item *outboxItem) eventAt() time.Time {
+	if item.Digest != nil {
+		return item.Digest.At
+	}
+	return item.Event.At
+}
+
+// lane returns the key of the items that have to be retried in order: those
+// of the same notifier and target. Digests get a lane per notifier.
+func (item *outboxItem) lane() string {
+	if item.Digest != nil {
+		return item.Notifier
+	}
+	return outboxKey(item.Notifier, item.Event.URL)
+}
+
+func outboxKey(notifier, url string) string {
+	return notifier + " " + url
+}
+
+// alertOutbox keeps failed deliveries on disk and retries them with
+// exponential backoff until they succeed or run out of attempts.
+type alertOutbox struct {
+	mu      sync.Mutex
+	cfg     outboxConfig
+	pending []*outboxItem
+	dead    []*outboxItem
+	newest  map[string]time.Time // Newest event delivered or queued per notifier and target
+
+	depth    prometheus.Gauge
+	deadSize prometheus.Gauge
+	failures *prometheus.CounterVec
+}
+
+type outboxState struct {
+	Pending     []*outboxItem `json:"pending"`
+	DeadLetters []*outboxItem `json:"dead_letters"`
+}
+
+// newAlertOutbox creates an outbox and loads the items persisted at cfg.path.
+func newAlertOutbox(cfg outboxConfig, m appMetrics) (*alertOutbox, error) {
+	o := &alertOutbox{cfg: cfg, depth: m.outboxDepth, deadSize: m.deadLetters, failures: m.deliveryFailures}
+	data, err := os.ReadFile(cfg.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		o.updateMetricsLocked()
+		return o, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading outbox: %w", err)
+	}
+	var state outboxState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("reading outbox %s: %w", cfg.path, err)
+	}
+	o.pending, o.dead = state.Pending, state.DeadLetters
+	o.updateMetricsLocked()
+	if len(o.pending) > 0 {
+		log.Printf("Loaded %d pending alert deliveries from %s", len(o.pending), cfg.path)
+	}
+	return o, nil
+}
+
+// setConfig applies new retry settings. The outbox file stays the same
+// until restart.
+func (o *alertOutbox) setConfig(cfg outboxConfig) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	cfg.path = o.cfg.path
+	o.cfg = cfg
+}
+
+// enqueue stores a failed first delivery of ev to the named notifier.
+func (o *alertOutbox) enqueue(notifier string, ev AlertEvent, deliveryErr error) {
+	o.add(&outboxItem{Notifier: notifier, Event: ev}, deliveryErr)
+}
+
+// enqueueDigest stores a failed first delivery of d to the named notifier.
+func (o *alertOutbox) enqueueDigest(notifier string, d AlertDigest, deliveryErr error) {
+	o.add(&outboxItem{Notifier: notifier, Digest: &d}, deliveryErr)
+}
+
+func (o *alertOutbox) add(item *outboxItem, deliveryErr error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	item.ID = newOutboxID()
+	item.CreatedAt = now
+	o.supersedeLocked(item)
+	o.failedLocked(item, deliveryErr, now)
+	if item.NextAttempt.IsZero() {
+		o.dead = append(o.dead, item)
+	} else {
+		o.pending = append(o.pending, item)
+	}
+	o.saveLocked()
+}
+
+// supersede drops the pending items of the notifier that are older than
+// events, which were delivered to it.
+func (o *alertOutbox) supersede(notifier string, events ...AlertEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := len(o.pending)
+	for _, ev := range events {
+		o.supersedeLocked(&outboxItem{Notifier: notifier, Event: ev})
+	}
+	if len(o.pending) != n {
+		o.saveLocked()
+	}
+}
+
+// supersedeLocked records the events of item as the newest for their
+// targets and drops older pending events of the same notifier and targets,
+// so that e.g. a failed DOWN is not retried after the UP went out. Callers
+// must hold o.mu.
+func (o *alertOutbox) supersedeLocked(item *outboxItem) {
+	if o.newest == nil {
+		o.newest = make(map[string]time.Time)
+	}
+	events := []AlertEvent{item.Event}
+	if item.Digest != nil {
+		events = item.Digest.Events()
+	}
+	for _, ev := range events {
+		key := outboxKey(item.Notifier, ev.URL)
+		if ev.At.After(o.newest[key]) {
+			o.newest[key] = ev.At
+		}
+	}
+	o.pending = slices.DeleteFunc(o.pending, func(p *outboxItem) bool {
+		if p.Notifier != item.Notifier {
+			return false
+		}
+		return o.dropStaleLocked(p)
+	})
+}
+
+// dropStaleLocked removes the events of item that are older than the
+// newest event of their target and reports whether nothing is left.
+// Callers must hold o.mu.
+func (o *alertOutbox) dropStaleLocked(item *outboxItem) bool {
+	stale := func(ev AlertEvent) bool {
+		return ev.At.Before(o.newest[outboxKey(item.Notifier, ev.URL)])
+	}
+	if item.Digest == nil {
+		if stale(item.Event) {
+			log.Printf("Dropping %s for %s, superseded by a newer alert", item, item.Notifier)
+			return true
+		}
+		return false
+	}
+	d := item.Digest.filter(func(ev AlertEvent) bool { return !stale(ev) })
+	if len(d.Groups) == 0 {
+		log.Printf("Dropping %s for %s, superseded by newer alerts", item, item.Notifier)
+		return true
+	}
+	item.Digest = &d
+	return false
+}
+
+// failedLocked records a failed attempt and schedules the next one, or
+// clears NextAttempt if the item is out of attempts or the error is not
+// worth retrying. Callers must hold o.mu.
+func (o *alertOutbox) failedLocked(item *outboxItem, err error, now time.Time) {
+	item.Attempts++
+	item.LastError = err.Error()
+	if o.failures != nil {
+		o.failures.With(prometheus.Labels{"notifier": item.Notifier}).Inc()
+	}
+	if item.Attempts >= o.cfg.maxAttempts || !retryable(err) {
+		item.NextAttempt = time.Time{}
+		log.Printf("Giving up delivering %s to %s after %d attempts: %v", item, item.Notifier, item.Attempts, err)
+		return
+	}
+	backoff := o.cfg.backoff
+	for i := 1; i < item.Attempts && backoff < o.cfg.maxBackoff; i++ {
+		backoff *= 2
+	}
+	item.NextAttempt = now.Add(min(backoff, o.cfg.maxBackoff))
+}
+
+// due removes and returns the items whose next attempt has come, oldest
+// event first.
+func (o *alertOutbox) due(now time.Time) []*outboxItem {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var due []*outboxItem
+	o.pending = slices.DeleteFunc(o.pending, func(item *outboxItem) bool {
+		if item.NextAttempt.After(now) {
+			return false
+		}
+		due = append(due, item)
+		return true
+	})
+	slices.SortStableFunc(due, func(a, b *outboxItem) int { return a.eventAt().Compare(b.eventAt()) })
+	return due
+}
+
+// finish puts retried items back: failed ones are rescheduled or
+// dead-lettered, delivered ones are dropped. errs is indexed like items.
+func (o *alertOutbox) finish(items []*outboxItem, errs []error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	for i, item := range items {
+		if errs[i] == nil {
+			log.Printf("Delivered %s to %s after %d failed attempts", item, item.Notifier, item.Attempts)
+			o.supersedeLocked(item)
+			continue
+		}
+		if o.dropStaleLocked(item) {
+			continue
+		}
+		o.failedLocked(item, errs[i], now)
+		if item.NextAttempt.IsZero() {
+			o.dead = append(o.dead, item)
+		} else {
+			o.pending = append(o.pending, item)
+		}
+	}
+	o.saveLocked()
+}
+
+// deadLetters returns a copy of the dead-letter list.
+func (o *alertOutbox) deadLetters() []outboxItem {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	out := make([]outboxItem, len(o.dead))
+	for i, item := range o.dead {
+		out[i] = *item
+	}
+	return out
+}
+
+// clearDeadLetters empties the dead-letter list and returns how many items it held.
+func (o *alertOutbox) clearDeadLetters() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := len(o.dead)
+	o.dead = nil
+	o.saveLocked()
+	return n
+}
+
+// saveLocked persists the outbox and updates its metrics. Callers must hold o.mu.
+func (o *alertOutbox) saveLocked() {
+	o.updateMetricsLocked()
+	if o.cfg.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(outboxState{Pending: o.pending, DeadLetters: o.dead}, "", "  ")
+	if err != nil {
+		log.Printf("Encoding outbox: %v", err)
+		return
+	}
+	if err := writeFileAtomic(o.cfg.path, data); err != nil {
+		log.Printf("Saving outbox: %v", err)
+	}
+}
+
+func (o *alertOutbox) updateMetricsLocked() {
+	if o.depth != nil {
+		o.depth.Set(float64(len(o.pending)))
+	}
+	if o.deadSize != nil {
+		o.deadSize.Set(float64(len(o.dead)))
+	}
+}
+
+// writeFileAtomic replaces path with data via a temporary file, creating
+// the parent directory if needed.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func newOutboxID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// processOutbox retries due deliveries until ctx is done.
+func (s *Service) processOutbox(ctx context.Context) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.retryOutbox(time.Now())
+		}
+	}
+}
+
+// retryOutbox redelivers the outbox items due at now.
+func (s *Service) retryOutbox(now time.Time) {
+	items := s.outbox.due(now)
+	if len(items) == 0 {
+		return
+	}
+	notifiers := make(map[string]Notifier)
+	for _, entry := range s.activeNotifiers() {
+		notifiers[entry.name] = entry.notifier
+	}
+
+	// Items of the same notifier and target are retried one at a time, in
+	// order, so that e.g. a DOWN can't overtake the UP after it.
+	lanes := make(map[string][]int)
+	var order []string
+	for i, item := range items {
+		key := item.lane()
+		if _, ok := lanes[key]; !ok {
+			order = append(order, key)
+		}
+		lanes[key] = append(lanes[key], i)
+	}
+	errs := make([]error, len(items))
+	var wg sync.WaitGroup
+	for _, key := range order {
+		wg.Add(1)
+		go func(indexes []int) {
+			defer wg.Done()
+			for _, i := range indexes {
+				errs[i] = s.redeliver(items[i], notifiers[items[i].Notifier])
+			}
+		}(lanes[key])
+	}
+	wg.Wait()
+	s.outbox.finish(items, errs)
+}
+
+// redeliver retries item with n, which is nil if the notifier is no longer
+// configured.
+func (s *Service) redeliver(item *outboxItem, n Notifier) error {
+	if n == nil {
+		return fmt.Errorf("notifier %s is no longer configured", item.Notifier)
+	}
+	if item.Digest != nil {
+		dn, ok := digestNotifier(n)
+		if !ok {
+			return fmt.Errorf("notifier %s no longer sends digests", item.Notifier)
+		}
+		d := *item.Digest
+		for g := range d.Groups {
+			for j, ev := range d.Groups[g].Events {
+				d.Groups[g].Events[j].target = s.targetFor(ev)
+			}
+		}
+		return dn.NotifyDigest(d)
+	}
+	ev := item.Event
+	ev.target = s.targetFor(ev)
+	return n.Notify(ev)
+}
+
+// targetFor returns the configured target of ev, or a minimal target built
+// from the event if it was removed since.
+func (s *Service) targetFor(ev AlertEvent) target {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.config.targets {
+		if t.url == ev.URL {
+			return t
+		}
+	}
+	return target{name: ev.Name, url: ev.URL, labels: ev.Labels, dashboardURL: ev.DashboardURL}
+}
+
+// handleDeadLetters serves the dead-letter list as JSON on GET and clears
+// it on DELETE, which requires the API token.
+func (s *Service) handleDeadLetters(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.outbox.deadLetters())
+	case http.MethodDelete:
+		if !s.requireAPIToken(w, r) {
+			return
+		}
+		n := s.outbox.clearDeadLetters()
+		log.Printf("Cleared %d dead-lettered alert deliveries", n)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestOutbox(t *testing.T, cfg outboxConfig) (*alertOutbox, appMetrics) {
+	t.Helper()
+	m := appMetrics{
+		outboxDepth:      prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_outbox_depth"}),
+		deadLetters:      prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_dead_letters"}),
+		deliveryFailures: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_delivery_failures"}, []string{"notifier"}),
+	}
+	o, err := newAlertOutbox(cfg, m)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return o, m
+}
+
+// flakyNotifier fails until it has been called failures times.
+type flakyNotifier struct {
+	recordingNotifier
+	failures int
+	calls    int
+}
+
+func (f *flakyNotifier) Notify(ev AlertEvent) error {
+	f.calls++
+	if f.calls <= f.failures {
+		return errors.New("smtp: connection refused")
+	}
+	return f.recordingNotifier.Notify(ev)
+}
+
+func TestOutboxRetriesWithBackoff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	cfg := outboxConfig{path: path, maxAttempts: 5, backoff: time.Minute, maxBackoff: 3 * time.Minute}
+	s := newTestService()
+	flaky := &flakyNotifier{failures: 3}
+	s.notifiers = []notifierEntry{{name: "mail", notifier: flaky}}
+	var m appMetrics
+	s.outbox, m = newTestOutbox(t, cfg)
+	s.config.targets = []target{{name: "api", url: "https://api.example.com", recipients: []string{"api@example.com"}}}
+
+	s.sendSiteDownAlert(s.config.targets[0], "returned status 500")
+	if got := testutil.ToFloat64(m.outboxDepth); got != 1 {
+		t.Fatalf("expected 1 queued delivery, got %v", got)
+	}
+
+	now := time.Now()
+	s.retryOutbox(now) // Not due yet
+	if flaky.calls != 1 {
+		t.Fatalf("expected no retry before the backoff, got %d calls", flaky.calls)
+	}
+
+	// Attempts 2 and 3 fail, with the backoff doubling up to max_backoff.
+	s.retryOutbox(now.Add(time.Minute + time.Second))
+	item := s.outbox.pending[0]
+	if wait := time.Until(item.NextAttempt); wait < time.Minute+50*time.Second || wait > 2*time.Minute {
+		t.Errorf("expected ~2m backoff after 2 attempts, got %s", wait)
+	}
+	s.retryOutbox(now.Add(4 * time.Minute))
+	if wait := time.Until(s.outbox.pending[0].NextAttempt); wait > 3*time.Minute {
+		t.Errorf("expected backoff capped at 3m, got %s", wait)
+	}
+
+	// Restart: the pending delivery is loaded from disk and delivered.
+	reloaded, m2 := newTestOutbox(t, cfg)
+	if len(reloaded.pending) != 1 || reloaded.pending[0].Attempts != 3 || reloaded.pending[0].Event.Name != "api" {
+		t.Fatalf("outbox not persisted: %+v", reloaded.pending)
+	}
+	s.outbox = reloaded
+	s.retryOutbox(now.Add(time.Hour))
+	events := flaky.received()
+	if len(events) != 1 || events[0].State != StateDown || events[0].Reason != "returned status 500" {
+		t.Fatalf("expected the DOWN alert to be delivered, got %+v", events)
+	}
+	if len(events[0].target.recipients) != 1 {
+		t.Errorf("expected the target to be restored from the config, got %+v", events[0].target)
+	}
+	if testutil.ToFloat64(m2.outboxDepth) != 0 || testutil.ToFloat64(m.deliveryFailures.WithLabelValues("mail")) != 3 {
+		t.Errorf("unexpected metrics: depth %v, failures %v", testutil.ToFloat64(m2.outboxDepth), testutil.ToFloat64(m.deliveryFailures.WithLabelValues("mail")))
+	}
+}
+
+func TestOutboxDeadLetters(t *testing.T) {
+	cfg := outboxConfig{path: filepath.Join(t.TempDir(), "outbox.json"), maxAttempts: 2, backoff: time.Second, maxBackoff: time.Second}
+	s := newTestService()
+	s.notifiers = []notifierEntry{{name: "slack", notifier: &recordingNotifier{err: errors.New("status 500")}}}
+	var m appMetrics
+	s.outbox, m = newTestOutbox(t, cfg)
+
+	s.sendSiteDownAlert(target{name: "api", url: "https://api.example.com"}, "returned status 502")
+	s.retryOutbox(time.Now().Add(time.Minute))
+	if testutil.ToFloat64(m.outboxDepth) != 0 || testutil.ToFloat64(m.deadLetters) != 1 {
+		t.Fatalf("expected the delivery to be dead-lettered, depth %v dead %v", testutil.ToFloat64(m.outboxDepth), testutil.ToFloat64(m.deadLetters))
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleDeadLetters(rec, httptest.NewRequest(http.MethodGet, "/outbox/dead-letters", nil))
+	var dead []outboxItem
+	if err := json.Unmarshal(rec.Body.Bytes(), &dead); err != nil {
+		t.Fatalf("invalid JSON %s: %v", rec.Body, err)
+	}
+	if len(dead) != 1 || dead[0].Notifier != "slack" || dead[0].Attempts != 2 || dead[0].LastError != "status 500" || dead[0].Event.URL != "https://api.example.com" {
+		t.Errorf("unexpected dead letters: %+v", dead)
+	}
+
+	del := httptest.NewRequest(http.MethodDelete, "/outbox/dead-letters", nil)
+	rec = httptest.NewRecorder()
+	s.handleDeadLetters(rec, del)
+	if rec.Code != http.StatusUnauthorized || len(s.outbox.deadLetters()) != 1 {
+		t.Fatalf("clearing without a configured token should be rejected, got %d", rec.Code)
+	}
+	s.config.acks.apiToken = "t0ken"
+	del.Header.Set("Authorization", "Bearer t0ken")
+	rec = httptest.NewRecorder()
+	s.handleDeadLetters(rec, del)
+	if rec.Code != http.StatusNoContent || len(s.outbox.deadLetters()) != 0 {
+		t.Errorf("expected dead letters to be cleared, got %d %+v", rec.Code, s.outbox.deadLetters())
+	}
+	if reloaded, _ := newTestOutbox(t, cfg); len(reloaded.dead) != 0 {
+		t.Errorf("cleared dead letters should be persisted, got %+v", reloaded.dead)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleDeadLetters(rec, httptest.NewRequest(http.MethodPost, "/outbox/dead-letters", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rec.Code)
+	}
+}
+
+func TestOutboxRemovedNotifier(t *testing.T) {
+	s := newTestService()
+	s.notifiers = []notifierEntry{{name: "old", notifier: &recordingNotifier{err: errors.New("boom")}}}
+	s.outbox, _ = newTestOutbox(t, outboxConfig{maxAttempts: 2, backoff: time.Second, maxBackoff: time.Second})
+
+	s.sendSiteDownAlert(target{url: "https://api.example.com"}, "returned status 500")
+	s.notifiers = []notifierEntry{}
+	s.retryOutbox(time.Now().Add(time.Minute))
+	dead := s.outbox.deadLetters()
+	if len(dead) != 1 || !strings.Contains(dead[0].LastError, "no longer configured") {
+		t.Errorf("expected dead letter for removed notifier, got %+v", dead)
+	}
+}
+
+func TestOutboxSupersededEvents(t *testing.T) {
+	s := newTestService()
+	rec := &recordingNotifier{err: errors.New("status 503")}
+	entry := notifierEntry{name: "slack", notifier: rec}
+	s.notifiers = []notifierEntry{entry}
+	var m appMetrics
+	s.outbox, m = newTestOutbox(t, outboxConfig{maxAttempts: 5, backoff: time.Second, maxBackoff: time.Second})
+	at := time.Now()
+	down := AlertEvent{URL: "https://api.example.com", State: StateDown, At: at}
+	up := AlertEvent{URL: "https://api.example.com", State: StateUp, At: at.Add(time.Minute)}
+	other := AlertEvent{URL: "https://web.example.com", State: StateDown, At: at}
+
+	// A newer event that is delivered drops the queued older one.
+	s.deliver(entry, down)
+	s.deliver(entry, other)
+	rec.err = nil
+	s.deliver(entry, up)
+	if len(s.outbox.pending) != 1 || s.outbox.pending[0].Event.URL != other.URL {
+		t.Fatalf("expected only the alert of the other target to stay queued, got %+v", s.outbox.pending)
+	}
+	if testutil.ToFloat64(m.outboxDepth) != 1 {
+		t.Errorf("expected depth 1, got %v", testutil.ToFloat64(m.outboxDepth))
+	}
+
+	// A newer event that is queued drops the older one too, so a DOWN is
+	// never retried after the UP.
+	s.outbox.pending = nil
+	rec.err = errors.New("status 503")
+	s.deliver(entry, down)
+	s.deliver(entry, up)
+	if len(s.outbox.pending) != 1 || s.outbox.pending[0].Event.State != StateUp {
+		t.Fatalf("expected only the UP to stay queued, got %+v", s.outbox.pending)
+	}
+
+	// A retry that fails after a newer event went out is dropped.
+	items := s.outbox.due(at.Add(time.Hour))
+	s.outbox.supersede("slack", AlertEvent{URL: up.URL, State: StateDown, At: at.Add(2 * time.Minute)})
+	s.outbox.finish(items, []error{errors.New("status 503")})
+	if len(s.outbox.pending) != 0 || len(s.outbox.dead) != 0 {
+		t.Errorf("expected the superseded retry to be dropped, got %+v %+v", s.outbox.pending, s.outbox.dead)
+	}
+}
+
+func TestOutboxDueInOrder(t *testing.T) {
+	o, _ := newTestOutbox(t, outboxConfig{maxAttempts: 5, backoff: time.Second, maxBackoff: time.Second})
+	at := time.Now()
+	o.enqueue("slack", AlertEvent{URL: "https://b.example.com", At: at.Add(time.Minute)}, errors.New("boom"))
+	o.enqueue("slack", AlertEvent{URL: "https://a.example.com", At: at}, errors.New("boom"))
+	o.enqueueDigest("slack", AlertDigest{At: at.Add(30 * time.Second), Groups: []AlertGroup{{Events: []AlertEvent{{URL: "https://c.example.com", At: at}}}}}, errors.New("boom"))
+
+	var got []string
+	for _, item := range o.due(at.Add(time.Hour)) {
+		got = append(got, item.String())
+	}
+	want := []string{" alert for https://a.example.com", "digest of 1 alerts", " alert for https://b.example.com"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("expected the oldest events first, got %q", got)
+	}
+}
+
+func TestLoadConfigOutbox(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+targets:
+  - url: https://a.com
+outbox:
+  path: /var/lib/go-grafana/outbox.json
+  max_attempts: 0
+  backoff: 1m
+  max_backoff: 10s
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "CHECK_INTERVAL": "", "ALERT_THRESHOLD": ""})
+	defer cleanup()
+
+	_, err := loadConfig()
+	if err == nil || !strings.Contains(err.Error(), "outbox.max_backoff: must not be shorter") {
+		t.Errorf("expected outbox validation error, got %v", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "max_attempts") {
+		t.Errorf("max_attempts 0 should keep the default, got %v", err)
+	}
+}
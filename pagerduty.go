@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	defaultPagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+	defaultPagerDutySeverity  = "error"
+)
+
+var pagerDutySeverities = map[string]bool{"critical": true, "error": true, "warning": true, "info": true}
+
+// pagerDutyConfig holds the settings of a PagerDuty Events API v2 notifier.
+type pagerDutyConfig struct {
+	routingKey string
+	eventsURL  string
+	severity   string
+	severities map[string]string // Severity overrides by target name
+}
+
+type filePagerDuty struct {
+	RoutingKey string            `yaml:"routing_key" json:"routing_key"`
+	EventsURL  string            `yaml:"events_url" json:"events_url"`
+	Severity   string            `yaml:"severity" json:"severity"`
+	Severities map[string]string `yaml:"severities" json:"severities"`
+}
+
+func parsePagerDuty(f filePagerDuty) pagerDutyConfig {
+	cfg := pagerDutyConfig{
+		routingKey: f.RoutingKey,
+		eventsURL:  f.EventsURL,
+		severity:   f.Severity,
+		severities: f.Severities,
+	}
+	if cfg.eventsURL == "" {
+		cfg.eventsURL = defaultPagerDutyEventsURL
+	}
+	if cfg.severity == "" {
+		cfg.severity = defaultPagerDutySeverity
+	}
+	return cfg
+}
+
+func validatePagerDuty(cfg pagerDutyConfig, field string, targets map[string]bool, errs *configErrors) {
+	if cfg.routingKey == "" {
+		errs.add(field+".routing_key", "required for pagerduty notifiers")
+	}
+	if u, err := url.Parse(cfg.eventsURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		errs.add(field+".events_url", "invalid events URL %q", cfg.eventsURL)
+	}
+	if !pagerDutySeverities[cfg.severity] {
+		errs.add(field+".severity", "unknown severity %q (use critical, error, warning or info)", cfg.severity)
+	}
+	for name, sev := range cfg.severities {
+		if !targets[name] {
+			errs.add(field+".severities."+name, "unknown target %q", name)
+		} else if !pagerDutySeverities[sev] {
+			errs.add(field+".severities."+name, "unknown severity %q (use critical, error, warning or info)", sev)
+		}
+	}
+}
+
+// pagerDutyNotifier triggers PagerDuty incidents for DOWN events and resolves
+// them on recovery, using a dedup key derived from the target URL.
+type pagerDutyNotifier struct {
+	cfg    pagerDutyConfig
+	client *http.Client
+}
+
+func newPagerDutyNotifier(nc notifierConfig) *pagerDutyNotifier {
+	return &pagerDutyNotifier{cfg: nc.pagerduty, client: &http.Client{Timeout: nc.timeout}}
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+	Client      string            `json:"client,omitempty"`
+	Links       []pagerDutyLink   `json:"links,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"`
+	Timestamp     string         `json:"timestamp"`
+	Component     string         `json:"component"`
+	CustomDetails map[string]any `json:"custom_details"`
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+func (n *pagerDutyNotifier) Notify(ev AlertEvent) error {
+	if ev.State == StateFlapping || ev.latencyOnly() {
+		// Incidents follow DOWN and UP, flapping and degradation leave them as they are.
+		return nil
+	}
+	return postJSON(n.client, n.cfg.eventsURL, nil, n.event(ev))
+}
+
+// event builds the trigger or resolve event for ev.
+func (n *pagerDutyNotifier) event(ev AlertEvent) pagerDutyEvent {
+	event := pagerDutyEvent{
+		RoutingKey: n.cfg.routingKey,
+		DedupKey:   pagerDutyDedupKey(ev.URL),
+		Client:     "go-grafana",
+	}
+	if ev.State != StateDown {
+		event.EventAction = "resolve"
+		return event
+	}
+
+	severity := n.cfg.severity
+	if sev, ok := n.cfg.severities[ev.Name]; ok {
+		severity = sev
+	}
+	event.EventAction = "trigger"
+	event.Payload = &pagerDutyPayload{
+		Summary:   fmt.Sprintf("%s is DOWN: %s", ev.Name, ev.Reason),
+		Source:    ev.URL,
+		Severity:  severity,
+		Timestamp: ev.At.UTC().Format(time.RFC3339),
+		Component: ev.Name,
+		CustomDetails: map[string]any{
+			"reason":        ev.Reason,
+			"failure_count": ev.FailureCount,
+			"failing_since": ev.Since.UTC().Format(time.RFC3339),
+			"labels":        ev.Labels,
+		},
+	}
+	if ev.DashboardURL != "" {
+		event.Links = []pagerDutyLink{{Href: ev.DashboardURL, Text: "Grafana dashboard"}}
+	}
+	return event
+}
+
+// pagerDutyDedupKey returns a stable dedup key for the target URL, so the
+// resolve event matches the trigger event across restarts.
+func pagerDutyDedupKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return "go-grafana-" + hex.EncodeToString(sum[:16])
+}
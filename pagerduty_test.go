@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPagerDutyTriggerAndResolve(t *testing.T) {
+	hook := newWebhookRecorder(t, http.StatusAccepted)
+	n := newPagerDutyNotifier(notifierConfig{timeout: time.Second, pagerduty: pagerDutyConfig{
+		routingKey: "key",
+		eventsURL:  hook.URL,
+		severity:   "error",
+		severities: map[string]string{"checkout": "critical"},
+	}})
+
+	if err := n.Notify(testEvent(StateDown)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var trigger pagerDutyEvent
+	hook.next(t, &trigger)
+	if trigger.EventAction != "trigger" || trigger.RoutingKey != "key" || trigger.DedupKey == "" {
+		t.Errorf("unexpected trigger event: %+v", trigger)
+	}
+	if trigger.Payload == nil || trigger.Payload.Severity != "critical" || trigger.Payload.Source != "https://shop.example.com" ||
+		trigger.Payload.CustomDetails["reason"] != "returned status 503" {
+		t.Errorf("unexpected trigger payload: %+v", trigger.Payload)
+	}
+	if len(trigger.Links) != 1 || trigger.Links[0].Href != "https://grafana.example.com/d/abc" {
+		t.Errorf("expected dashboard link, got %+v", trigger.Links)
+	}
+
+	if err := n.Notify(testEvent(StateUp)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var resolve pagerDutyEvent
+	hook.next(t, &resolve)
+	if resolve.EventAction != "resolve" || resolve.DedupKey != trigger.DedupKey || resolve.Payload != nil {
+		t.Errorf("resolve must reuse the dedup key: %+v", resolve)
+	}
+}
+
+func TestPagerDutyDefaultSeverity(t *testing.T) {
+	n := newPagerDutyNotifier(notifierConfig{pagerduty: pagerDutyConfig{severity: "warning", severities: map[string]string{"api": "critical"}}})
+	if ev := n.event(testEvent(StateDown)); ev.Payload.Severity != "warning" {
+		t.Errorf("expected default severity, got %q", ev.Payload.Severity)
+	}
+}
+
+func TestPagerDutyDedupKeyStable(t *testing.T) {
+	a := pagerDutyDedupKey("https://a.example.com")
+	if a != pagerDutyDedupKey("https://a.example.com") {
+		t.Error("dedup key is not stable")
+	}
+	if a == pagerDutyDedupKey("https://b.example.com") {
+		t.Error("dedup keys of different targets collide")
+	}
+}
+
+func TestPagerDutyFailuresGoToTheOutbox(t *testing.T) {
+	var calls atomic.Int32
+	status := atomic.Int32{}
+	status.Store(http.StatusServiceUnavailable)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer srv.Close()
+
+	s := newTestService()
+	n := newPagerDutyNotifier(notifierConfig{timeout: time.Second, pagerduty: pagerDutyConfig{routingKey: "key", eventsURL: srv.URL, severity: "error"}})
+	s.notifiers = []notifierEntry{{name: "pager", notifier: n}}
+	s.outbox, _ = newTestOutbox(t, outboxConfig{maxAttempts: 5, backoff: time.Minute, maxBackoff: time.Minute})
+
+	s.sendSiteDownAlert(target{name: "checkout", url: "https://shop.example.com"}, "returned status 503")
+	if calls.Load() != 1 || len(s.outbox.pending) != 1 {
+		t.Fatalf("expected a single attempt and a queued retry, got %d attempts and %+v", calls.Load(), s.outbox.pending)
+	}
+	status.Store(http.StatusAccepted)
+	s.retryOutbox(time.Now().Add(2 * time.Minute))
+	if calls.Load() != 2 || len(s.outbox.pending) != 0 {
+		t.Errorf("expected the outbox to redeliver the event, got %d attempts and %+v", calls.Load(), s.outbox.pending)
+	}
+
+	// Client errors won't go away by retrying.
+	status.Store(http.StatusBadRequest)
+	s.sendSiteDownAlert(target{name: "api", url: "https://api.example.com"}, "returned status 503")
+	if dead := s.outbox.deadLetters(); calls.Load() != 3 || len(s.outbox.pending) != 0 || len(dead) != 1 || dead[0].Attempts != 1 {
+		t.Errorf("expected a client error to be dead-lettered right away, got %d attempts and %+v", calls.Load(), dead)
+	}
+}
+
+func TestValidatePagerDuty(t *testing.T) {
+	errs := &configErrors{}
+	cfg := parsePagerDuty(filePagerDuty{Severity: "loud", Severities: map[string]string{"api": "meh", "nope": "info"}})
+	validatePagerDuty(cfg, "pd", map[string]bool{"api": true}, errs)
+	if len(errs.problems) != 4 {
+		t.Errorf("expected 4 problems, got %v", errs.problems)
+	}
+}
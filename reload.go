@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const configWatchInterval = 5 * time.Second
+
+// monitorHandle tracks the goroutine checking a single target.
+type monitorHandle struct {
+	target target
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// handleReloads reloads the configuration on SIGHUP and whenever one of the
+// config files changes on disk.
+func (s *Service) handleReloads(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+	last := configFingerprint(s.watchedFiles())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Println("Received SIGHUP, reloading configuration...")
+			_ = s.reloadConfig()
+			last = configFingerprint(s.watchedFiles())
+		case <-ticker.C:
+			fp := configFingerprint(s.watchedFiles())
+			if fp == last {
+				continue
+			}
+			last = fp
+			log.Println("Config file changed, reloading configuration...")
+			_ = s.reloadConfig()
+		}
+	}
+}
+
+// reloadConfig loads the configuration again and applies it. An invalid
+// configuration is rejected and the current one keeps running.
+func (s *Service) reloadConfig() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Printf("Config reload rejected, keeping current configuration: %v", err)
+		return err
+	}
+	s.applyConfig(cfg)
+	log.Printf("Configuration reloaded: %d targets", len(cfg.targets))
+	return nil
+}
+
+// applyConfig swaps in cfg and starts, restarts or stops target checks so
+// that they match it. Failure state of targets kept across the reload is
+// preserved.
+func (s *Service) applyConfig(cfg appConfig) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	notifiers, err := s.buildNotifiers(cfg)
+	if err != nil {
+		log.Printf("Keeping previous notifiers: %v", err)
+	}
+
+	s.mu.Lock()
+	if !slices.Equal(cfg.timings.buckets, s.config.timings.buckets) {
+		log.Println("Changed timings.buckets only take effect after a restart")
+	}
+	s.config = cfg
+	if sender, ok := s.emailSender.(*SMTPSender); ok {
+		sender.setConfig(cfg)
+	}
+	if err == nil {
+		s.notifiers = notifiers
+	}
+	s.mu.Unlock()
+	if s.outbox != nil {
+		s.outbox.setConfig(cfg.outbox)
+	}
+	if s.silences != nil {
+		s.silences.setStatic(cfg.maintenance)
+	}
+
+	s.syncTargets(cfg.targets)
+}
+
+// syncTargets diffs the running checks against targets. Callers must hold
+// s.reloadMu.
+func (s *Service) syncTargets(targets []target) {
+	if s.monitors == nil {
+		s.monitors = make(map[string]*monitorHandle)
+	}
+	wanted := make(map[string]target, len(targets))
+	for _, t := range targets {
+		wanted[t.url] = t
+	}
+
+	var removed, changed []*monitorHandle
+	for url, h := range s.monitors {
+		t, ok := wanted[url]
+		switch {
+		case !ok:
+			log.Printf("Stopping checks for removed target %s", url)
+			h.cancel()
+			removed = append(removed, h)
+			delete(s.monitors, url)
+		case !reflect.DeepEqual(t, h.target):
+			log.Printf("Restarting checks for changed target %s", url)
+			h.cancel()
+			changed = append(changed, h)
+		}
+	}
+	// Wait for in-flight checks of changed targets so the old and new
+	// checks never update the target's state at the same time.
+	for _, h := range changed {
+		<-h.done
+		s.startMonitor(wanted[h.target.url])
+	}
+	for url, t := range wanted {
+		if _, ok := s.monitors[url]; !ok {
+			s.startMonitor(t)
+		}
+	}
+
+	// Wait for in-flight checks of removed targets so they can't recreate
+	// the series deleted below.
+	for _, h := range removed {
+		<-h.done
+		s.forgetTarget(h.target.url)
+	}
+	s.metrics.sites.Set(float64(len(s.monitors)))
+}
+
+func (s *Service) startMonitor(t target) {
+	ctx, cancel := context.WithCancel(s.runCtx)
+	h := &monitorHandle{target: t, cancel: cancel, done: make(chan struct{})}
+	s.monitors[t.url] = h
+	go func() {
+		defer close(h.done)
+		s.monitorTarget(ctx, t)
+	}()
+}
+
+// forgetTarget drops the state and metric series of a removed target.
+func (s *Service) forgetTarget(url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.offlineMap, url)
+	delete(s.failureCount, url)
+	delete(s.successCount, url)
+	delete(s.recentChecks, url)
+	delete(s.downSince, url)
+	delete(s.lastReason, url)
+	delete(s.lastStatus, url)
+	delete(s.history, url)
+	delete(s.lastAlert, url)
+	delete(s.reminders, url)
+	s.clearSuppressionLocked(url)
+	delete(s.silencedDown, url)
+	s.clearAckLocked(url)
+	delete(s.escalations, url)
+	s.forgetFlappingLocked(url)
+	delete(s.latency, url)
+	s.metrics.state.Delete(prometheus.Labels{"url": url})
+	s.metrics.phaseDuration.DeletePartialMatch(prometheus.Labels{"url": url})
+	s.metrics.phaseLast.DeletePartialMatch(prometheus.Labels{"url": url})
+	s.metrics.silenced.Delete(prometheus.Labels{"url": url})
+	s.metrics.siteStatus.Delete(prometheus.Labels{"url": url})
+	s.metrics.errorCounter.Delete(prometheus.Labels{"url": url})
+	s.updateOfflineSitesLocked()
+}
+
+// watchedFiles lists the files whose changes trigger a reload.
+func (s *Service) watchedFiles() []string {
+	s.mu.Lock()
+	configFile := s.config.configFile
+	s.mu.Unlock()
+
+	files := append([]string{envFile}, defaultConfigFiles...)
+	if configFile != "" {
+		files = append(files, configFile)
+	}
+	return files
+}
+
+// configFingerprint summarizes size and modification time of files so that
+// changes, creations and deletions can be detected by polling.
+func configFingerprint(files []string) string {
+	var b strings.Builder
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(&b, "%s:-;", path)
+			continue
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String()
+}
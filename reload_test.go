@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newReloadTestService(t *testing.T) (*Service, context.CancelFunc) {
+	t.Helper()
+	s, _ := newRecordingService()
+	s.metrics.sites = prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_sites_reload", Help: ""})
+	ctx, cancel := context.WithCancel(context.Background())
+	s.runCtx = ctx
+	return s, cancel
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestApplyConfigStartsAndStopsTargets(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	s, cancel := newReloadTestService(t)
+	defer cancel()
+	s.config.alertThreshold = 5
+
+	okTarget := target{url: ok.URL, method: "GET", timeout: time.Second, interval: time.Hour}
+	failTarget := target{url: failing.URL, method: "GET", timeout: time.Second, interval: time.Hour}
+	s.applyConfig(appConfig{alertThreshold: 5, targets: []target{okTarget, failTarget}})
+
+	waitFor(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.failureCount[failing.URL] == 1
+	})
+	if len(s.monitors) != 2 {
+		t.Fatalf("expected 2 running monitors, got %d", len(s.monitors))
+	}
+	if got := testutil.ToFloat64(s.metrics.sites); got != 2 {
+		t.Errorf("expected sites=2, got %v", got)
+	}
+
+	// Reload without the failing target but with a changed ok target.
+	old := s.monitors[ok.URL]
+	okTarget.labels = map[string]string{"team": "web"}
+	s.applyConfig(appConfig{alertThreshold: 5, targets: []target{okTarget}})
+	select {
+	case <-old.done:
+	default:
+		t.Error("checks of a changed target should stop before they are restarted")
+	}
+
+	if len(s.monitors) != 1 || s.monitors[ok.URL] == nil {
+		t.Fatalf("expected only %s to keep running, got %v", ok.URL, s.monitors)
+	}
+	s.mu.Lock()
+	_, found := s.failureCount[failing.URL]
+	s.mu.Unlock()
+	if found {
+		t.Errorf("failure state of removed target not cleared")
+	}
+	if n := testutil.CollectAndCount(s.metrics.errorCounter); n != 1 {
+		t.Errorf("expected stale error_sites series to be deleted, got %d series", n)
+	}
+	if got := testutil.ToFloat64(s.metrics.sites); got != 1 {
+		t.Errorf("expected sites=1, got %v", got)
+	}
+}
+
+func TestApplyConfigPreservesFailureState(t *testing.T) {
+	s, cancel := newReloadTestService(t)
+	defer cancel()
+
+	tgt := target{url: "http://127.0.0.1:0/unchanged", method: "GET", timeout: time.Second, interval: time.Hour}
+	s.reloadMu.Lock()
+	s.monitors = map[string]*monitorHandle{tgt.url: {target: tgt, cancel: func() {}, done: make(chan struct{})}}
+	s.reloadMu.Unlock()
+	s.offlineMap[tgt.url] = true
+	s.failureCount[tgt.url] = 7
+
+	s.applyConfig(appConfig{targets: []target{tgt}})
+
+	if !s.offlineMap[tgt.url] || s.failureCount[tgt.url] != 7 {
+		t.Errorf("state of unchanged target was reset: offline=%v failures=%d", s.offlineMap[tgt.url], s.failureCount[tgt.url])
+	}
+}
+
+func TestReloadConfigRejectsInvalidConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("targets:\n  - url: https://a.com\n    interval: never\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path})
+	defer cleanup()
+
+	s, cancel := newReloadTestService(t)
+	defer cancel()
+	old := appConfig{targets: []target{{url: "https://old.com"}}, alertThreshold: 3}
+	s.config = old
+
+	if err := s.reloadConfig(); err == nil {
+		t.Fatal("expected reload to fail")
+	}
+	if len(s.config.targets) != 1 || s.config.targets[0].url != "https://old.com" {
+		t.Errorf("old config should be kept, got %+v", s.config.targets)
+	}
+}
+
+func TestConfigFingerprintDetectsChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	before := configFingerprint([]string{path})
+	if err := os.WriteFile(path, []byte("targets: []\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	created := configFingerprint([]string{path})
+	if created == before {
+		t.Error("fingerprint should change when the file is created")
+	}
+	if err := os.WriteFile(path, []byte("targets:\n  - url: https://a.com\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if configFingerprint([]string{path}) == created {
+		t.Error("fingerprint should change when the file is modified")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// reminderConfig controls the repeated notifications sent while a target
+// stays down.
+type reminderConfig struct {
+	interval      time.Duration // Default reminder interval, 0 disables reminders
+	escalateAfter int           // Reminders after which the escalation notifiers are added
+	escalateTo    []string      // Notifiers that only receive escalated outages
+}
+
+type fileReminders struct {
+	Interval      string   `yaml:"interval" json:"interval"`
+	EscalateAfter int      `yaml:"escalate_after" json:"escalate_after"`
+	EscalateTo    []string `yaml:"escalate_to" json:"escalate_to"`
+}
+
+func (c *reminderConfig) applyFile(f fileReminders, errs *configErrors) {
+	if f.Interval != "" {
+		errs.duration("reminders.interval", f.Interval, &c.interval)
+	}
+	if f.EscalateAfter != 0 {
+		c.escalateAfter = f.EscalateAfter
+	}
+	if len(f.EscalateTo) > 0 {
+		c.escalateTo = f.EscalateTo
+	}
+}
+
+func validateReminders(cfg appConfig, errs *configErrors) {
+	r := cfg.reminders
+	if r.interval < 0 {
+		errs.add("reminders.interval", "must not be negative")
+	}
+	for i, t := range cfg.targets {
+		if t.reminderInterval < 0 {
+			errs.add(fmt.Sprintf("targets[%d].reminder_interval", i), "must not be negative")
+		}
+	}
+	if r.escalateAfter < 0 {
+		errs.add("reminders.escalate_after", "must not be negative, got %d", r.escalateAfter)
+	}
+	if len(r.escalateTo) > 0 && r.escalateAfter == 0 {
+		errs.add("reminders.escalate_after", "required when reminders.escalate_to is set")
+	}
+	if r.escalateAfter > 0 && len(r.escalateTo) == 0 {
+		errs.add("reminders.escalate_to", "required when reminders.escalate_after is set")
+	}
+	for i, name := range r.escalateTo {
+		if !slices.ContainsFunc(cfg.notifiers, func(nc notifierConfig) bool { return nc.name == name }) {
+			errs.add(fmt.Sprintf("reminders.escalate_to[%d]", i), "unknown notifier %q", name)
+		}
+	}
+}
+
+// escalated reports whether an outage that has sent n reminders is escalated.
+func (c reminderConfig) escalated(n int) bool {
+	return c.escalateAfter > 0 && n >= c.escalateAfter
+}
+
+// reminderDueLocked reports whether a reminder for the down target t is due
+// at now and, if so, counts it. Acknowledged outages get no reminders.
+// Callers must hold s.mu.
+func (s *Service) reminderDueLocked(t target, now time.Time) bool {
+	if _, acked := s.acked[t.url]; acked {
+		return false
+	}
+	if t.reminderInterval <= 0 || now.Sub(s.lastAlert[t.url]) < t.reminderInterval {
+		return false
+	}
+	s.lastAlert[t.url] = now
+	s.reminders[t.url]++
+	return true
+}
+
+// sendReminder notifies that t is still down. Once the outage is escalated
+// the escalation notifiers receive it as well.
+func (s *Service) sendReminder(t target, reason string) {
+	ev := s.newAlertEvent(t, StateDown, reason)
+	s.mu.Lock()
+	escalateAfter := s.config.reminders.escalateAfter
+	s.mu.Unlock()
+	if ev.Escalated && ev.Reminder == escalateAfter {
+		log.Printf("Escalating outage of %s after %d reminders", t.url, ev.Reminder)
+	}
+	s.dispatch(ev)
+}
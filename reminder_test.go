@@ -0,0 +1,153 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// backdateLastAlert makes the next reminder of url due.
+func backdateLastAlert(s *Service, url string, d time.Duration) {
+	s.mu.Lock()
+	s.lastAlert[url] = s.lastAlert[url].Add(-d)
+	s.mu.Unlock()
+}
+
+func TestRemindersAndEscalation(t *testing.T) {
+	s := newTestService()
+	team := &recordingNotifier{}
+	oncall := &recordingNotifier{}
+	s.notifiers = []notifierEntry{
+		{name: "team", notifier: team},
+		{name: "oncall", escalation: true, notifier: oncall},
+	}
+	s.config.alertThreshold = 1
+	s.config.reminders = reminderConfig{interval: 30 * time.Minute, escalateAfter: 2, escalateTo: []string{"oncall"}}
+	tgt := target{name: "api", url: "https://api.com", reminderInterval: 30 * time.Minute}
+
+	s.handleSiteError(tgt, "returned status 503")
+	s.handleSiteError(tgt, "returned status 503")
+	if got := team.received(); len(got) != 1 || got[0].Reminder != 0 {
+		t.Fatalf("expected only the initial DOWN alert before the interval passed, got %+v", got)
+	}
+
+	backdateLastAlert(s, tgt.url, 31*time.Minute)
+	s.handleSiteError(tgt, "returned status 503")
+	got := team.received()
+	if len(got) != 2 || got[1].State != StateDown || got[1].Reminder != 1 || got[1].Escalated {
+		t.Fatalf("expected a first, unescalated reminder, got %+v", got)
+	}
+	if len(oncall.received()) != 0 {
+		t.Fatal("escalation notifier should not hear about unescalated outages")
+	}
+
+	backdateLastAlert(s, tgt.url, 31*time.Minute)
+	s.handleSiteError(tgt, "returned status 503")
+	if got := team.received(); len(got) != 3 || got[2].Reminder != 2 || !got[2].Escalated {
+		t.Fatalf("expected an escalated second reminder, got %+v", got)
+	}
+	if got := oncall.received(); len(got) != 1 || got[0].Reminder != 2 || got[0].Downtime() < 0 {
+		t.Fatalf("escalation notifier should receive the escalated reminder, got %+v", got)
+	}
+
+	s.handleSiteRecovery(tgt)
+	if got := oncall.received(); len(got) != 2 || got[1].State != StateUp || !got[1].Escalated {
+		t.Fatalf("escalation notifier should receive the recovery of an escalated outage, got %+v", got)
+	}
+	if _, ok := s.reminders[tgt.url]; ok {
+		t.Error("reminder state should be reset on recovery")
+	}
+}
+
+func TestRemindersDisabled(t *testing.T) {
+	s := newTestService()
+	rec := &recordingNotifier{}
+	s.notifiers = []notifierEntry{{name: "team", notifier: rec}}
+	s.config.alertThreshold = 1
+	tgt := target{name: "api", url: "https://api.com"}
+
+	s.handleSiteError(tgt, "returned status 503")
+	backdateLastAlert(s, tgt.url, 24*time.Hour)
+	s.handleSiteError(tgt, "returned status 503")
+	if got := rec.received(); len(got) != 1 {
+		t.Errorf("expected no reminders without an interval, got %d events", len(got))
+	}
+}
+
+func TestReminderMessages(t *testing.T) {
+	ev := testEvent(StateDown)
+	ev.Reminder = 3
+	subject, text, _, err := alertTemplates{}.render(ev)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if subject != "[🔁 STILL DOWN] https://shop.example.com for 42m0s (returned status 503)" {
+		t.Errorf("unexpected reminder subject: %q", subject)
+	}
+	if !strings.Contains(text, "still down after 42m0s") {
+		t.Errorf("reminder body should include the downtime: %q", text)
+	}
+	if title, body := plainMessage(ev); !strings.HasPrefix(title, "🔁 STILL DOWN") || !strings.Contains(body, "42m0s") {
+		t.Errorf("unexpected push reminder: %q %q", title, body)
+	}
+}
+
+func TestLoadConfigReminders(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+reminders:
+  interval: 30m
+  escalate_after: 4
+  escalate_to: [pager]
+notifiers:
+  - name: pager
+    type: webhook
+    webhook:
+      url: https://hooks.example.com/pager
+targets:
+  - name: api
+    url: https://api.example.com
+  - name: blog
+    url: https://blog.example.com
+    reminder_interval: 0s
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "CHECK_INTERVAL": "", "ALERT_THRESHOLD": "", "REMINDER_INTERVAL": ""})
+	defer cleanup()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.reminders.interval != 30*time.Minute || cfg.reminders.escalateAfter != 4 {
+		t.Errorf("reminders not loaded: %+v", cfg.reminders)
+	}
+	if cfg.targets[0].reminderInterval != 30*time.Minute || cfg.targets[1].reminderInterval != 0 {
+		t.Errorf("target reminder intervals not applied: %v %v", cfg.targets[0].reminderInterval, cfg.targets[1].reminderInterval)
+	}
+
+	s := newTestService()
+	entries, err := s.buildNotifiers(cfg)
+	if err != nil || len(entries) != 1 || !entries[0].escalation {
+		t.Errorf("pager should be an escalation notifier: %+v %v", entries, err)
+	}
+}
+
+func TestValidateReminders(t *testing.T) {
+	cfg := appConfig{
+		targets:   []target{{name: "api", reminderInterval: -time.Minute}},
+		notifiers: []notifierConfig{{name: "pager"}},
+		reminders: reminderConfig{interval: -time.Second, escalateTo: []string{"pager", "nobody"}},
+	}
+	errs := &configErrors{}
+	validateReminders(cfg, errs)
+	msg := errs.Error()
+	for _, want := range []string{
+		"reminders.interval: must not be negative",
+		"targets[0].reminder_interval: must not be negative",
+		"reminders.escalate_after: required when reminders.escalate_to is set",
+		`reminders.escalate_to[1]: unknown notifier "nobody"`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("missing %q in %s", want, msg)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// emailRecipients are the addresses an alert email is sent to.
+type emailRecipients struct {
+	to  []string
+	cc  []string
+	bcc []string
+}
+
+func (r emailRecipients) empty() bool {
+	return len(r.to) == 0 && len(r.cc) == 0 && len(r.bcc) == 0
+}
+
+// add appends the addresses of o that r doesn't contain yet.
+func (r *emailRecipients) add(o emailRecipients) {
+	r.to = appendNew(r.to, o.to...)
+	r.cc = appendNew(r.cc, o.cc...)
+	r.bcc = appendNew(r.bcc, o.bcc...)
+}
+
+func appendNew(list []string, addrs ...string) []string {
+	for _, a := range addrs {
+		if !slices.Contains(list, a) {
+			list = append(list, a)
+		}
+	}
+	return list
+}
+
+// emailRoute sends alerts of matching targets to its own recipients instead
+// of the SMTP defaults.
+type emailRoute struct {
+	targets    []string          // Target names, empty matches all
+	labels     map[string]string // All labels must match
+	recipients emailRecipients
+	cont       bool // Keep evaluating later routes after a match
+}
+
+func (r emailRoute) matches(t target) bool {
+	if len(r.targets) > 0 && !slices.Contains(r.targets, t.name) {
+		return false
+	}
+	for k, v := range r.labels {
+		if t.labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+type fileRoute struct {
+	Targets  []string          `yaml:"targets" json:"targets"`
+	Labels   map[string]string `yaml:"labels" json:"labels"`
+	To       addressList       `yaml:"to" json:"to"`
+	CC       addressList       `yaml:"cc" json:"cc"`
+	BCC      addressList       `yaml:"bcc" json:"bcc"`
+	Continue bool              `yaml:"continue" json:"continue"`
+}
+
+func parseRoutes(frs []fileRoute) []emailRoute {
+	var routes []emailRoute
+	for _, fr := range frs {
+		routes = append(routes, emailRoute{
+			targets:    fr.Targets,
+			labels:     fr.Labels,
+			recipients: emailRecipients{to: fr.To, cc: fr.CC, bcc: fr.BCC},
+			cont:       fr.Continue,
+		})
+	}
+	return routes
+}
+
+func validateRoutes(cfg appConfig, names map[string]bool, errs *configErrors) {
+	for i, r := range cfg.emailRoutes {
+		field := fmt.Sprintf("smtp.routes[%d]", i)
+		if r.recipients.empty() {
+			errs.add(field, "route has no recipients")
+		}
+		for j, name := range r.targets {
+			if !names[name] {
+				errs.add(fmt.Sprintf("%s.targets[%d]", field, j), "unknown target %q", name)
+			}
+		}
+		validateAddresses(r.recipients.to, field+".to", errs.add)
+		validateAddresses(r.recipients.cc, field+".cc", errs.add)
+		validateAddresses(r.recipients.bcc, field+".bcc", errs.add)
+	}
+}
+
+// validateAddresses checks that every entry of addrs is a valid email address.
+func validateAddresses(addrs []string, field string, add func(field, format string, args ...any)) {
+	for i, a := range addrs {
+		if _, err := mail.ParseAddress(a); err != nil {
+			add(fmt.Sprintf("%s[%d]", field, i), "invalid email address %q", a)
+		}
+	}
+}
+
+// resolveRecipients returns the recipients of alerts for t: the recipients
+// of all matching routes, or the SMTP defaults if no route matches. The
+// target's own recipients replace To.
+func resolveRecipients(cfg appConfig, t target) emailRecipients {
+	var rcpt emailRecipients
+	matched := false
+	for _, r := range cfg.emailRoutes {
+		if !r.matches(t) {
+			continue
+		}
+		rcpt.add(r.recipients)
+		matched = true
+		if !r.cont {
+			break
+		}
+	}
+	if !matched {
+		rcpt.add(emailRecipients{to: cfg.smtpTo, cc: cfg.smtpCC, bcc: cfg.smtpBCC})
+	}
+	if len(t.recipients) > 0 {
+		rcpt.to = slices.Clone(t.recipients)
+	}
+	return rcpt
+}
+
+// addressList is a list of email addresses given either as a YAML/JSON list
+// or as a comma-separated string.
+type addressList []string
+
+// splitAddresses splits a comma-separated address list.
+func splitAddresses(s string) []string {
+	var out []string
+	for _, a := range strings.Split(s, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func (l *addressList) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*l = splitAddresses(n.Value)
+		return nil
+	}
+	var list []string
+	if err := n.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+func (l *addressList) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = splitAddresses(s)
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
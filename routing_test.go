@@ -0,0 +1,167 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func routingTestConfig() appConfig {
+	return appConfig{
+		smtpTo:  []string{"ops@example.com"},
+		smtpCC:  []string{"lead@example.com"},
+		smtpBCC: []string{"archive@example.com"},
+		emailRoutes: []emailRoute{
+			{
+				labels:     map[string]string{"team": "payments"},
+				recipients: emailRecipients{to: []string{"payments@example.com"}, cc: []string{"finance@example.com"}},
+				cont:       true,
+			},
+			{
+				targets:    []string{"checkout"},
+				recipients: emailRecipients{to: []string{"checkout@example.com", "payments@example.com"}},
+			},
+			{
+				labels:     map[string]string{"team": "web"},
+				recipients: emailRecipients{to: []string{"web@example.com"}, bcc: []string{"agency@example.com"}},
+			},
+			{
+				labels:     map[string]string{"team": "web"},
+				recipients: emailRecipients{to: []string{"never@example.com"}},
+			},
+		},
+	}
+}
+
+func TestResolveRecipients(t *testing.T) {
+	cfg := routingTestConfig()
+	cases := []struct {
+		name   string
+		target target
+		want   emailRecipients
+	}{
+		{
+			"no route matches",
+			target{name: "blog", labels: map[string]string{"team": "content"}},
+			emailRecipients{to: []string{"ops@example.com"}, cc: []string{"lead@example.com"}, bcc: []string{"archive@example.com"}},
+		},
+		{
+			"continue merges routes",
+			target{name: "checkout", labels: map[string]string{"team": "payments"}},
+			emailRecipients{to: []string{"payments@example.com", "checkout@example.com"}, cc: []string{"finance@example.com"}},
+		},
+		{
+			"first match wins",
+			target{name: "marketing", labels: map[string]string{"team": "web"}},
+			emailRecipients{to: []string{"web@example.com"}, bcc: []string{"agency@example.com"}},
+		},
+		{
+			"target recipients replace to",
+			target{name: "marketing", labels: map[string]string{"team": "web"}, recipients: []string{"owner@example.com"}},
+			emailRecipients{to: []string{"owner@example.com"}, bcc: []string{"agency@example.com"}},
+		},
+	}
+	for _, c := range cases {
+		got := resolveRecipients(cfg, c.target)
+		if !slices.Equal(got.to, c.want.to) || !slices.Equal(got.cc, c.want.cc) || !slices.Equal(got.bcc, c.want.bcc) {
+			t.Errorf("%s: expected %+v, got %+v", c.name, c.want, got)
+		}
+	}
+}
+
+func TestEmailRoutingWithMockSender(t *testing.T) {
+	mock := &mockSender{}
+	s := &Service{config: routingTestConfig(), emailSender: mock}
+
+	s.sendSiteDownAlert(target{name: "checkout", url: "https://shop.example.com/checkout", labels: map[string]string{"team": "payments"}}, "returned status 500")
+	if !slices.Equal(mock.lastTo, []string{"payments@example.com", "checkout@example.com"}) || !slices.Equal(mock.lastCC, []string{"finance@example.com"}) {
+		t.Errorf("payments alert routed to %v cc %v", mock.lastTo, mock.lastCC)
+	}
+
+	s.sendSiteRecoveryAlert(target{name: "www", url: "https://www.example.com", labels: map[string]string{"team": "web"}})
+	if !slices.Equal(mock.lastTo, []string{"web@example.com"}) || len(mock.lastCC) != 0 || !slices.Equal(mock.lastBCC, []string{"agency@example.com"}) {
+		t.Errorf("web alert routed to %v cc %v bcc %v", mock.lastTo, mock.lastCC, mock.lastBCC)
+	}
+}
+
+func TestLoadConfigEmailRoutes(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+smtp:
+  server: smtp.example.com
+  port: "587"
+  from: monitor@example.com
+  to: ops@example.com, oncall@example.com
+  bcc: [archive@example.com]
+  routes:
+    - labels: {team: payments}
+      to: [payments@example.com]
+      cc: finance@example.com
+targets:
+  - name: checkout
+    url: https://shop.example.com
+    labels: {team: payments}
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "CHECK_INTERVAL": "", "ALERT_THRESHOLD": "", "SMTP_USER": "", "SMTP_PASS": ""})
+	defer cleanup()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(cfg.smtpTo, []string{"ops@example.com", "oncall@example.com"}) || !slices.Equal(cfg.smtpBCC, []string{"archive@example.com"}) {
+		t.Errorf("SMTP address lists not loaded: %v %v", cfg.smtpTo, cfg.smtpBCC)
+	}
+	if len(cfg.emailRoutes) != 1 || !slices.Equal(cfg.emailRoutes[0].recipients.cc, []string{"finance@example.com"}) {
+		t.Errorf("routes not loaded: %+v", cfg.emailRoutes)
+	}
+}
+
+func TestValidateEmailRoutes(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `smtp:
+  server: smtp.example.com
+  port: "587"
+  from: monitor@example.com
+  cc: [not-an-address]
+  routes:
+    - targets: [checkout, missing]
+      to: [payments@example.com]
+    - labels: {team: web}
+targets:
+  - name: checkout
+    url: https://shop.example.com
+  - name: www
+    url: https://www.example.com
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "CHECK_INTERVAL": "", "ALERT_THRESHOLD": "", "SMTP_TO": "", "SMTP_USER": "", "SMTP_PASS": ""})
+	defer cleanup()
+
+	_, err := loadConfig()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		path + ":5: smtp.cc: invalid email address \"not-an-address\"",
+		path + ":7: smtp.routes[0].targets[1]: unknown target \"missing\"",
+		path + ":9: smtp.routes[1]: route has no recipients",
+		"env: SMTP_TO: required unless every target sets recipients",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in:\n%s", want, err)
+		}
+	}
+}
+
+func TestAddressListJSON(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{
+  "smtp": {"to": "a@example.com, b@example.com", "cc": ["c@example.com"]},
+  "targets": [{"url": "https://a.com"}]
+}`)
+	var errs configErrors
+	fc, _ := readConfigFile(path, &errs)
+	if err := errs.err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(fc.SMTP.To, addressList{"a@example.com", "b@example.com"}) || !slices.Equal(fc.SMTP.CC, addressList{"c@example.com"}) {
+		t.Errorf("unexpected address lists: %v %v", fc.SMTP.To, fc.SMTP.CC)
+	}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultSilencesPath    = "data/silences.json"
+	silenceRefreshInterval = 30 * time.Second
+)
+
+var errConfigSilence = errors.New("maintenance windows from the config file can only be removed there")
+
+// Silence mutes the notifications of the matching targets during a one-off
+// window (start/end) or a recurring one (cron/duration). Checks and metrics
+// keep running.
+type Silence struct {
+	ID        string            `json:"id"`
+	Targets   []string          `json:"targets,omitempty"` // Target names or URLs
+	Labels    map[string]string `json:"labels,omitempty"`  // All labels must match
+	Start     time.Time         `json:"start,omitzero"`
+	End       time.Time         `json:"end,omitzero"`
+	Cron      string            `json:"cron,omitempty"`     // Start of each recurring window
+	Duration  string            `json:"duration,omitempty"` // Length of each recurring window
+	Timezone  string            `json:"timezone,omitempty"` // Location of Cron, default UTC
+	Comment   string            `json:"comment,omitempty"`
+	CreatedBy string            `json:"created_by,omitempty"`
+	CreatedAt time.Time         `json:"created_at,omitzero"`
+
+	schedule cronSchedule
+	duration time.Duration
+	location *time.Location
+}
+
+// fileMaintenance is a maintenance window in the config file.
+type fileMaintenance struct {
+	Targets  []string          `yaml:"targets" json:"targets"`
+	Labels   map[string]string `yaml:"labels" json:"labels"`
+	Start    string            `yaml:"start" json:"start"`
+	End      string            `yaml:"end" json:"end"`
+	Cron     string            `yaml:"cron" json:"cron"`
+	Duration string            `yaml:"duration" json:"duration"`
+	Timezone string            `yaml:"timezone" json:"timezone"`
+	Comment  string            `yaml:"comment" json:"comment"`
+}
+
+type fileSilences struct {
+	Path string `yaml:"path" json:"path"`
+}
+
+// parseMaintenance converts the maintenance windows of the config file.
+func parseMaintenance(fms []fileMaintenance, errs *configErrors) []Silence {
+	var out []Silence
+	for i, fm := range fms {
+		field := fmt.Sprintf("maintenance[%d]", i)
+		sil := Silence{
+			ID:       fmt.Sprintf("maintenance-%d", i),
+			Targets:  fm.Targets,
+			Labels:   fm.Labels,
+			Cron:     fm.Cron,
+			Duration: fm.Duration,
+			Timezone: fm.Timezone,
+			Comment:  fm.Comment,
+		}
+		valid := true
+		for _, ts := range []struct {
+			name  string
+			value string
+			dst   *time.Time
+		}{{"start", fm.Start, &sil.Start}, {"end", fm.End, &sil.End}} {
+			if ts.value == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, ts.value)
+			if err != nil {
+				errs.add(field+"."+ts.name, "invalid time %q (use RFC 3339, e.g. 2024-06-01T22:00:00+02:00)", ts.value)
+				valid = false
+			}
+			*ts.dst = t
+		}
+		if !valid {
+			continue
+		}
+		if err := sil.compile(); err != nil {
+			errs.add(field, "%v", err)
+			continue
+		}
+		out = append(out, sil)
+	}
+	return out
+}
+
+// validateMaintenance checks that maintenance windows select known targets.
+func validateMaintenance(cfg appConfig, errs *configErrors) {
+	for i, sil := range cfg.maintenance {
+		for j, ref := range sil.Targets {
+			if _, ok := findTarget(cfg.targets, ref); !ok {
+				errs.add(fmt.Sprintf("maintenance[%d].targets[%d]", i, j), "unknown target %q", ref)
+			}
+		}
+	}
+}
+
+// compile validates the silence and prepares its schedule.
+func (sil *Silence) compile() error {
+	if len(sil.Targets) == 0 && len(sil.Labels) == 0 {
+		return errors.New("needs targets or labels to select what to silence")
+	}
+	loc := time.UTC
+	if sil.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(sil.Timezone); err != nil {
+			return fmt.Errorf("unknown timezone %q", sil.Timezone)
+		}
+	}
+	sil.location = loc
+	switch {
+	case sil.Cron != "" || sil.Duration != "":
+		if !sil.Start.IsZero() || !sil.End.IsZero() {
+			return errors.New("use either start/end or cron/duration")
+		}
+		schedule, err := parseCron(sil.Cron)
+		if err != nil {
+			return fmt.Errorf("invalid cron %q: %v", sil.Cron, err)
+		}
+		d, err := time.ParseDuration(sil.Duration)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid duration %q", sil.Duration)
+		}
+		sil.schedule, sil.duration = schedule, d
+	case sil.Start.IsZero() || sil.End.IsZero():
+		return errors.New("needs start and end, or cron and duration")
+	case !sil.End.After(sil.Start):
+		return errors.New("end must be after start")
+	}
+	return nil
+}
+
+// recurring reports whether the silence repeats on a cron schedule.
+func (sil Silence) recurring() bool {
+	return sil.Cron != ""
+}
+
+// activeAt reports whether the silence mutes notifications at now.
+func (sil Silence) activeAt(now time.Time) bool {
+	if sil.recurring() {
+		_, ok := sil.schedule.activeWindow(now, sil.duration, sil.location)
+		return ok
+	}
+	return !now.Before(sil.Start) && now.Before(sil.End)
+}
+
+// expired reports whether a one-off silence has ended.
+func (sil Silence) expired(now time.Time) bool {
+	return !sil.recurring() && !now.Before(sil.End)
+}
+
+// matches reports whether the silence selects t.
+func (sil Silence) matches(t target) bool {
+	if len(sil.Targets) > 0 && !slices.Contains(sil.Targets, t.name) && !slices.Contains(sil.Targets, t.url) {
+		return false
+	}
+	for k, v := range sil.Labels {
+		if t.labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// silenceStore holds the maintenance windows of the config file and the
+// silences created through the API, which are persisted as JSON.
+type silenceStore struct {
+	mu     sync.Mutex
+	path   string
+	static []Silence // From the config file
+	items  []Silence // From the API
+}
+
+// newSilenceStore creates a store and loads the silences persisted at path.
+func newSilenceStore(path string) (*silenceStore, error) {
+	st := &silenceStore{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading silences: %w", err)
+	}
+	var items []Silence
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("reading silences %s: %w", path, err)
+	}
+	for _, sil := range items {
+		if err := sil.compile(); err != nil {
+			log.Printf("Dropping invalid silence %s: %v", sil.ID, err)
+			continue
+		}
+		st.items = append(st.items, sil)
+	}
+	return st, nil
+}
+
+// setStatic replaces the maintenance windows from the config file.
+func (st *silenceStore) setStatic(windows []Silence) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.static = windows
+}
+
+// add validates and stores a new silence.
+func (st *silenceStore) add(sil Silence, now time.Time) (Silence, error) {
+	if err := sil.compile(); err != nil {
+		return Silence{}, err
+	}
+	if sil.expired(now) {
+		return Silence{}, errors.New("end is in the past")
+	}
+	sil.ID = newOutboxID()
+	sil.CreatedAt = now
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.items = append(st.items, sil)
+	st.saveLocked()
+	return sil, nil
+}
+
+// remove deletes the silence with id and reports whether it existed.
+func (st *silenceStore) remove(id string) (bool, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if slices.ContainsFunc(st.static, func(sil Silence) bool { return sil.ID == id }) {
+		return true, errConfigSilence
+	}
+	n := len(st.items)
+	st.items = slices.DeleteFunc(st.items, func(sil Silence) bool { return sil.ID == id })
+	if len(st.items) == n {
+		return false, nil
+	}
+	st.saveLocked()
+	return true, nil
+}
+
+// prune drops one-off silences that have ended.
+func (st *silenceStore) prune(now time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	n := len(st.items)
+	st.items = slices.DeleteFunc(st.items, func(sil Silence) bool { return sil.expired(now) })
+	if len(st.items) != n {
+		st.saveLocked()
+	}
+}
+
+// silenceStatus is a silence as listed by the API.
+type silenceStatus struct {
+	Silence
+	Source string `json:"source"` // config or api
+	Active bool   `json:"active"`
+}
+
+func (st *silenceStore) list(now time.Time) []silenceStatus {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	out := []silenceStatus{}
+	for _, sil := range st.static {
+		out = append(out, silenceStatus{Silence: sil, Source: "config", Active: sil.activeAt(now)})
+	}
+	for _, sil := range st.items {
+		out = append(out, silenceStatus{Silence: sil, Source: "api", Active: sil.activeAt(now)})
+	}
+	return out
+}
+
+// silenced reports whether an active silence selects t at now.
+func (st *silenceStore) silenced(t target, now time.Time) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, sil := range slices.Concat(st.static, st.items) {
+		if sil.matches(t) && sil.activeAt(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// saveLocked persists the API silences. Callers must hold st.mu.
+func (st *silenceStore) saveLocked() {
+	if st.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(st.items, "", "  ")
+	if err != nil {
+		log.Printf("Encoding silences: %v", err)
+		return
+	}
+	if err := writeFileAtomic(st.path, data); err != nil {
+		log.Printf("Saving silences: %v", err)
+	}
+}
+
+// isSilenced reports whether notifications for t are muted at now.
+func (s *Service) isSilenced(t target, now time.Time) bool {
+	return s.silences != nil && s.silences.silenced(t, now)
+}
+
+// updateSilenced sets site_silenced for t.
+func (s *Service) updateSilenced(t target, silenced bool) {
+	v := 0.0
+	if silenced {
+		v = 1
+	}
+	s.metrics.silenced.With(prometheus.Labels{"url": t.url}).Set(v)
+}
+
+// refreshSilenced updates site_silenced for all targets and drops expired silences.
+func (s *Service) refreshSilenced(now time.Time) {
+	if s.silences == nil {
+		return
+	}
+	s.silences.prune(now)
+	s.mu.Lock()
+	targets := s.config.targets
+	s.mu.Unlock()
+	for _, t := range targets {
+		s.updateSilenced(t, s.silences.silenced(t, now))
+	}
+}
+
+// watchSilences keeps site_silenced current between checks until ctx is done.
+func (s *Service) watchSilences(ctx context.Context) {
+	ticker := time.NewTicker(silenceRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.refreshSilenced(time.Now())
+		}
+	}
+}
+
+// handleSilences serves the silences API: GET /silences lists all silences,
+// POST /silences creates one and DELETE /silences/{id} removes it. Writes
+// require the API token.
+func (s *Service) handleSilences(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/silences"), "/")
+	if r.Method != http.MethodGet && !s.requireAPIToken(w, r) {
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		writeJSON(w, http.StatusOK, s.silences.list(time.Now()))
+	case r.Method == http.MethodPost && id == "":
+		var sil Silence
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&sil); err != nil {
+			http.Error(w, fmt.Sprintf("invalid silence: %v", err), http.StatusBadRequest)
+			return
+		}
+		created, err := s.silences.add(sil, time.Now())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid silence: %v", err), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Created silence %s for targets %v labels %v (%s)", created.ID, created.Targets, created.Labels, created.Comment)
+		s.refreshSilenced(time.Now())
+		writeJSON(w, http.StatusCreated, created)
+	case r.Method == http.MethodDelete && id != "":
+		found, err := s.silences.remove(id)
+		switch {
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusConflict)
+		case !found:
+			http.Error(w, "silence not found", http.StatusNotFound)
+		default:
+			log.Printf("Deleted silence %s", id)
+			s.refreshSilenced(time.Now())
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestSilenceStore(t *testing.T) *silenceStore {
+	t.Helper()
+	st, err := newSilenceStore(filepath.Join(t.TempDir(), "silences.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func TestSilenceMutesAlerts(t *testing.T) {
+	s, rec := newRecordingService()
+	s.silences = newTestSilenceStore(t)
+	tgt := target{name: "api", url: "https://api.com", labels: map[string]string{"team": "payments"}}
+	now := time.Now()
+	sil, err := s.silences.add(Silence{Labels: map[string]string{"team": "payments"}, Start: now.Add(-time.Minute), End: now.Add(time.Hour)}, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s.handleSiteError(tgt, "returned status 503")
+	s.handleSiteError(tgt, "returned status 503")
+	if len(rec.received()) != 0 {
+		t.Fatal("DOWN alert should be muted during a silence")
+	}
+	if !s.offlineMap[tgt.url] || s.failureCount[tgt.url] != 2 {
+		t.Error("checks should still be recorded during a silence")
+	}
+	if got := testutil.ToFloat64(s.metrics.silenced.With(prometheus.Labels{"url": tgt.url})); got != 1 {
+		t.Errorf("expected site_silenced 1, got %v", got)
+	}
+
+	// Still down when the silence ends: the DOWN alert follows.
+	s.silences.remove(sil.ID)
+	s.handleSiteError(tgt, "returned status 503")
+	if got := rec.received(); len(got) != 1 || got[0].State != StateDown {
+		t.Fatalf("expected the DOWN alert after the silence, got %+v", got)
+	}
+	if got := testutil.ToFloat64(s.metrics.silenced.With(prometheus.Labels{"url": tgt.url})); got != 0 {
+		t.Errorf("expected site_silenced 0, got %v", got)
+	}
+}
+
+func TestSilenceMutesRecoveryOfSilencedOutage(t *testing.T) {
+	s, rec := newRecordingService()
+	s.silences = newTestSilenceStore(t)
+	tgt := target{name: "api", url: "https://api.com"}
+	now := time.Now()
+	sil, _ := s.silences.add(Silence{Targets: []string{"api"}, Start: now.Add(-time.Minute), End: now.Add(time.Hour)}, now)
+
+	s.handleSiteError(tgt, "returned status 503")
+	s.silences.remove(sil.ID)
+	s.handleSiteRecovery(tgt)
+	if len(rec.received()) != 0 {
+		t.Error("no recovery notice should be sent for an outage whose DOWN alert was muted")
+	}
+	if s.silencedDown[tgt.url] {
+		t.Error("silenced state should be reset on recovery")
+	}
+}
+
+func TestRecoveryOfAlertedOutageDuringSilence(t *testing.T) {
+	s, rec := newRecordingService()
+	s.silences = newTestSilenceStore(t)
+	tgt := target{name: "api", url: "https://api.com"}
+
+	s.handleSiteError(tgt, "returned status 503")
+	now := time.Now()
+	if _, err := s.silences.add(Silence{Targets: []string{"api"}, Start: now.Add(-time.Minute), End: now.Add(time.Hour)}, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s.handleSiteRecovery(tgt)
+	if got := rec.received(); len(got) != 2 || got[1].State != StateUp {
+		t.Errorf("an outage alerted before the silence should get its recovery notice, got %+v", got)
+	}
+}
+
+func TestRecurringSilence(t *testing.T) {
+	sil := Silence{Targets: []string{"api"}, Cron: "0 22 * * 6", Duration: "2h", Timezone: "UTC"}
+	if err := sil.compile(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !sil.activeAt(time.Date(2024, 6, 1, 23, 30, 0, 0, time.UTC)) {
+		t.Error("recurring silence should be active during its window")
+	}
+	if sil.activeAt(time.Date(2024, 6, 2, 0, 30, 0, 0, time.UTC)) {
+		t.Error("recurring silence should not be active after its window")
+	}
+	if sil.expired(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("recurring silences never expire")
+	}
+}
+
+func TestSilenceCompileErrors(t *testing.T) {
+	now := time.Now()
+	cases := map[string]Silence{
+		"needs targets or labels":  {Start: now, End: now.Add(time.Hour)},
+		"needs start and end":      {Targets: []string{"api"}},
+		"end must be after start":  {Targets: []string{"api"}, Start: now, End: now},
+		"invalid cron":             {Targets: []string{"api"}, Cron: "every day", Duration: "1h"},
+		"invalid duration":         {Targets: []string{"api"}, Cron: "0 0 * * *"},
+		"unknown timezone":         {Targets: []string{"api"}, Cron: "0 0 * * *", Duration: "1h", Timezone: "Mars/Olympus"},
+		"either start/end or cron": {Targets: []string{"api"}, Cron: "0 0 * * *", Duration: "1h", Start: now},
+	}
+	for want, sil := range cases {
+		if err := sil.compile(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q, got %v", want, err)
+		}
+	}
+}
+
+func TestSilencesAPI(t *testing.T) {
+	s, _ := newRecordingService()
+	s.silences = newTestSilenceStore(t)
+	window := Silence{ID: "maintenance-0", Targets: []string{"db"}, Cron: "0 3 * * *", Duration: "1h"}
+	if err := window.compile(); err != nil {
+		t.Fatal(err)
+	}
+	s.silences.setStatic([]Silence{window})
+	s.config.targets = []target{{name: "api", url: "https://api.com"}}
+	s.config.acks.apiToken = "t0ken"
+	request := func(method, path, body string) *http.Request {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer t0ken")
+		return req
+	}
+
+	body := `{"targets": ["api"], "start": "2020-01-01T00:00:00Z", "end": "2999-01-01T00:00:00Z", "comment": "DB migration", "created_by": "alice"}`
+	for _, token := range []string{"", "Bearer wrong"} {
+		req := request(http.MethodPost, "/silences", body)
+		req.Header.Set("Authorization", token)
+		rec := httptest.NewRecorder()
+		s.handleSilences(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("authorization %q: expected 401, got %d", token, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleSilences(rec, request(http.MethodPost, "/silences", body))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var created Silence
+	json.NewDecoder(rec.Body).Decode(&created)
+	if created.ID == "" || created.CreatedBy != "alice" {
+		t.Fatalf("unexpected created silence: %+v", created)
+	}
+	if got := testutil.ToFloat64(s.metrics.silenced.With(prometheus.Labels{"url": "https://api.com"})); got != 1 {
+		t.Errorf("creating a silence should update site_silenced, got %v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleSilences(rec, httptest.NewRequest(http.MethodGet, "/silences", nil))
+	var listed []silenceStatus
+	json.NewDecoder(rec.Body).Decode(&listed)
+	if len(listed) != 2 || listed[0].Source != "config" || listed[1].ID != created.ID || !listed[1].Active {
+		t.Fatalf("unexpected silence list: %+v", listed)
+	}
+
+	// Persisted across restarts.
+	reloaded, err := newSilenceStore(s.silences.path)
+	if err != nil || len(reloaded.list(time.Now())) != 1 {
+		t.Errorf("silence should be persisted: %v", err)
+	}
+
+	for _, c := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/silences", `{"targets": ["api"]}`, http.StatusBadRequest},
+		{http.MethodPost, "/silences", `{"target": "api"}`, http.StatusBadRequest},
+		{http.MethodDelete, "/silences/maintenance-0", "", http.StatusConflict},
+		{http.MethodDelete, "/silences/unknown", "", http.StatusNotFound},
+		{http.MethodPut, "/silences", "", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/silences/" + created.ID, "", http.StatusNoContent},
+	} {
+		rec := httptest.NewRecorder()
+		s.handleSilences(rec, request(c.method, c.path, c.body))
+		if rec.Code != c.want {
+			t.Errorf("%s %s: expected %d, got %d: %s", c.method, c.path, c.want, rec.Code, rec.Body)
+		}
+	}
+	if got := testutil.ToFloat64(s.metrics.silenced.With(prometheus.Labels{"url": "https://api.com"})); got != 0 {
+		t.Errorf("deleting a silence should update site_silenced, got %v", got)
+	}
+
+	// Without a configured token, silences can only be listed.
+	s.config.acks.apiToken = ""
+	rec = httptest.NewRecorder()
+	s.handleSilences(rec, request(http.MethodPost, "/silences", body))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a configured token, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	s.handleSilences(rec, httptest.NewRequest(http.MethodGet, "/silences", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("listing silences should not need a token, got %d", rec.Code)
+	}
+}
+
+func TestLoadConfigMaintenance(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+maintenance:
+  - targets: [api]
+    cron: "0 22 * * 6"
+    duration: 2h
+    timezone: UTC
+    comment: weekly patching
+  - labels:
+      team: web
+    start: 2024-06-01T22:00:00+02:00
+    end: 2024-06-02T02:00:00+02:00
+silences:
+  path: /tmp/silences.json
+targets:
+  - name: api
+    url: https://api.example.com
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "CHECK_INTERVAL": "", "ALERT_THRESHOLD": "", "SILENCES_PATH": ""})
+	defer cleanup()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.maintenance) != 2 || cfg.maintenance[0].duration != 2*time.Hour || cfg.maintenance[1].End.Sub(cfg.maintenance[1].Start) != 4*time.Hour {
+		t.Errorf("maintenance windows not loaded: %+v", cfg.maintenance)
+	}
+	if cfg.silencesPath != "/tmp/silences.json" {
+		t.Errorf("silences path not loaded: %q", cfg.silencesPath)
+	}
+
+	path = writeConfigFile(t, "config.yaml", `
+maintenance:
+  - targets: [nope]
+    start: tomorrow
+    end: 2024-06-02T02:00:00Z
+targets:
+  - name: api
+    url: https://api.example.com
+`)
+	cleanup2 := setupEnv(map[string]string{"CONFIG_FILE": path})
+	defer cleanup2()
+	_, err = loadConfig()
+	if err == nil || !strings.Contains(err.Error(), `maintenance[0].start: invalid time "tomorrow"`) {
+		t.Errorf("expected an invalid start error, got %v", err)
+	}
+}
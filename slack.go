@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// slackConfig holds the settings of a Slack incoming-webhook notifier.
+type slackConfig struct {
+	webhookURL string
+	channel    string // Only honoured by legacy webhooks, app webhooks post to their own channel
+	username   string
+	iconEmoji  string
+	webhooks   map[string]string // Webhook URL overrides by target name
+}
+
+type fileSlack struct {
+	WebhookURL string            `yaml:"webhook_url" json:"webhook_url"`
+	Channel    string            `yaml:"channel" json:"channel"`
+	Username   string            `yaml:"username" json:"username"`
+	IconEmoji  string            `yaml:"icon_emoji" json:"icon_emoji"`
+	Webhooks   map[string]string `yaml:"webhooks" json:"webhooks"`
+}
+
+func parseSlack(f fileSlack) slackConfig {
+	return slackConfig{
+		webhookURL: f.WebhookURL,
+		channel:    f.Channel,
+		username:   f.Username,
+		iconEmoji:  f.IconEmoji,
+		webhooks:   f.Webhooks,
+	}
+}
+
+func validateSlack(cfg slackConfig, field string, targets map[string]bool, errs *configErrors) {
+	requireURL(errs, field+".webhook_url", cfg.webhookURL, "slack")
+	for name, url := range cfg.webhooks {
+		if !targets[name] {
+			errs.add(field+".webhooks."+name, "unknown target %q", name)
+		} else {
+			requireURL(errs, field+".webhooks."+name, url, "slack")
+		}
+	}
+}
+
+// webhookFor returns the webhook URL of the target named name.
+func (c slackConfig) webhookFor(name string) string {
+	if url, ok := c.webhooks[name]; ok {
+		return url
+	}
+	return c.webhookURL
+}
+
+// slackNotifier posts Block Kit messages to a Slack incoming webhook.
+type slackNotifier struct {
+	cfg    slackConfig
+	client *http.Client
+}
+
+func newSlackNotifier(nc notifierConfig) *slackNotifier {
+	return &slackNotifier{cfg: nc.slack, client: &http.Client{Timeout: nc.timeout}}
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type     string         `json:"type"`
+	Text     *slackText     `json:"text,omitempty"`
+	Fields   []slackText    `json:"fields,omitempty"`
+	Elements []slackElement `json:"elements,omitempty"`
+}
+
+type slackElement struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+	URL  string     `json:"url,omitempty"`
+}
+
+type slackMessage struct {
+	Channel   string       `json:"channel,omitempty"`
+	Username  string       `json:"username,omitempty"`
+	IconEmoji string       `json:"icon_emoji,omitempty"`
+	Text      string       `json:"text"`
+	Blocks    []slackBlock `json:"blocks"`
+}
+
+func (n *slackNotifier) Notify(ev AlertEvent) error {
+	return postJSON(n.client, n.cfg.webhookFor(ev.Name), nil, n.message(ev))
+}
+
+// NotifyDigest posts d as a single message per webhook, each with the
+// targets sent to that webhook.
+func (n *slackNotifier) NotifyDigest(d AlertDigest) error {
+	var errs []error
+	for _, part := range d.partition(func(ev AlertEvent) string { return n.cfg.webhookFor(ev.Name) }) {
+		title, body := digestMessage(part)
+		errs = append(errs, postJSON(n.client, n.cfg.webhookFor(part.Events()[0].Name), nil, slackMessage{
+			Channel:   n.cfg.channel,
+			Username:  n.cfg.username,
+			IconEmoji: n.cfg.iconEmoji,
+			Text:      title,
+			Blocks: []slackBlock{
+				{Type: "header", Text: &slackText{Type: "plain_text", Text: title}},
+				{Type: "section", Text: &slackText{Type: "mrkdwn", Text: body}},
+			},
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// message builds the Block Kit payload for ev.
+func (n *slackNotifier) message(ev AlertEvent) slackMessage {
+	msg := slackMessage{
+		Channel:   n.cfg.channel,
+		Username:  n.cfg.username,
+		IconEmoji: n.cfg.iconEmoji,
+	}
+
+	var header string
+	fields := []slackText{mrkdwn("*URL:*\n<%s>", ev.URL)}
+	switch ev.State {
+	case StateDown:
+		header = downTitle(ev)
+		msg.Text = fmt.Sprintf("[DOWN] %s (%s)", ev.URL, ev.Reason)
+		fields = append(fields,
+			mrkdwn("*Reason:*\n%s", ev.Reason),
+			mrkdwn("*Failures:*\n%d", ev.FailureCount),
+			mrkdwn("*Failing since:*\n%s", ev.Since.Format(time.RFC1123)),
+		)
+		if ev.OnCall != nil {
+			fields = append(fields, mrkdwn("*On call (level %d):*\n%s", ev.Level, slackMention(ev.OnCall)))
+		}
+	case StateFlapping:
+		header = flapTitle(ev)
+		msg.Text = fmt.Sprintf("[FLAPPING] %s", ev.URL)
+		fields = append(fields,
+			mrkdwn("*State change:*\n%.0f%%", ev.FlapRate),
+			mrkdwn("*Last failure:*\n%s", ev.Reason),
+		)
+	case StateDegraded:
+		header = degradedTitle(ev)
+		msg.Text = fmt.Sprintf("[DEGRADED] %s (%s)", ev.URL, ev.Reason)
+		fields = append(fields,
+			mrkdwn("*Latency:*\n%s", ev.Reason),
+			mrkdwn("*Slow checks:*\n%d", ev.SlowChecks),
+			mrkdwn("*Slow since:*\n%s", ev.Since.Format(time.RFC1123)),
+		)
+	default:
+		if ev.From == StateDegraded {
+			header = upTitle(ev)
+			msg.Text = fmt.Sprintf("[UP] %s is responding normally again", ev.URL)
+			fields = append(fields,
+				mrkdwn("*Degraded for:*\n%s", ev.Downtime()),
+				mrkdwn("*Last slow check:*\n%s", ev.Reason),
+			)
+			break
+		}
+		header = upTitle(ev)
+		msg.Text = fmt.Sprintf("[UP] %s is back online", ev.URL)
+		fields = append(fields,
+			mrkdwn("*Downtime:*\n%s", ev.Downtime()),
+			mrkdwn("*Failures:*\n%d", ev.FailureCount),
+			mrkdwn("*Outage reason:*\n%s", outageReason(ev)),
+		)
+		if ev.AckedBy != "" {
+			fields = append(fields, mrkdwn("*Acknowledged by:*\n%s at %s", ev.AckedBy, ev.AckedAt.Format(time.RFC1123)))
+		}
+	}
+
+	msg.Blocks = []slackBlock{
+		{Type: "header", Text: &slackText{Type: "plain_text", Text: header}},
+		{Type: "section", Fields: fields},
+	}
+	var buttons []slackElement
+	if ev.AckURL != "" {
+		buttons = append(buttons, slackElement{Type: "button", Text: &slackText{Type: "plain_text", Text: "Acknowledge"}, URL: ev.AckURL})
+	}
+	if ev.DashboardURL != "" {
+		buttons = append(buttons, slackElement{Type: "button", Text: &slackText{Type: "plain_text", Text: "Open Grafana dashboard"}, URL: ev.DashboardURL})
+	}
+	if len(buttons) > 0 {
+		msg.Blocks = append(msg.Blocks, slackBlock{Type: "actions", Elements: buttons})
+	}
+	return msg
+}
+
+// slackMention mentions the on-call person by member ID, falling back to
+// their name.
+func slackMention(p *OnCallPerson) string {
+	if p.Slack == "" {
+		return p.Name
+	}
+	return fmt.Sprintf("<@%s>", p.Slack)
+}
+
+func mrkdwn(format string, args ...any) slackText {
+	return slackText{Type: "mrkdwn", Text: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// webhookRecorder is a local stand-in for webhook endpoints.
+type webhookRecorder struct {
+	*httptest.Server
+	bodies  chan []byte
+	headers chan http.Header
+}
+
+func newWebhookRecorder(t *testing.T, status int) *webhookRecorder {
+	t.Helper()
+	r := &webhookRecorder{bodies: make(chan []byte, 10), headers: make(chan http.Header, 10)}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.bodies <- body
+		r.headers <- req.Header.Clone()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *webhookRecorder) next(t *testing.T, v any) {
+	t.Helper()
+	select {
+	case body := <-r.bodies:
+		if err := json.Unmarshal(body, v); err != nil {
+			t.Fatalf("invalid JSON payload %s: %v", body, err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no request received")
+	}
+}
+
+func testEvent(state AlertState) AlertEvent {
+	since := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	return AlertEvent{
+		Name:         "checkout",
+		URL:          "https://shop.example.com",
+		State:        state,
+		Reason:       "returned status 503",
+		FailureCount: 3,
+		Since:        since,
+		At:           since.Add(42 * time.Minute),
+		Labels:       map[string]string{"team": "payments"},
+		DashboardURL: "https://grafana.example.com/d/abc",
+	}
+}
+
+func TestSlackNotifierDown(t *testing.T) {
+	hook := newWebhookRecorder(t, http.StatusOK)
+	payments := newWebhookRecorder(t, http.StatusOK)
+	n := newSlackNotifier(notifierConfig{timeout: time.Second, slack: slackConfig{
+		webhookURL: hook.URL,
+		webhooks:   map[string]string{"checkout": payments.URL},
+	}})
+
+	if err := n.Notify(testEvent(StateDown)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var msg slackMessage
+	payments.next(t, &msg)
+	if len(hook.bodies) != 0 {
+		t.Error("expected the message to go to the per-target webhook only")
+	}
+	if len(msg.Blocks) != 3 || msg.Blocks[0].Text.Text != "🚨 DOWN: checkout" {
+		t.Fatalf("unexpected blocks: %+v", msg.Blocks)
+	}
+	fields := msg.Blocks[1].Fields
+	if !strings.Contains(fields[0].Text, "https://shop.example.com") || !strings.Contains(fields[1].Text, "returned status 503") ||
+		!strings.Contains(fields[2].Text, "3") {
+		t.Errorf("unexpected fields: %+v", fields)
+	}
+	if msg.Blocks[2].Elements[0].URL != "https://grafana.example.com/d/abc" {
+		t.Errorf("missing dashboard button: %+v", msg.Blocks[2])
+	}
+}
+
+func TestSlackNotifierUp(t *testing.T) {
+	hook := newWebhookRecorder(t, http.StatusOK)
+	n := newSlackNotifier(notifierConfig{timeout: time.Second, slack: slackConfig{webhookURL: hook.URL, channel: "#ops"}})
+
+	ev := testEvent(StateUp)
+	ev.Name = "other"
+	ev.DashboardURL = ""
+	if err := n.Notify(ev); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var msg slackMessage
+	hook.next(t, &msg)
+	if msg.Channel != "#ops" {
+		t.Errorf("expected default channel, got %q", msg.Channel)
+	}
+	if len(msg.Blocks) != 2 || msg.Blocks[0].Text.Text != "✅ UP: other" {
+		t.Fatalf("unexpected blocks: %+v", msg.Blocks)
+	}
+	if !strings.Contains(msg.Blocks[1].Fields[1].Text, "42m0s") {
+		t.Errorf("expected downtime in fields: %+v", msg.Blocks[1].Fields)
+	}
+}
+
+func TestSlackNotifierDigestWebhooks(t *testing.T) {
+	hook := newWebhookRecorder(t, http.StatusOK)
+	payments := newWebhookRecorder(t, http.StatusOK)
+	n := newSlackNotifier(notifierConfig{timeout: time.Second, slack: slackConfig{
+		webhookURL: hook.URL,
+		webhooks:   map[string]string{"checkout": payments.URL},
+	}})
+	other := testEvent(StateDown)
+	other.Name, other.URL = "blog", "https://blog.example.com"
+	if err := n.NotifyDigest(AlertDigest{Groups: []AlertGroup{{Events: []AlertEvent{testEvent(StateDown), other}}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var msg slackMessage
+	payments.next(t, &msg)
+	if msg.Text != "🚨 1 DOWN" || !strings.Contains(msg.Blocks[1].Text.Text, "checkout") || strings.Contains(msg.Blocks[1].Text.Text, "blog") {
+		t.Errorf("expected only checkout in the digest of its webhook: %+v", msg)
+	}
+	hook.next(t, &msg)
+	if !strings.Contains(msg.Blocks[1].Text.Text, "blog") || strings.Contains(msg.Blocks[1].Text.Text, "checkout") {
+		t.Errorf("expected only blog in the digest of the default webhook: %+v", msg)
+	}
+}
+
+func TestSlackNotifierErrorStatus(t *testing.T) {
+	hook := newWebhookRecorder(t, http.StatusNotFound)
+	n := newSlackNotifier(notifierConfig{timeout: time.Second, slack: slackConfig{webhookURL: hook.URL + "/services/secret"}})
+
+	err := n.Notify(testEvent(StateDown))
+	if err == nil || !strings.Contains(err.Error(), "status 404") {
+		t.Fatalf("expected status error, got %v", err)
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("webhook path should be redacted: %v", err)
+	}
+}
+
+func TestValidateSlack(t *testing.T) {
+	errs := &configErrors{}
+	validateSlack(slackConfig{webhooks: map[string]string{"nope": "https://hooks.slack.com/x", "api": "hooks.slack.com"}}, "notifiers[0].slack", map[string]bool{"api": true}, errs)
+	if len(errs.problems) != 3 {
+		t.Errorf("expected missing webhook, unknown target and invalid URL problems, got %v", errs.problems)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"time"
+)
+
+const defaultSMTPTimeout = 30 * time.Second
+
+// SMTP TLS modes.
+const (
+	smtpTLSAuto     = "auto"     // STARTTLS if the server offers it
+	smtpTLSNone     = "none"     // Never encrypt
+	smtpTLSStartTLS = "starttls" // Require STARTTLS
+	smtpTLSImplicit = "implicit" // TLS from the start, usually port 465
+)
+
+// SMTP auth mechanisms.
+const (
+	smtpAuthNone    = "none"
+	smtpAuthPlain   = "plain"
+	smtpAuthLogin   = "login"
+	smtpAuthCRAMMD5 = "cram-md5"
+)
+
+var (
+	smtpTLSModes  = map[string]bool{smtpTLSAuto: true, smtpTLSNone: true, smtpTLSStartTLS: true, smtpTLSImplicit: true}
+	smtpAuthModes = map[string]bool{smtpAuthNone: true, smtpAuthPlain: true, smtpAuthLogin: true, smtpAuthCRAMMD5: true}
+)
+
+// smtpAuthMechanism returns the configured auth mechanism, defaulting to
+// PLAIN when credentials are set and no authentication otherwise.
+func smtpAuthMechanism(cfg appConfig) string {
+	if cfg.smtpAuth != "" {
+		return cfg.smtpAuth
+	}
+	if cfg.smtpUser != "" {
+		return smtpAuthPlain
+	}
+	return smtpAuthNone
+}
+
+// smtpTLSConfig builds the TLS settings for the SMTP server, loading the CA
+// bundle if one is configured.
+func smtpTLSConfig(cfg appConfig) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		ServerName:         cfg.smtpServer,
+		InsecureSkipVerify: cfg.smtpSkipVerify,
+	}
+	if cfg.smtpCAFile != "" {
+		pem, err := os.ReadFile(cfg.smtpCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", cfg.smtpCAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	return tlsCfg, nil
+}
+
+// deliverSMTP sends msg from from to rcpts with the configured TLS mode,
+// auth mechanism and timeout. The timeout covers the whole transaction.
+func deliverSMTP(cfg appConfig, from string, rcpts []string, msg []byte) error {
+	tlsCfg, err := smtpTLSConfig(cfg)
+	if err != nil {
+		return err
+	}
+	timeout := cfg.smtpTimeout
+	if timeout <= 0 {
+		timeout = defaultSMTPTimeout
+	}
+	addr := net.JoinHostPort(cfg.smtpServer, cfg.smtpPort)
+	dialer := &net.Dialer{Timeout: timeout}
+
+	var conn net.Conn
+	if cfg.smtpTLS == smtpTLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsCfg)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, cfg.smtpServer)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	switch cfg.smtpTLS {
+	case smtpTLSStartTLS, smtpTLSAuto, "":
+		ok, _ := c.Extension("STARTTLS")
+		if !ok && cfg.smtpTLS == smtpTLSStartTLS {
+			return errors.New("server does not support STARTTLS")
+		}
+		if ok {
+			if err := c.StartTLS(tlsCfg); err != nil {
+				return fmt.Errorf("STARTTLS: %w", err)
+			}
+		}
+	}
+
+	if auth := smtpAuthFor(cfg); auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return fmt.Errorf("authenticating: %w", err)
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range rcpts {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("recipient %s: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func smtpAuthFor(cfg appConfig) smtp.Auth {
+	switch smtpAuthMechanism(cfg) {
+	case smtpAuthPlain:
+		return smtp.PlainAuth("", cfg.smtpUser, cfg.smtpPass, cfg.smtpServer)
+	case smtpAuthLogin:
+		return &loginAuth{username: cfg.smtpUser, password: cfg.smtpPass, host: cfg.smtpServer}
+	case smtpAuthCRAMMD5:
+		return smtp.CRAMMD5Auth(cfg.smtpUser, cfg.smtpPass)
+	}
+	return nil
+}
+
+// loginAuth implements the LOGIN mechanism, which net/smtp lacks. Like
+// smtp.PlainAuth it refuses to send credentials over unencrypted
+// connections to anything but localhost.
+type loginAuth struct {
+	username, password, host string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch string(fromServer) {
+	case "Username:", "username:":
+		return []byte(a.username), nil
+	case "Password:", "password:":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
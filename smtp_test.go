@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpSession is what the stand-in server saw during one connection.
+type smtpSession struct {
+	tls   bool
+	auth  string
+	user  string
+	from  string
+	rcpts []string
+	data  string
+}
+
+// smtpStandIn is a minimal in-process SMTP server.
+type smtpStandIn struct {
+	addr     string
+	port     string
+	caFile   string
+	tlsCfg   *tls.Config
+	implicit bool   // Serve TLS from the start
+	startTLS bool   // Advertise STARTTLS
+	mechs    string // Advertised AUTH mechanisms
+	user     string
+	pass     string
+
+	mu       sync.Mutex
+	sessions []smtpSession
+}
+
+func newSMTPStandIn(t *testing.T, configure func(*smtpStandIn)) *smtpStandIn {
+	t.Helper()
+	s := &smtpStandIn{user: "monitor", pass: "secret"}
+	s.tlsCfg, s.caFile = selfSignedTLS(t)
+	configure(s)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.implicit {
+		ln = tls.NewListener(ln, s.tlsCfg)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s.addr, s.port, _ = net.SplitHostPort(ln.Addr().String())
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpStandIn) config() appConfig {
+	return appConfig{smtpServer: s.addr, smtpPort: s.port, smtpFrom: "monitor@example.com", smtpTimeout: 2 * time.Second}
+}
+
+func (s *smtpStandIn) last(t *testing.T) smtpSession {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.sessions) == 0 {
+		t.Fatal("no SMTP session recorded")
+	}
+	return s.sessions[len(s.sessions)-1]
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	sess := smtpSession{tls: s.implicit}
+	tp := textproto.NewConn(conn)
+	reply := func(format string, args ...any) { tp.PrintfLine(format, args...) }
+	readB64 := func() string {
+		line, _ := tp.ReadLine()
+		b, _ := base64.StdEncoding.DecodeString(line)
+		return string(b)
+	}
+	authOK := func(mech, user string, ok bool) {
+		if !ok {
+			reply("535 authentication failed")
+			return
+		}
+		sess.auth, sess.user = mech, user
+		reply("235 authenticated")
+	}
+
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			caps := []string{"localhost"}
+			if s.startTLS && !sess.tls {
+				caps = append(caps, "STARTTLS")
+			}
+			if s.mechs != "" {
+				caps = append(caps, "AUTH "+s.mechs)
+			}
+			for i, c := range caps {
+				sep := "-"
+				if i == len(caps)-1 {
+					sep = " "
+				}
+				reply("250%s%s", sep, c)
+			}
+		case "STARTTLS":
+			reply("220 ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsCfg)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, sess.tls = tlsConn, true
+			tp = textproto.NewConn(conn)
+		case "AUTH":
+			mech, initial, _ := strings.Cut(arg, " ")
+			switch strings.ToUpper(mech) {
+			case "PLAIN":
+				b, _ := base64.StdEncoding.DecodeString(initial)
+				parts := strings.Split(string(b), "\x00")
+				authOK("plain", parts[1], len(parts) == 3 && parts[1] == s.user && parts[2] == s.pass)
+			case "LOGIN":
+				reply("334 %s", base64.StdEncoding.EncodeToString([]byte("Username:")))
+				user := readB64()
+				reply("334 %s", base64.StdEncoding.EncodeToString([]byte("Password:")))
+				pass := readB64()
+				authOK("login", user, user == s.user && pass == s.pass)
+			case "CRAM-MD5":
+				challenge := "<1234.5678@localhost>"
+				reply("334 %s", base64.StdEncoding.EncodeToString([]byte(challenge)))
+				user, digest, _ := strings.Cut(readB64(), " ")
+				mac := hmac.New(md5.New, []byte(s.pass))
+				mac.Write([]byte(challenge))
+				authOK("cram-md5", user, user == s.user && digest == hex.EncodeToString(mac.Sum(nil)))
+			default:
+				reply("504 unsupported mechanism")
+			}
+		case "MAIL":
+			sess.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			reply("250 ok")
+		case "RCPT":
+			sess.rcpts = append(sess.rcpts, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			sess.data = string(data)
+			s.mu.Lock()
+			s.sessions = append(s.sessions, sess)
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+// selfSignedTLS returns a server config with a self-signed certificate for
+// 127.0.0.1 and the path of that certificate as a CA bundle.
+func selfSignedTLS(t *testing.T) (*tls.Config, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "smtp stand-in"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, caFile
+}
+
+func TestSMTPSenderImplicitTLSWithCABundle(t *testing.T) {
+	srv := newSMTPStandIn(t, func(s *smtpStandIn) {
+		s.implicit = true
+		s.mechs = "PLAIN LOGIN"
+	})
+	cfg := srv.config()
+	cfg.smtpTLS = smtpTLSImplicit
+	cfg.smtpCAFile = srv.caFile
+	cfg.smtpUser, cfg.smtpPass = "monitor", "secret"
+
+	sender := &SMTPSender{cfg: cfg}
+	err := sender.Send(emailMessage{
+		to:      []string{"ops@example.com"},
+		cc:      []string{"lead@example.com"},
+		bcc:     []string{"archive@example.com"},
+		subject: "[🚨 DOWN] https://example.com (returned status 500)",
+		text:    "https://example.com: returned status 500",
+		html:    "<p>down</p>",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sess := srv.last(t)
+	if !sess.tls || sess.auth != "plain" || sess.user != "monitor" || sess.from != "monitor@example.com" {
+		t.Errorf("unexpected session: %+v", sess)
+	}
+	if !slices.Equal(sess.rcpts, []string{"ops@example.com", "lead@example.com", "archive@example.com"}) {
+		t.Errorf("unexpected recipients %v", sess.rcpts)
+	}
+	if strings.Contains(sess.data, "archive@example.com") || !strings.Contains(sess.data, "Cc: <lead@example.com>") {
+		t.Errorf("expected Cc header and hidden Bcc in:\n%s", sess.data)
+	}
+	if !strings.Contains(sess.data, "multipart/alternative") {
+		t.Errorf("expected multipart message, got:\n%s", sess.data)
+	}
+}
+
+func TestSMTPSenderDisplayNames(t *testing.T) {
+	srv := newSMTPStandIn(t, func(*smtpStandIn) {})
+	cfg := srv.config()
+	cfg.smtpTLS = smtpTLSNone
+	cfg.smtpFrom = "Uptime Monitor <monitor@example.com>"
+
+	sender := &SMTPSender{cfg: cfg}
+	err := sender.Send(emailMessage{
+		to:      []string{"Ops <ops@example.com>"},
+		cc:      []string{`"Lead, Payments" <lead@example.com>`},
+		bcc:     []string{"Archive <archive@example.com>"},
+		subject: "[🚨 DOWN] https://example.com (returned status 500)",
+		text:    "https://example.com: returned status 500",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sess := srv.last(t)
+	if sess.from != "monitor@example.com" {
+		t.Errorf("expected the bare sender address, got %q", sess.from)
+	}
+	if !slices.Equal(sess.rcpts, []string{"ops@example.com", "lead@example.com", "archive@example.com"}) {
+		t.Errorf("expected bare recipient addresses, got %v", sess.rcpts)
+	}
+	if !strings.Contains(sess.data, `To: "Ops" <ops@example.com>`) {
+		t.Errorf("expected the display name in the To header:\n%s", sess.data)
+	}
+}
+
+func TestDeliverSMTPTransportOptions(t *testing.T) {
+	cases := []struct {
+		name      string
+		server    func(*smtpStandIn)
+		configure func(*appConfig, *smtpStandIn)
+		wantTLS   bool
+		wantAuth  string
+	}{
+		{
+			name:   "required STARTTLS with LOGIN and skip-verify",
+			server: func(s *smtpStandIn) { s.startTLS, s.mechs = true, "LOGIN" },
+			configure: func(c *appConfig, _ *smtpStandIn) {
+				c.smtpTLS, c.smtpAuth, c.smtpSkipVerify = smtpTLSStartTLS, smtpAuthLogin, true
+				c.smtpUser, c.smtpPass = "monitor", "secret"
+			},
+			wantTLS:  true,
+			wantAuth: "login",
+		},
+		{
+			name:   "auto STARTTLS with CRAM-MD5",
+			server: func(s *smtpStandIn) { s.startTLS, s.mechs = true, "CRAM-MD5 PLAIN" },
+			configure: func(c *appConfig, s *smtpStandIn) {
+				c.smtpTLS, c.smtpAuth, c.smtpCAFile = smtpTLSAuto, smtpAuthCRAMMD5, s.caFile
+				c.smtpUser, c.smtpPass = "monitor", "secret"
+			},
+			wantTLS:  true,
+			wantAuth: "cram-md5",
+		},
+		{
+			name:   "unauthenticated relay without TLS",
+			server: func(s *smtpStandIn) { s.startTLS, s.mechs = true, "PLAIN" },
+			configure: func(c *appConfig, _ *smtpStandIn) {
+				c.smtpTLS = smtpTLSNone
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			srv := newSMTPStandIn(t, c.server)
+			cfg := srv.config()
+			c.configure(&cfg, srv)
+			if err := deliverSMTP(cfg, cfg.smtpFrom, []string{"ops@example.com"}, []byte("Subject: test\r\n\r\nbody\r\n")); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			sess := srv.last(t)
+			if sess.tls != c.wantTLS || sess.auth != c.wantAuth {
+				t.Errorf("expected tls=%v auth=%q, got %+v", c.wantTLS, c.wantAuth, sess)
+			}
+		})
+	}
+}
+
+func TestDeliverSMTPFailures(t *testing.T) {
+	msg := []byte("Subject: test\r\n\r\nbody\r\n")
+
+	noTLS := newSMTPStandIn(t, func(s *smtpStandIn) {})
+	cfg := noTLS.config()
+	cfg.smtpTLS = smtpTLSStartTLS
+	if err := deliverSMTP(cfg, cfg.smtpFrom, []string{"ops@example.com"}, msg); err == nil || !strings.Contains(err.Error(), "does not support STARTTLS") {
+		t.Errorf("expected STARTTLS policy error, got %v", err)
+	}
+
+	untrusted := newSMTPStandIn(t, func(s *smtpStandIn) { s.implicit = true })
+	cfg = untrusted.config()
+	cfg.smtpTLS = smtpTLSImplicit
+	if err := deliverSMTP(cfg, cfg.smtpFrom, []string{"ops@example.com"}, msg); err == nil {
+		t.Error("expected certificate verification error without CA bundle")
+	}
+
+	wrongPass := newSMTPStandIn(t, func(s *smtpStandIn) { s.mechs = "PLAIN" })
+	cfg = wrongPass.config()
+	cfg.smtpUser, cfg.smtpPass = "monitor", "wrong"
+	if err := deliverSMTP(cfg, cfg.smtpFrom, []string{"ops@example.com"}, msg); err == nil || !strings.Contains(err.Error(), "authenticating") {
+		t.Errorf("expected auth error, got %v", err)
+	}
+
+	// A server that accepts connections but never greets.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			bufio.NewReader(conn).ReadString('\n')
+			conn.Close()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	start := time.Now()
+	err = deliverSMTP(appConfig{smtpServer: host, smtpPort: port, smtpTLS: smtpTLSNone, smtpTimeout: 200 * time.Millisecond}, "monitor@example.com", []string{"ops@example.com"}, msg)
+	if err == nil || time.Since(start) > 2*time.Second {
+		t.Errorf("expected timeout error, got %v after %s", err, time.Since(start))
+	}
+}
+
+func TestValidateSMTPTransport(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `smtp:
+  server: smtp.example.com
+  port: "465"
+  from: monitor@example.com
+  to: ops@example.com
+  tls: ssl
+  auth: login
+  ca_file: /does/not/exist.pem
+  timeout: 0s
+targets:
+  - url: https://a.com
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "CHECK_INTERVAL": "", "ALERT_THRESHOLD": "", "SMTP_USER": "", "SMTP_PASS": ""})
+	defer cleanup()
+
+	_, err := loadConfig()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		path + `:6: smtp.tls: unknown TLS mode "ssl"`,
+		path + ":7: smtp.auth: login authentication requires an SMTP user and password",
+		path + ":8: smtp.ca_file: reading CA bundle",
+		path + ":9: smtp.timeout: must be a positive duration",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in:\n%s", want, err)
+		}
+	}
+}
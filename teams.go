@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// teamsConfig holds the settings of a Microsoft Teams notifier.
+type teamsConfig struct {
+	webhookURL string
+}
+
+type fileTeams struct {
+	WebhookURL string `yaml:"webhook_url" json:"webhook_url"`
+}
+
+// teamsNotifier posts Adaptive Cards to a Teams incoming webhook or workflow.
+type teamsNotifier struct {
+	cfg    teamsConfig
+	client *http.Client
+}
+
+func newTeamsNotifier(nc notifierConfig) *teamsNotifier {
+	return &teamsNotifier{cfg: nc.teams, client: &http.Client{Timeout: nc.timeout}}
+}
+
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string    `json:"contentType"`
+	Content     teamsCard `json:"content"`
+}
+
+type teamsCard struct {
+	Schema  string         `json:"$schema"`
+	Type    string         `json:"type"`
+	Version string         `json:"version"`
+	Body    []teamsElement `json:"body"`
+	Actions []teamsAction  `json:"actions,omitempty"`
+}
+
+type teamsElement struct {
+	Type   string         `json:"type"`
+	Style  string         `json:"style,omitempty"`
+	Items  []teamsElement `json:"items,omitempty"`
+	Text   string         `json:"text,omitempty"`
+	Weight string         `json:"weight,omitempty"`
+	Size   string         `json:"size,omitempty"`
+	Color  string         `json:"color,omitempty"`
+	Wrap   bool           `json:"wrap,omitempty"`
+	Facts  []teamsFact    `json:"facts,omitempty"`
+}
+
+type teamsFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type teamsAction struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+func (n *teamsNotifier) Notify(ev AlertEvent) error {
+	return postJSON(n.client, n.cfg.webhookURL, nil, n.message(ev))
+}
+
+// NotifyDigest posts d as a single card, colored as DOWN if it contains
+// any DOWN event.
+func (n *teamsNotifier) NotifyDigest(d AlertDigest) error {
+	title, body := digestMessage(d)
+	style, color := "attention", "Attention"
+	if d.Count(StateDown) == 0 {
+		style, color = "good", "Good"
+	}
+	card := teamsCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		Body: []teamsElement{
+			{Type: "Container", Style: style, Items: []teamsElement{
+				{Type: "TextBlock", Text: title, Weight: "Bolder", Size: "Large", Color: color, Wrap: true},
+			}},
+			// Adaptive Card markdown needs blank lines between paragraphs.
+			{Type: "TextBlock", Text: strings.ReplaceAll(body, "\n", "\n\n"), Wrap: true},
+		},
+	}
+	return postJSON(n.client, n.cfg.webhookURL, nil, teamsMessage{
+		Type:        "message",
+		Attachments: []teamsAttachment{{ContentType: "application/vnd.microsoft.card.adaptive", Content: card}},
+	})
+}
+
+// message builds an Adaptive Card colored by the state of ev.
+func (n *teamsNotifier) message(ev AlertEvent) teamsMessage {
+	title := downTitle(ev)
+	style, color := "attention", "Attention"
+	facts := []teamsFact{
+		{Title: "URL", Value: ev.URL},
+		{Title: "Reason", Value: ev.Reason},
+		{Title: "Failures", Value: fmt.Sprint(ev.FailureCount)},
+		{Title: "Failing since", Value: ev.Since.Format(time.RFC1123)},
+	}
+	if ev.OnCall != nil {
+		facts = append(facts, teamsFact{Title: fmt.Sprintf("On call (level %d)", ev.Level), Value: ev.OnCall.Name})
+	}
+	switch ev.State {
+	case StateFlapping:
+		title = flapTitle(ev)
+		style, color = "warning", "Warning"
+		facts = []teamsFact{
+			{Title: "URL", Value: ev.URL},
+			{Title: "State change", Value: fmt.Sprintf("%.0f%%", ev.FlapRate)},
+			{Title: "Last failure", Value: ev.Reason},
+		}
+	case StateDegraded:
+		title = degradedTitle(ev)
+		style, color = "warning", "Warning"
+		facts = []teamsFact{
+			{Title: "URL", Value: ev.URL},
+			{Title: "Latency", Value: ev.Reason},
+			{Title: "Slow checks", Value: fmt.Sprint(ev.SlowChecks)},
+			{Title: "Slow since", Value: ev.Since.Format(time.RFC1123)},
+		}
+	case StateUp:
+		if ev.From == StateDegraded {
+			title = upTitle(ev)
+			style, color = "good", "Good"
+			facts = []teamsFact{
+				{Title: "URL", Value: ev.URL},
+				{Title: "Degraded for", Value: ev.Downtime().String()},
+				{Title: "Last slow check", Value: ev.Reason},
+			}
+			break
+		}
+		title = upTitle(ev)
+		style, color = "good", "Good"
+		facts = []teamsFact{
+			{Title: "URL", Value: ev.URL},
+			{Title: "Outage duration", Value: ev.Downtime().String()},
+			{Title: "Outage reason", Value: outageReason(ev)},
+		}
+		if ev.AckedBy != "" {
+			facts = append(facts, teamsFact{Title: "Acknowledged by", Value: fmt.Sprintf("%s at %s", ev.AckedBy, ev.AckedAt.Format(time.RFC1123))})
+		}
+	}
+
+	card := teamsCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		Body: []teamsElement{
+			{Type: "Container", Style: style, Items: []teamsElement{
+				{Type: "TextBlock", Text: title, Weight: "Bolder", Size: "Large", Color: color, Wrap: true},
+			}},
+			{Type: "FactSet", Facts: facts},
+		},
+	}
+	if ev.AckURL != "" {
+		card.Actions = append(card.Actions, teamsAction{Type: "Action.OpenUrl", Title: "Acknowledge", URL: ev.AckURL})
+	}
+	if ev.DashboardURL != "" {
+		card.Actions = append(card.Actions, teamsAction{Type: "Action.OpenUrl", Title: "Open Grafana dashboard", URL: ev.DashboardURL})
+	}
+	return teamsMessage{
+		Type:        "message",
+		Attachments: []teamsAttachment{{ContentType: "application/vnd.microsoft.card.adaptive", Content: card}},
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTeamsNotifierDown(t *testing.T) {
+	hook := newWebhookRecorder(t, http.StatusOK)
+	n := newTeamsNotifier(notifierConfig{timeout: time.Second, teams: teamsConfig{webhookURL: hook.URL}})
+
+	if err := n.Notify(testEvent(StateDown)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var msg teamsMessage
+	hook.next(t, &msg)
+	if len(msg.Attachments) != 1 || msg.Attachments[0].ContentType != "application/vnd.microsoft.card.adaptive" {
+		t.Fatalf("unexpected attachments: %+v", msg.Attachments)
+	}
+	card := msg.Attachments[0].Content
+	header := card.Body[0]
+	if header.Style != "attention" || header.Items[0].Color != "Attention" || header.Items[0].Text != "🚨 DOWN: checkout" {
+		t.Errorf("unexpected header: %+v", header)
+	}
+	facts := card.Body[1].Facts
+	if facts[1].Title != "Reason" || facts[1].Value != "returned status 503" {
+		t.Errorf("expected failure reason fact, got %+v", facts)
+	}
+	if len(card.Actions) != 1 || card.Actions[0].URL != "https://grafana.example.com/d/abc" {
+		t.Errorf("expected dashboard action, got %+v", card.Actions)
+	}
+}
+
+func TestTeamsNotifierUp(t *testing.T) {
+	n := newTeamsNotifier(notifierConfig{})
+	card := n.message(testEvent(StateUp)).Attachments[0].Content
+	header := card.Body[0]
+	if header.Style != "good" || header.Items[0].Color != "Good" || header.Items[0].Text != "✅ UP: checkout" {
+		t.Errorf("unexpected header: %+v", header)
+	}
+	facts := card.Body[1].Facts
+	if facts[1].Title != "Outage duration" || facts[1].Value != "42m0s" {
+		t.Errorf("expected outage duration fact, got %+v", facts)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+)
+
+const defaultTelegramAPIURL = "https://api.telegram.org"
+
+// telegramConfig holds the settings of a Telegram Bot API notifier.
+type telegramConfig struct {
+	apiURL         string
+	botToken       string
+	chatID         string
+	silentRecovery bool // Deliver UP messages without sound
+}
+
+type fileTelegram struct {
+	APIURL         string `yaml:"api_url" json:"api_url"`
+	BotToken       string `yaml:"bot_token" json:"bot_token"`
+	ChatID         string `yaml:"chat_id" json:"chat_id"`
+	SilentRecovery *bool  `yaml:"silent_recovery" json:"silent_recovery"`
+}
+
+func parseTelegram(f fileTelegram) telegramConfig {
+	cfg := telegramConfig{
+		apiURL:         strings.TrimSuffix(f.APIURL, "/"),
+		botToken:       f.BotToken,
+		chatID:         f.ChatID,
+		silentRecovery: f.SilentRecovery == nil || *f.SilentRecovery,
+	}
+	if cfg.apiURL == "" {
+		cfg.apiURL = defaultTelegramAPIURL
+	}
+	return cfg
+}
+
+func validateTelegram(cfg telegramConfig, field string, errs *configErrors) {
+	requireURL(errs, field+".api_url", cfg.apiURL, "telegram")
+	if cfg.botToken == "" {
+		errs.add(field+".bot_token", "required for telegram notifiers")
+	}
+	if cfg.chatID == "" {
+		errs.add(field+".chat_id", "required for telegram notifiers")
+	}
+}
+
+// telegramNotifier sends messages through the Telegram Bot API. DOWN
+// messages notify loudly, UP messages silently unless configured otherwise.
+type telegramNotifier struct {
+	cfg    telegramConfig
+	client *http.Client
+}
+
+func newTelegramNotifier(nc notifierConfig) *telegramNotifier {
+	return &telegramNotifier{cfg: nc.telegram, client: &http.Client{Timeout: nc.timeout}}
+}
+
+type telegramMessage struct {
+	ChatID              string `json:"chat_id"`
+	Text                string `json:"text"`
+	ParseMode           string `json:"parse_mode"`
+	DisableNotification bool   `json:"disable_notification"`
+}
+
+func (n *telegramNotifier) Notify(ev AlertEvent) error {
+	url := fmt.Sprintf("%s/bot%s/sendMessage", n.cfg.apiURL, n.cfg.botToken)
+	return postJSON(n.client, url, nil, n.message(ev))
+}
+
+// NotifyDigest sends d as a single message, silently if it only reports
+// recoveries and silent_recovery is set.
+func (n *telegramNotifier) NotifyDigest(d AlertDigest) error {
+	title, body := digestMessage(d)
+	url := fmt.Sprintf("%s/bot%s/sendMessage", n.cfg.apiURL, n.cfg.botToken)
+	return postJSON(n.client, url, nil, telegramMessage{
+		ChatID:              n.cfg.chatID,
+		Text:                fmt.Sprintf("<b>%s</b>\n%s", html.EscapeString(title), html.EscapeString(body)),
+		ParseMode:           "HTML",
+		DisableNotification: d.Count(StateDown) == 0 && n.cfg.silentRecovery,
+	})
+}
+
+func (n *telegramNotifier) message(ev AlertEvent) telegramMessage {
+	title, body := plainMessage(ev)
+	text := fmt.Sprintf("<b>%s</b>\n%s", html.EscapeString(title), html.EscapeString(body))
+	if ev.DashboardURL != "" {
+		text += fmt.Sprintf("\n<a href=\"%s\">Grafana dashboard</a>", html.EscapeString(ev.DashboardURL))
+	}
+	return telegramMessage{
+		ChatID:              n.cfg.chatID,
+		Text:                text,
+		ParseMode:           "HTML",
+		DisableNotification: ev.State == StateUp && n.cfg.silentRecovery,
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTelegramNotifierDown(t *testing.T) {
+	api := newWebhookRecorder(t, http.StatusOK)
+	var paths []string
+	api.Config.Handler = recordPath(api.Config.Handler, &paths)
+	n := newTelegramNotifier(notifierConfig{timeout: time.Second, telegram: parseTelegram(fileTelegram{
+		APIURL: api.URL, BotToken: "123:abc", ChatID: "-10042",
+	})})
+
+	if err := n.Notify(testEvent(StateDown)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var msg telegramMessage
+	api.next(t, &msg)
+	if len(paths) != 1 || paths[0] != "/bot123:abc/sendMessage" {
+		t.Errorf("unexpected request paths %v", paths)
+	}
+	if msg.ChatID != "-10042" || msg.ParseMode != "HTML" || msg.DisableNotification {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+	if !strings.HasPrefix(msg.Text, "<b>🚨 DOWN: checkout</b>") || !strings.Contains(msg.Text, "returned status 503") {
+		t.Errorf("unexpected text %q", msg.Text)
+	}
+}
+
+func TestTelegramNotifierUpIsSilent(t *testing.T) {
+	n := newTelegramNotifier(notifierConfig{telegram: parseTelegram(fileTelegram{ChatID: "1"})})
+	msg := n.message(testEvent(StateUp))
+	if !msg.DisableNotification || !strings.Contains(msg.Text, "back online after 42m0s") {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+
+	loud := false
+	n = newTelegramNotifier(notifierConfig{telegram: parseTelegram(fileTelegram{ChatID: "1", SilentRecovery: &loud})})
+	if n.message(testEvent(StateUp)).DisableNotification {
+		t.Error("expected audible recovery when silent_recovery is false")
+	}
+}
+
+func TestValidateTelegram(t *testing.T) {
+	errs := &configErrors{}
+	validateTelegram(parseTelegram(fileTelegram{}), "notifiers[0].telegram", errs)
+	got := errs.Error()
+	for _, want := range []string{"notifiers[0].telegram.bot_token", "notifiers[0].telegram.chat_id"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected problem for %s, got:\n%s", want, got)
+		}
+	}
+}
+
+// recordPath wraps h to append each request path to paths.
+func recordPath(h http.Handler, paths *[]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*paths = append(*paths, r.URL.Path)
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"strings"
+	"text/template"
+)
+
+const (
+	defaultSubjectTemplate = `{{if eq .State "flapping"}}[🔀 FLAPPING] {{.URL}} ({{printf "%.0f" .FlapRate}}% state change){{else if eq .State "degraded"}}[🐢 DEGRADED{{if eq .Severity "critical"}} (critical){{end}}] {{.URL}} ({{.Reason}}){{else if eq .State "up"}}{{if eq .From "degraded"}}[✅ UP] {{.URL}} is responding normally again{{else if .FlapEnded}}[✅ STABLE] {{.URL}} stopped flapping{{else}}[✅ UP] {{.URL}} is back online{{end}}{{else if .Reminder}}[🔁 STILL DOWN] {{.URL}} for {{.Downtime}} ({{.Reason}}){{else}}[🚨 DOWN] {{.URL}} ({{.Reason}}){{end}}`
+	defaultTextTemplate    = `{{if eq .State "flapping"}}{{.URL}} is flapping between up and down ({{printf "%.0f" .FlapRate}}% state change). Notifications are paused until it is stable.{{if .Reason}}
+Last failure: {{.Reason}}{{end}}{{else if eq .State "degraded"}}{{.URL}} {{.Reason}} ({{.SlowChecks}} slow checks in a row){{else if eq .State "up"}}{{.URL}} {{if eq .From "degraded"}}is responding normally again after {{.Downtime}} degraded{{else if .FlapEnded}}stopped flapping and is up{{else}}is back online{{end}}{{if .Parent}} (was unreachable due to parent {{.Parent}}){{end}}{{if .AckedBy}}
+Acknowledged by {{.AckedBy}} at {{rfc3339 .AckedAt}}{{end}}{{else if .Reminder}}{{.URL}} is still down after {{.Downtime}}: {{.Reason}}{{else}}{{.URL}}: {{.Reason}}{{end}}{{if and .OnCall (eq .State "down")}}
+On call (level {{.Level}}): {{.OnCall.Name}}{{end}}{{if .AckURL}}
+
+Acknowledge: {{.AckURL}}{{end}}`
+)
+
+// defaultHTMLTemplate renders the HTML part of alert emails. Styles are
+// inlined because most mail clients ignore style sheets.
+const defaultHTMLTemplate = `<!DOCTYPE html>
+<html>
+<body style="margin:0;padding:24px;background:#f4f5f7;font-family:-apple-system,'Segoe UI',Helvetica,Arial,sans-serif;color:#1d1c1d;">
+<table role="presentation" width="100%" cellpadding="0" cellspacing="0" style="max-width:640px;margin:0 auto;background:#ffffff;border-radius:6px;overflow:hidden;">
+  <tr><td style="padding:16px 24px;background:{{if eq .State "up"}}#2eb67d{{else if eq .State "flapping"}}#ecb22e{{else if eq .State "degraded"}}#f2952f{{else}}#e01e5a{{end}};color:#ffffff;font-size:20px;font-weight:bold;">
+    {{if eq .State "flapping"}}🔀 FLAPPING: {{.Name}}{{else if eq .State "degraded"}}🐢 DEGRADED{{if eq .Severity "critical"}} (critical){{end}}: {{.Name}}{{else if eq .State "up"}}{{if eq .From "degraded"}}✅ UP: {{.Name}} is responding normally again{{else if .FlapEnded}}✅ STABLE: {{.Name}} stopped flapping{{else}}✅ UP: {{.Name}} is back online{{end}}{{else if .Reminder}}🔁 STILL DOWN: {{.Name}} for {{.Downtime}}{{else}}🚨 DOWN: {{.Name}}{{end}}
+  </td></tr>
+  <tr><td style="padding:16px 24px;">
+    <table role="presentation" cellpadding="4" cellspacing="0" style="font-size:14px;">
+      <tr><td style="color:#616061;">URL</td><td><a href="{{.URL}}">{{.URL}}</a></td></tr>
+      <tr><td style="color:#616061;">{{if eq .From "degraded"}}Last slow check{{else if eq .State "up"}}Outage reason{{else if eq .State "flapping"}}Last failure{{else if eq .State "degraded"}}Latency{{else}}Reason{{end}}</td><td>{{.Reason}}</td></tr>
+      {{- if .Parent}}
+      <tr><td style="color:#616061;">Unreachable due to parent</td><td>{{.Parent}}</td></tr>
+      {{- end}}
+      {{- if and .OnCall (eq .State "down")}}
+      <tr><td style="color:#616061;">On call (level {{.Level}})</td><td>{{.OnCall.Name}}</td></tr>
+      {{- end}}
+      {{- if .AckedBy}}
+      <tr><td style="color:#616061;">Acknowledged by</td><td>{{.AckedBy}} at {{rfc3339 .AckedAt}}</td></tr>
+      {{- end}}
+      {{- if not .Since.IsZero}}
+      <tr><td style="color:#616061;">{{if .From}}Degraded since{{else if eq .State "degraded"}}Slow since{{else}}Failing since{{end}}</td><td>{{rfc3339 .Since}}</td></tr>
+      {{- end}}
+      {{- if eq .State "flapping"}}
+      <tr><td style="color:#616061;">State change</td><td>{{printf "%.0f" .FlapRate}}%</td></tr>
+      {{- else if eq .State "degraded"}}
+      <tr><td style="color:#616061;">Slow checks</td><td>{{.SlowChecks}}</td></tr>
+      {{- else if eq .From "degraded"}}
+      <tr><td style="color:#616061;">Degraded for</td><td>{{.Downtime}}</td></tr>
+      {{- else}}
+      <tr><td style="color:#616061;">{{if eq .State "up"}}Downtime{{else}}Failed checks{{end}}</td><td>{{if eq .State "up"}}{{.Downtime}}{{else}}{{.FailureCount}} ({{.Downtime}}){{end}}</td></tr>
+      {{- end}}
+      {{- range $k, $v := .Labels}}
+      <tr><td style="color:#616061;">{{$k}}</td><td>{{$v}}</td></tr>
+      {{- end}}
+    </table>
+  </td></tr>
+  {{- if .History}}
+  <tr><td style="padding:0 24px 16px;">
+    <div style="font-size:14px;font-weight:bold;margin-bottom:8px;">Recent checks</div>
+    <table role="presentation" width="100%" cellpadding="6" cellspacing="0" style="font-size:13px;border-collapse:collapse;">
+      <tr style="background:#f4f5f7;text-align:left;"><th>Time (UTC)</th><th>Status</th><th>Latency</th><th>Result</th></tr>
+      {{- range .History}}
+      <tr style="border-top:1px solid #e8e8e8;">
+        <td>{{rfc3339 .At}}</td>
+        <td>{{if .StatusCode}}{{.StatusCode}}{{else}}-{{end}}</td>
+        <td>{{ms .Latency}}</td>
+        <td style="color:{{if .OK}}#2eb67d{{else}}#e01e5a{{end}};">{{if .OK}}OK{{else if .Error}}{{.Error}}{{else}}failed{{end}}</td>
+      </tr>
+      {{- end}}
+    </table>
+  </td></tr>
+  {{- end}}
+  {{- if or .AckURL .DashboardURL}}
+  <tr><td style="padding:0 24px 24px;">
+    {{- if .AckURL}}
+    <a href="{{.AckURL}}" style="display:inline-block;padding:10px 16px;margin-right:8px;background:#e01e5a;color:#ffffff;text-decoration:none;border-radius:4px;font-size:14px;">Acknowledge</a>
+    {{- end}}
+    {{- if .DashboardURL}}
+    <a href="{{.DashboardURL}}" style="display:inline-block;padding:10px 16px;background:#1d1c1d;color:#ffffff;text-decoration:none;border-radius:4px;font-size:14px;">Open Grafana dashboard</a>
+    {{- end}}
+  </td></tr>
+  {{- end}}
+</table>
+</body>
+</html>
+`
+
+var (
+	defaultSubject = template.Must(template.New("subject").Funcs(templateFuncs).Parse(defaultSubjectTemplate))
+	defaultText    = template.Must(template.New("text").Funcs(templateFuncs).Parse(defaultTextTemplate))
+	defaultHTML    = htmltemplate.Must(htmltemplate.New("html").Funcs(htmltemplate.FuncMap(templateFuncs)).Parse(defaultHTMLTemplate))
+)
+
+// alertTemplates render alert emails. Nil templates fall back to the
+// built-in subject, plain-text and HTML bodies.
+type alertTemplates struct {
+	subject *template.Template
+	text    *template.Template
+	html    *htmltemplate.Template
+}
+
+type fileTemplates struct {
+	Subject     string `yaml:"subject" json:"subject"`
+	SubjectFile string `yaml:"subject_file" json:"subject_file"`
+	Text        string `yaml:"text" json:"text"`
+	TextFile    string `yaml:"text_file" json:"text_file"`
+	HTML        string `yaml:"html" json:"html"`
+	HTMLFile    string `yaml:"html_file" json:"html_file"`
+}
+
+// parseTemplates converts the templates section of the config file.
+func parseTemplates(f fileTemplates, errs *configErrors) alertTemplates {
+	var tmpls alertTemplates
+	if text, ok := templateSource(f.Subject, f.SubjectFile, "templates.subject", errs); ok {
+		tmpl, err := template.New("subject").Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			errs.add("templates.subject", "%v", err)
+		}
+		tmpls.subject = tmpl
+	}
+	if text, ok := templateSource(f.Text, f.TextFile, "templates.text", errs); ok {
+		tmpl, err := template.New("text").Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			errs.add("templates.text", "%v", err)
+		}
+		tmpls.text = tmpl
+	}
+	if text, ok := templateSource(f.HTML, f.HTMLFile, "templates.html", errs); ok {
+		tmpl, err := htmltemplate.New("html").Funcs(htmltemplate.FuncMap(templateFuncs)).Parse(text)
+		if err != nil {
+			errs.add("templates.html", "%v", err)
+		}
+		tmpls.html = tmpl
+	}
+	return tmpls
+}
+
+// templateSource returns the inline template or the contents of file, and
+// whether either was set.
+func templateSource(inline, file, field string, errs *configErrors) (string, bool) {
+	if file == "" {
+		return inline, inline != ""
+	}
+	if inline != "" {
+		errs.add(field+"_file", "%s and %s_file are mutually exclusive", field, field)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		errs.add(field+"_file", "%v", err)
+		return "", false
+	}
+	return string(data), true
+}
+
+// render executes the templates for ev. The subject is collapsed to a
+// single line.
+func (t alertTemplates) render(ev AlertEvent) (subject, text, html string, err error) {
+	subjectTmpl, textTmpl, htmlTmpl := t.subject, t.text, t.html
+	if subjectTmpl == nil {
+		subjectTmpl = defaultSubject
+	}
+	if textTmpl == nil {
+		textTmpl = defaultText
+	}
+	if htmlTmpl == nil {
+		htmlTmpl = defaultHTML
+	}
+
+	var b strings.Builder
+	if err := subjectTmpl.Execute(&b, ev); err != nil {
+		return "", "", "", fmt.Errorf("rendering subject: %w", err)
+	}
+	subject = strings.Join(strings.Fields(b.String()), " ")
+
+	b.Reset()
+	if err := textTmpl.Execute(&b, ev); err != nil {
+		return "", "", "", fmt.Errorf("rendering text body: %w", err)
+	}
+	text = b.String()
+
+	b.Reset()
+	if err := htmlTmpl.Execute(&b, ev); err != nil {
+		return "", "", "", fmt.Errorf("rendering HTML body: %w", err)
+	}
+	return subject, text, b.String(), nil
+}
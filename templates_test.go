@@ -0,0 +1,147 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultTemplatesKeepBuiltInWording(t *testing.T) {
+	down := testEvent(StateDown)
+	subject, text, html, err := alertTemplates{}.render(down)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if subject != "[🚨 DOWN] https://shop.example.com (returned status 503)" || text != "https://shop.example.com: returned status 503" {
+		t.Errorf("unexpected DOWN email %q / %q", subject, text)
+	}
+	if !strings.Contains(html, "🚨 DOWN: checkout") || !strings.Contains(html, "Open Grafana dashboard") {
+		t.Errorf("expected default HTML part, got %q", html)
+	}
+
+	subject, text, _, _ = alertTemplates{}.render(testEvent(StateUp))
+	if subject != "[✅ UP] https://shop.example.com is back online" || text != "https://shop.example.com is back online" {
+		t.Errorf("unexpected UP email %q / %q", subject, text)
+	}
+}
+
+func TestDefaultHTMLShowsCheckHistory(t *testing.T) {
+	ev := testEvent(StateDown)
+	ev.History = []CheckResult{
+		{At: ev.At, Latency: 1500 * time.Millisecond, Error: "unreachable: connection refused"},
+		{At: ev.At.Add(-time.Minute), Latency: 230 * time.Millisecond, StatusCode: 503},
+		{At: ev.At.Add(-2 * time.Minute), Latency: 85 * time.Millisecond, StatusCode: 200, OK: true},
+	}
+	_, _, html, err := alertTemplates{}.render(ev)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"Recent checks", "1500 ms", "connection refused", "<td>503</td>", "230 ms", "85 ms", ">OK</td>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected %q in HTML body:\n%s", want, html)
+		}
+	}
+	if strings.Index(html, "1500 ms") > strings.Index(html, "85 ms") {
+		t.Error("expected newest check first")
+	}
+}
+
+func TestCustomTemplates(t *testing.T) {
+	errs := &configErrors{}
+	tmpls := parseTemplates(fileTemplates{
+		Subject: "{{upper .State}}: {{.Name}}\n({{.StatusCode}})",
+		Text: "{{.URL}} failed {{.FailureCount}} times since {{rfc3339 .Since}}: {{.Reason}}\n" +
+			"Down for {{.Downtime}}. Runbook: https://runbooks.example.com/{{.Labels.team}}",
+		HTML: `<p>{{.Name}} <a href="{{.DashboardURL}}">dashboard</a> {{.Reason}}</p>`,
+	}, errs)
+	if err := errs.err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ev := testEvent(StateDown)
+	ev.StatusCode = 503
+	ev.Reason = "returned <b>503</b>"
+	subject, text, html, err := tmpls.render(ev)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if subject != "DOWN: checkout (503)" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+	wantText := "https://shop.example.com failed 3 times since 2024-06-01T12:00:00Z: returned <b>503</b>\n" +
+		"Down for 42m0s. Runbook: https://runbooks.example.com/payments"
+	if text != wantText {
+		t.Errorf("unexpected text:\n%s", text)
+	}
+	if !strings.Contains(html, `href="https://grafana.example.com/d/abc"`) || !strings.Contains(html, "returned &lt;b&gt;503&lt;/b&gt;") {
+		t.Errorf("expected escaped HTML body, got %q", html)
+	}
+}
+
+func TestTemplateFiles(t *testing.T) {
+	path := writeConfigFile(t, "down.html", "<h1>{{.Name}}</h1>")
+	errs := &configErrors{}
+	tmpls := parseTemplates(fileTemplates{HTMLFile: path}, errs)
+	if err := errs.err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, html, _ := tmpls.render(testEvent(StateDown)); html != "<h1>checkout</h1>" {
+		t.Errorf("unexpected HTML body %q", html)
+	}
+
+	errs = &configErrors{}
+	parseTemplates(fileTemplates{
+		Subject:  "{{.Name",
+		Text:     "inline",
+		TextFile: "/does/not/exist.txt",
+	}, errs)
+	got := errs.Error()
+	for _, want := range []string{"templates.subject", "templates.text and templates.text_file are mutually exclusive", "exist.txt"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestEmailNotifierUsesTemplatesAndStatusCode(t *testing.T) {
+	errs := &configErrors{}
+	s := newTestService()
+	mock := &mockSender{}
+	s.emailSender = mock
+	s.config.smtpTo = []string{"ops@example.com"}
+	s.config.alertThreshold = 1
+	s.config.templates = parseTemplates(fileTemplates{
+		Subject: "{{.Name}} returned {{.StatusCode}}",
+		HTML:    "<p>{{.FailureCount}}</p>",
+	}, errs)
+
+	client := &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})}
+	s.checkSiteStatus(target{name: "api", url: "https://api.example.com"}, client)
+
+	if mock.lastSubject != "api returned 502" || mock.lastHTML != "<p>1</p>" {
+		t.Errorf("unexpected email %q / %q", mock.lastSubject, mock.lastHTML)
+	}
+	if mock.lastBody != "https://api.example.com: returned status 502" {
+		t.Errorf("expected default text body, got %q", mock.lastBody)
+	}
+}
+
+func TestLoadConfigTemplates(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+targets:
+  - url: https://a.com
+templates:
+  subject: "{{.Name}} is {{.State"
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "CHECK_INTERVAL": "", "ALERT_THRESHOLD": ""})
+	defer cleanup()
+
+	_, err := loadConfig()
+	if err == nil || !strings.Contains(err.Error(), "config.yaml:5: templates.subject") {
+		t.Errorf("expected template error with line reference, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Phases of a check exported by the timing metrics, in request order.
+var timingPhases = []string{"dns", "connect", "tls", "ttfb", "total"}
+
+// timingsConfig controls the response time histograms.
+type timingsConfig struct {
+	buckets []time.Duration // Upper bounds of the histogram buckets, nil for the Prometheus defaults
+}
+
+type fileTimings struct {
+	Buckets []string `yaml:"buckets" json:"buckets"`
+}
+
+func (c *timingsConfig) applyFile(f fileTimings, errs *configErrors) {
+	if len(f.Buckets) > 0 {
+		c.buckets = parseBuckets(f.Buckets, "timings.buckets", errs)
+	}
+}
+
+// parseBuckets converts bucket durations, which must be positive and in
+// ascending order.
+func parseBuckets(values []string, field string, errs *configErrors) []time.Duration {
+	buckets := make([]time.Duration, 0, len(values))
+	for i, v := range values {
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			errs.add(fmt.Sprintf("%s[%d]", field, i), "invalid duration %q", v)
+			return nil
+		}
+		if d <= 0 || (len(buckets) > 0 && d <= buckets[len(buckets)-1]) {
+			errs.add(field, "buckets must be positive and in ascending order, got %s", strings.Join(values, ", "))
+			return nil
+		}
+		buckets = append(buckets, d)
+	}
+	return buckets
+}
+
+// seconds returns the buckets as the upper bounds of a Prometheus histogram.
+func (c timingsConfig) seconds() []float64 {
+	if len(c.buckets) == 0 {
+		return prometheus.DefBuckets
+	}
+	out := make([]float64, len(c.buckets))
+	for i, b := range c.buckets {
+		out[i] = b.Seconds()
+	}
+	return out
+}
+
+// initTimingMetrics registers the response time metrics. The buckets come
+// from the configuration, so it has to be loaded first.
+func (s *Service) initTimingMetrics() {
+	s.metrics.phaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "site_http_duration_seconds",
+		Help:    "Duration of the phases of requests that got a response: dns, connect, tls, ttfb (time to first byte) and total",
+		Buckets: s.config.timings.seconds(),
+	}, []string{"url", "phase"})
+	if err := prometheus.Register(s.metrics.phaseDuration); err != nil && err.Error() != "duplicate metrics collector for site_http_duration_seconds registration attempted" {
+		log.Fatal(err)
+	}
+
+	s.metrics.phaseLast = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "site_http_last_duration_seconds",
+		Help: "Duration of the phases of the latest request that got a response, 0 for phases it skipped",
+	}, []string{"url", "phase"})
+	if err := prometheus.Register(s.metrics.phaseLast); err != nil && err.Error() != "duplicate metrics collector for site_http_last_duration_seconds registration attempted" {
+		log.Fatal(err)
+	}
+}
+
+// phaseTimer records the phases of a request through an httptrace.ClientTrace.
+type phaseTimer struct {
+	mu                        sync.Mutex // Dials to several addresses may run in parallel
+	start                     time.Time
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	firstByte                 time.Time
+}
+
+// trace returns req with a client trace recording into p, starting the
+// clock.
+func (p *phaseTimer) trace(req *http.Request) *http.Request {
+	at := func(dst *time.Time) {
+		p.mu.Lock()
+		*dst = time.Now()
+		p.mu.Unlock()
+	}
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { at(&p.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { at(&p.dnsDone) },
+		ConnectStart: func(string, string) {
+			p.mu.Lock()
+			if p.connectStart.IsZero() {
+				p.connectStart = time.Now()
+			}
+			p.mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				at(&p.connectDone)
+			}
+		},
+		TLSHandshakeStart:    func() { at(&p.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { at(&p.tlsDone) },
+		GotFirstResponseByte: func() { at(&p.firstByte) },
+	}
+	p.start = time.Now()
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// phases returns the duration of each phase the request went through. A
+// reused connection skips dns, connect and tls.
+func (p *phaseTimer) phases(total time.Duration) map[string]time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := map[string]time.Duration{"total": total}
+	span := func(phase string, from, to time.Time) {
+		if !from.IsZero() && !to.IsZero() {
+			out[phase] = to.Sub(from)
+		}
+	}
+	span("dns", p.dnsStart, p.dnsDone)
+	span("connect", p.connectStart, p.connectDone)
+	span("tls", p.tlsStart, p.tlsDone)
+	span("ttfb", p.start, p.firstByte)
+	return out
+}
+
+// observeTimings exports the phase durations of a check of url.
+func (s *Service) observeTimings(url string, phases map[string]time.Duration) {
+	for _, phase := range timingPhases {
+		labels := prometheus.Labels{"url": url, "phase": phase}
+		d, ok := phases[phase]
+		if ok {
+			s.metrics.phaseDuration.With(labels).Observe(d.Seconds())
+		}
+		s.metrics.phaseLast.With(labels).Set(d.Seconds())
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseBuckets(t *testing.T) {
+	errs := &configErrors{}
+	got := parseBuckets([]string{"50ms", " 250ms", "1s", "2.5s"}, "timings.buckets", errs)
+	if err := errs.err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := timingsConfig{buckets: got}.seconds()
+	if !slices.Equal(want, []float64{0.05, 0.25, 1, 2.5}) {
+		t.Errorf("got %v", want)
+	}
+	if !slices.Equal((timingsConfig{}).seconds(), prometheus.DefBuckets) {
+		t.Error("expected the Prometheus default buckets without configured ones")
+	}
+
+	for values, want := range map[string]string{
+		"1s,500ms":  "buckets must be positive and in ascending order, got 1s, 500ms",
+		"0s,1s":     "buckets must be positive and in ascending order",
+		"100ms,0.5": `TIMING_BUCKETS[1]: invalid duration "0.5"`,
+	} {
+		errs := &configErrors{}
+		parseBuckets(strings.Split(values, ","), "TIMING_BUCKETS", errs)
+		if err := errs.err(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected %q, got %v", values, want, err)
+		}
+	}
+}
+
+func TestCheckSiteStatusRecordsTimings(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	s := newTestService()
+	client := srv.Client()
+	client.Timeout = 5 * time.Second
+
+	s.checkSiteStatus(target{url: srv.URL}, client)
+
+	last := func(phase string) float64 {
+		return testutil.ToFloat64(s.metrics.phaseLast.With(prometheus.Labels{"url": srv.URL, "phase": phase}))
+	}
+	for _, phase := range []string{"connect", "tls", "ttfb", "total"} {
+		if last(phase) <= 0 {
+			t.Errorf("expected a %s duration, got %v", phase, last(phase))
+		}
+	}
+	if last("ttfb") < 0.005 || last("total") < last("ttfb") {
+		t.Errorf("expected ttfb >= 5ms and total >= ttfb, got %v and %v", last("ttfb"), last("total"))
+	}
+	if last("dns") != 0 {
+		t.Errorf("no DNS lookup for an IP address, got %v", last("dns"))
+	}
+	// dns was skipped, so only 4 phases were observed.
+	if n := testutil.CollectAndCount(s.metrics.phaseDuration); n != 4 {
+		t.Errorf("expected 4 histogram series, got %d", n)
+	}
+	if ev := s.newAlertEvent(target{url: srv.URL}, StateUp, ""); ev.History[0].Latency.Seconds() != last("total") {
+		t.Errorf("the check latency should be the total duration, got %v", ev.History[0].Latency)
+	}
+
+	s.forgetTarget(srv.URL)
+	if n := testutil.CollectAndCount(s.metrics.phaseDuration); n != 0 {
+		t.Errorf("expected the series of a removed target to be deleted, got %d", n)
+	}
+}
+
+func TestCheckSiteStatusBody(t *testing.T) {
+	s := newTestService()
+	s.config.alertThreshold = 1
+	tgt := target{name: "api", url: "https://api.example.com"}
+	respond := func(body io.Reader) *http.Client {
+		return &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(body)}, nil
+		})}
+	}
+
+	// An endless body is only read up to maxBodySize.
+	s.checkSiteStatus(tgt, respond(rand.Reader))
+	if s.offlineMap[tgt.url] {
+		t.Fatalf("a large body should not fail the check: %s", s.lastReason[tgt.url])
+	}
+
+	s.checkSiteStatus(tgt, respond(iotest.ErrReader(errors.New("connection reset by peer"))))
+	if !s.offlineMap[tgt.url] || s.lastReason[tgt.url] != "failed reading the response body: connection reset by peer" {
+		t.Errorf("expected a failed check for the body read error, got %q", s.lastReason[tgt.url])
+	}
+	if h := s.newAlertEvent(tgt, StateDown, "").History; len(h) != 2 || h[0].OK || h[0].StatusCode != 200 {
+		t.Errorf("expected the failed check in the history, got %+v", h)
+	}
+}
+
+func TestLoadConfigTimingBuckets(t *testing.T) {
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": "", "CHECK_INTERVAL": "", "ALERT_THRESHOLD": "",
+		"URLS": "https://example.com", "TIMING_BUCKETS": "100ms, 500ms, 2s"})
+	defer cleanup()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []time.Duration{100 * time.Millisecond, 500 * time.Millisecond, 2 * time.Second}; !slices.Equal(cfg.timings.buckets, want) {
+		t.Errorf("got %v, want %v", cfg.timings.buckets, want)
+	}
+
+	path := writeConfigFile(t, "config.yaml", `
+timings:
+  buckets: [50ms, 1s]
+targets:
+  - url: https://example.com
+`)
+	cleanup = setupEnv(map[string]string{"CONFIG_FILE": path})
+	defer cleanup()
+	cfg, err = loadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []time.Duration{50 * time.Millisecond, time.Second}; !slices.Equal(cfg.timings.buckets, want) {
+		t.Errorf("the file should override TIMING_BUCKETS, got %v", cfg.timings.buckets)
+	}
+}
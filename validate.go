@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configProblem is a single validation error, pointing at the offending
+// env variable or config file field.
+type configProblem struct {
+	source string // Config file path or "env"
+	line   int    // Line in the config file, 0 if unknown
+	field  string
+	msg    string
+}
+
+func (p configProblem) String() string {
+	loc := p.source
+	if p.line > 0 {
+		loc = fmt.Sprintf("%s:%d", loc, p.line)
+	}
+	if p.field != "" {
+		loc = fmt.Sprintf("%s: %s", loc, p.field)
+	}
+	return fmt.Sprintf("%s: %s", loc, p.msg)
+}
+
+// configErrors collects all problems found while loading the configuration
+// so they can be reported at once.
+type configErrors struct {
+	problems []configProblem
+	source   string         // Config file being read, empty while reading env
+	lines    map[string]int // Line numbers of config file fields by path
+}
+
+func (e *configErrors) Error() string {
+	msgs := make([]string, len(e.problems))
+	for i, p := range e.problems {
+		msgs[i] = "  - " + p.String()
+	}
+	return fmt.Sprintf("%d configuration problem(s):\n%s", len(e.problems), strings.Join(msgs, "\n"))
+}
+
+func (e *configErrors) err() error {
+	if len(e.problems) == 0 {
+		return nil
+	}
+	return e
+}
+
+// add records a problem for a config file field, or for an env variable if
+// no config file is being read.
+func (e *configErrors) add(field, format string, args ...any) {
+	if e.source == "" {
+		e.addEnv(field, format, args...)
+		return
+	}
+	e.addLine(e.lineOf(field), field, format, args...)
+}
+
+func (e *configErrors) addLine(line int, field, format string, args ...any) {
+	e.problems = append(e.problems, configProblem{source: e.source, line: line, field: field, msg: fmt.Sprintf(format, args...)})
+}
+
+func (e *configErrors) addEnv(name, format string, args ...any) {
+	e.problems = append(e.problems, configProblem{source: "env", field: name, msg: fmt.Sprintf(format, args...)})
+}
+
+var yamlLinePrefix = regexp.MustCompile(`^line (\d+): `)
+
+// addYAML records a yaml.TypeError message, keeping its line number.
+func (e *configErrors) addYAML(msg string) {
+	line := 0
+	if m := yamlLinePrefix.FindStringSubmatch(msg); m != nil {
+		line, _ = strconv.Atoi(m[1])
+		msg = msg[len(m[0]):]
+	}
+	e.addLine(line, "", "%s", msg)
+}
+
+// duration parses value into dst, recording a problem if it is invalid.
+func (e *configErrors) duration(field, value string, dst *time.Duration) {
+	dur, err := time.ParseDuration(value)
+	if err != nil {
+		e.add(field, "invalid duration %q", value)
+		return
+	}
+	*dst = dur
+}
+
+// lineOf returns the line of field, or of its closest parent found in the file.
+func (e *configErrors) lineOf(field string) int {
+	for field != "" {
+		if line, ok := e.lines[field]; ok {
+			return line
+		}
+		i := strings.LastIndexAny(field, ".[")
+		if i < 0 {
+			break
+		}
+		field = field[:i]
+	}
+	return 0
+}
+
+// fieldLines maps field paths such as "targets[1].timeout" to their line in
+// data. Unparseable documents yield an empty map.
+func fieldLines(data []byte) map[string]int {
+	lines := make(map[string]int)
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil || len(root.Content) == 0 {
+		return lines
+	}
+	var walk func(n *yaml.Node, path string)
+	walk = func(n *yaml.Node, path string) {
+		if path != "" {
+			lines[path] = n.Line
+		}
+		switch n.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key := n.Content[i].Value
+				if path != "" {
+					key = path + "." + key
+				}
+				walk(n.Content[i+1], key)
+				lines[key] = n.Content[i].Line
+			}
+		case yaml.SequenceNode:
+			for i, c := range n.Content {
+				walk(c, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+	walk(root.Content[0], "")
+	return lines
+}
+
+// lineAt converts a byte offset in data to a 1-based line number.
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+var validMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "OPTIONS": true,
+}
+
+// validateConfig checks the assembled configuration. targetsFromFile tells
+// whether the targets were defined in the config file or in URLS.
+func validateConfig(cfg appConfig, targetsFromFile bool, errs *configErrors) {
+	envOrFile := func(envName, fileField string) string {
+		if errs.source != "" && errs.lines[fileField] > 0 {
+			return fileField
+		}
+		return envName
+	}
+	field := func(envName, fileField string, format string, args ...any) {
+		if f := envOrFile(envName, fileField); f == envName {
+			errs.addEnv(f, format, args...)
+		} else {
+			errs.add(f, format, args...)
+		}
+	}
+
+	if cfg.checkInterval <= 0 {
+		field("CHECK_INTERVAL", "defaults.interval", "must be a positive duration")
+	}
+	if cfg.timeout <= 0 {
+		errs.add("defaults.timeout", "must be a positive duration")
+	}
+	if cfg.alertThreshold < 1 {
+		field("ALERT_THRESHOLD", "defaults.alert_threshold", "must be at least 1, got %d", cfg.alertThreshold)
+	}
+	if cfg.alertWindow < 0 {
+		field("ALERT_WINDOW", "defaults.alert_window", "must not be negative, got %d", cfg.alertWindow)
+	}
+	if cfg.recoveryThreshold < 1 {
+		field("RECOVERY_THRESHOLD", "defaults.recovery_threshold", "must be at least 1, got %d", cfg.recoveryThreshold)
+	}
+	if cfg.degradedThreshold < 1 {
+		errs.add("defaults.degraded_threshold", "must be at least 1, got %d", cfg.degradedThreshold)
+	}
+
+	if cfg.historySize < 1 {
+		errs.add("defaults.history", "must be at least 1, got %d", cfg.historySize)
+	}
+
+	if len(cfg.targets) == 0 {
+		field("URLS", "targets", "no targets configured")
+	}
+	seenURLs := make(map[string]string)
+	seenNames := make(map[string]string)
+	allHaveRecipients := len(cfg.targets) > 0
+	names := make(map[string]bool)
+	targetField, add := "URLS[%d]", errs.addEnv
+	if targetsFromFile {
+		targetField, add = "targets[%d]", errs.add
+	}
+	for i, t := range cfg.targets {
+		f := fmt.Sprintf(targetField, i)
+		validateTarget(t, f, add)
+		if prev, ok := seenURLs[t.url]; ok && t.url != "" {
+			add(f+".url", "duplicate target %s, already defined by %s", t.url, prev)
+		}
+		seenURLs[t.url] = f
+		if prev, ok := seenNames[t.name]; ok && t.name != t.url {
+			add(f+".name", "duplicate target name %q, already used by %s", t.name, prev)
+		}
+		seenNames[t.name] = f
+		if len(resolveRecipients(appConfig{emailRoutes: cfg.emailRoutes}, t).to) == 0 {
+			allHaveRecipients = false
+		}
+		names[t.name] = true
+	}
+	validateRoutes(cfg, names, errs)
+	validateDependencies(cfg.targets, errs)
+	validateMaintenance(cfg, errs)
+
+	validateNotifiers(cfg, errs)
+	validateOutbox(cfg.outbox, errs)
+	validateReminders(cfg, errs)
+	validateGrouping(cfg.grouping, errs)
+	validateAcks(cfg.acks, errs)
+	validateOnCall(cfg, errs)
+	validatePolicies(cfg, errs)
+	validateFlapping(cfg.flapping, errs)
+
+	// SMTP is optional, but once any part of it is configured it must be complete.
+	smtpUsed := cfg.smtpServer != "" || cfg.smtpPort != "" || cfg.smtpUser != "" || cfg.smtpPass != "" ||
+		len(cfg.smtpTo) > 0 || len(cfg.smtpCC) > 0 || len(cfg.smtpBCC) > 0 || cfg.smtpFrom != ""
+	if !smtpUsed {
+		if len(cfg.notifiers) == 0 {
+			field("SMTP_SERVER", "smtp.server", "no alert channel configured (set up SMTP or at least one notifier)")
+		}
+		return
+	}
+	if cfg.smtpServer == "" {
+		field("SMTP_SERVER", "smtp.server", "required when SMTP is configured")
+	}
+	if port, err := strconv.Atoi(cfg.smtpPort); err != nil || port < 1 || port > 65535 {
+		field("SMTP_PORT", "smtp.port", "must be a port number between 1 and 65535, got %q", cfg.smtpPort)
+	}
+	if (cfg.smtpUser == "") != (cfg.smtpPass == "") {
+		field("SMTP_USER", "smtp.user", "SMTP user and password must be set together")
+	}
+	if !smtpTLSModes[cfg.smtpTLS] {
+		field("SMTP_TLS", "smtp.tls", "unknown TLS mode %q (use auto, none, starttls or implicit)", cfg.smtpTLS)
+	}
+	if mech := smtpAuthMechanism(cfg); !smtpAuthModes[mech] {
+		field("SMTP_AUTH", "smtp.auth", "unknown auth mechanism %q (use none, plain, login or cram-md5)", mech)
+	} else if mech != smtpAuthNone && cfg.smtpUser == "" {
+		field("SMTP_AUTH", "smtp.auth", "%s authentication requires an SMTP user and password", mech)
+	}
+	if cfg.smtpCAFile != "" {
+		if _, err := smtpTLSConfig(cfg); err != nil {
+			field("SMTP_CA_FILE", "smtp.ca_file", "%v", err)
+		}
+	}
+	if cfg.smtpTimeout <= 0 {
+		field("SMTP_TIMEOUT", "smtp.timeout", "must be a positive duration")
+	}
+	if cfg.smtpFrom == "" {
+		field("SMTP_FROM", "smtp.from", "required when SMTP is configured")
+	} else if _, err := mail.ParseAddress(cfg.smtpFrom); err != nil {
+		field("SMTP_FROM", "smtp.from", "invalid email address %q", cfg.smtpFrom)
+	}
+	if len(cfg.smtpTo) == 0 && !allHaveRecipients {
+		field("SMTP_TO", "smtp.to", "required unless every target sets recipients or matches a route with to addresses")
+	}
+	for _, l := range []struct {
+		env, field string
+		addrs      []string
+	}{{"SMTP_TO", "smtp.to", cfg.smtpTo}, {"SMTP_CC", "smtp.cc", cfg.smtpCC}, {"SMTP_BCC", "smtp.bcc", cfg.smtpBCC}} {
+		for _, a := range l.addrs {
+			if _, err := mail.ParseAddress(a); err != nil {
+				field(l.env, l.field, "invalid email address %q", a)
+			}
+		}
+	}
+}
+
+func validateTarget(t target, field string, add func(field, format string, args ...any)) {
+	if t.url == "" {
+		add(field+".url", "URL is empty")
+	} else if u, err := url.Parse(t.url); err != nil {
+		add(field+".url", "invalid URL %q: %v", t.url, err)
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		add(field+".url", "unsupported scheme in %q (use http or https)", t.url)
+	} else if u.Host == "" {
+		add(field+".url", "missing host in %q", t.url)
+	}
+	if !validMethods[t.method] {
+		add(field+".method", "unsupported HTTP method %q", t.method)
+	}
+	if t.timeout <= 0 {
+		add(field+".timeout", "must be a positive duration")
+	}
+	if t.interval <= 0 {
+		add(field+".interval", "must be a positive duration")
+	}
+	if t.alertThreshold < 1 {
+		add(field+".alert_threshold", "must be at least 1, got %d", t.alertThreshold)
+	}
+	if t.alertWindow != 0 && t.alertWindow < t.alertThreshold {
+		add(field+".alert_window", "must be at least the alert threshold %d, got %d", t.alertThreshold, t.alertWindow)
+	}
+	if t.recoveryThreshold < 1 {
+		add(field+".recovery_threshold", "must be at least 1, got %d", t.recoveryThreshold)
+	}
+	if t.latencyWarning < 0 {
+		add(field+".latency_warning", "must not be negative")
+	}
+	if t.latencyCritical < 0 {
+		add(field+".latency_critical", "must not be negative")
+	} else if t.latencyCritical > 0 && t.latencyCritical <= t.latencyWarning {
+		add(field+".latency_critical", "must be above the warning latency %v, got %v", t.latencyWarning, t.latencyCritical)
+	}
+	if t.degradedThreshold < 1 {
+		add(field+".degraded_threshold", "must be at least 1, got %d", t.degradedThreshold)
+	}
+	if t.dashboardURL != "" {
+		if u, err := url.Parse(t.dashboardURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			add(field+".dashboard_url", "invalid dashboard URL %q", t.dashboardURL)
+		}
+	}
+	for j, r := range t.recipients {
+		if _, err := mail.ParseAddress(r); err != nil {
+			add(fmt.Sprintf("%s.recipients[%d]", field, j), "invalid email address %q", r)
+		}
+	}
+}
+
+// runValidate implements the validate subcommand and returns the exit code.
+func runValidate(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(out)
+	flags.Usage = func() {
+		fmt.Fprintln(out, "usage: go-grafana validate [config-file]")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+	if flags.NArg() == 1 {
+		os.Setenv("CONFIG_FILE", flags.Arg(0))
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 1
+	}
+	source := "environment"
+	if cfg.configFile != "" {
+		source = cfg.configFile
+	}
+	fmt.Fprintf(out, "Configuration OK (%s): %d targets\n", source, len(cfg.targets))
+	return 0
+}
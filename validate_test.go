@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigAggregatesProblems(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `defaults:
+  interval: 30s
+smtp:
+  server: smtp.example.com
+  port: "99999"
+  from: monitor@example.com
+targets:
+  - name: api
+    url: ftp://api.example.com
+    timeout: soon
+  - name: api
+    url: https://www.example.com
+    method: FETCH
+    alert_threshold: -1
+  - url: https://www.example.com
+    recipients: [not-an-address]
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "SMTP_TO": "", "ALERT_THRESHOLD": "", "CHECK_INTERVAL": ""})
+	defer cleanup()
+
+	_, err := loadConfig()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		path + ":10: targets[0].timeout: invalid duration \"soon\"",
+		path + ":9: targets[0].url: unsupported scheme",
+		path + ":11: targets[1].name: duplicate target name \"api\"",
+		path + ":13: targets[1].method: unsupported HTTP method \"FETCH\"",
+		path + ":14: targets[1].alert_threshold: must be at least 1",
+		path + ":15: targets[2].url: duplicate target https://www.example.com, already defined by targets[1]",
+		path + ":16: targets[2].recipients[0]: invalid email address",
+		path + ":5: smtp.port: must be a port number",
+		"env: SMTP_TO: required unless every target sets recipients",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("expected %q in:\n%s", want, msg)
+		}
+	}
+	if !strings.HasPrefix(msg, "9 configuration problem(s)") {
+		t.Errorf("unexpected problem count:\n%s", msg)
+	}
+}
+
+func TestLoadConfigRejectsZeroAlertThreshold(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `defaults:
+  alert_threshold: 0
+targets:
+  - url: https://api.example.com
+    alert_threshold: 0
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "ALERT_THRESHOLD": "", "CHECK_INTERVAL": ""})
+	defer cleanup()
+
+	_, err := loadConfig()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		path + ":2: defaults.alert_threshold: must be at least 1, got 0",
+		path + ":5: targets[0].alert_threshold: must be at least 1, got 0",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadConfigRejectsEmptyURLS(t *testing.T) {
+	cleanup := setupEnv(map[string]string{"URLS": "", "CONFIG_FILE": "", "SMTP_SERVER": "", "SMTP_PORT": "", "SMTP_FROM": "", "SMTP_TO": ""})
+	defer cleanup()
+
+	_, err := loadConfig()
+	if err == nil || !strings.Contains(err.Error(), "env: URLS: no targets configured") {
+		t.Errorf("expected no targets error, got %v", err)
+	}
+}
+
+func TestLoadConfigRequiresAlertChannel(t *testing.T) {
+	cleanup := setupEnv(map[string]string{
+		"URLS": "https://a.com", "CONFIG_FILE": "",
+		"SMTP_SERVER": "", "SMTP_PORT": "", "SMTP_USER": "", "SMTP_PASS": "",
+		"SMTP_FROM": "", "SMTP_TO": "", "SMTP_CC": "", "SMTP_BCC": "",
+	})
+	defer cleanup()
+
+	_, err := loadConfig()
+	if err == nil || !strings.Contains(err.Error(), "env: SMTP_SERVER: no alert channel configured") {
+		t.Errorf("expected a missing alert channel to be reported, got %v", err)
+	}
+}
+
+func TestLoadConfigReportsUnknownFields(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `targets:
+  - url: https://a.com
+    intervall: 10s
+`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path})
+	defer cleanup()
+
+	_, err := loadConfig()
+	if err == nil || !strings.Contains(err.Error(), path+":3: field intervall not found") {
+		t.Errorf("expected unknown field error with line, got %v", err)
+	}
+}
+
+func TestLoadConfigJSONLineNumbers(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{
+  "targets": [
+    {"url": "https://a.com"},
+    {"url": "mailto:someone"}
+  ]
+}`)
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": path, "ALERT_THRESHOLD": "", "CHECK_INTERVAL": ""})
+	defer cleanup()
+
+	_, err := loadConfig()
+	if err == nil || !strings.Contains(err.Error(), path+":4: targets[1].url: unsupported scheme") {
+		t.Errorf("expected JSON error with line, got %v", err)
+	}
+}
+
+func TestRunValidate(t *testing.T) {
+	cleanup := setupEnv(map[string]string{"CONFIG_FILE": "", "ALERT_THRESHOLD": "", "CHECK_INTERVAL": ""})
+	defer cleanup()
+
+	good := writeConfigFile(t, "good.yaml", "targets:\n  - url: https://a.com\n")
+	var out bytes.Buffer
+	if code := runValidate([]string{good}, &out); code != 0 {
+		t.Errorf("expected exit code 0, got %d: %s", code, out.String())
+	}
+	if !strings.Contains(out.String(), "Configuration OK") {
+		t.Errorf("unexpected output: %s", out.String())
+	}
+
+	bad := writeConfigFile(t, "bad.yaml", "targets:\n  - url: a.com\n")
+	out.Reset()
+	if code := runValidate([]string{bad}, &out); code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(out.String(), bad+":2: targets[0].url") {
+		t.Errorf("unexpected output: %s", out.String())
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const defaultSignatureHeader = "X-Signature-256"
+
+// webhookConfig holds the settings of a generic webhook notifier.
+type webhookConfig struct {
+	url             string
+	method          string
+	headers         map[string]string
+	contentType     string
+	template        *template.Template // nil renders the default JSON payload
+	secret          string
+	signatureHeader string
+}
+
+type fileWebhook struct {
+	URL             string            `yaml:"url" json:"url"`
+	Method          string            `yaml:"method" json:"method"`
+	Headers         map[string]string `yaml:"headers" json:"headers"`
+	ContentType     string            `yaml:"content_type" json:"content_type"`
+	Template        string            `yaml:"template" json:"template"`
+	TemplateFile    string            `yaml:"template_file" json:"template_file"`
+	Secret          string            `yaml:"secret" json:"secret"`
+	SignatureHeader string            `yaml:"signature_header" json:"signature_header"`
+}
+
+func parseWebhook(f fileWebhook, field string, errs *configErrors) webhookConfig {
+	cfg := webhookConfig{
+		url:             f.URL,
+		method:          strings.ToUpper(f.Method),
+		headers:         f.Headers,
+		contentType:     f.ContentType,
+		secret:          f.Secret,
+		signatureHeader: f.SignatureHeader,
+	}
+	if cfg.method == "" {
+		cfg.method = http.MethodPost
+	}
+	if cfg.contentType == "" {
+		cfg.contentType = "application/json"
+	}
+	if cfg.signatureHeader == "" {
+		cfg.signatureHeader = defaultSignatureHeader
+	}
+
+	text := f.Template
+	if f.TemplateFile != "" {
+		if text != "" {
+			errs.add(field+".template_file", "template and template_file are mutually exclusive")
+		}
+		data, err := os.ReadFile(f.TemplateFile)
+		if err != nil {
+			errs.add(field+".template_file", "%v", err)
+		}
+		text = string(data)
+	}
+	if text != "" {
+		tmpl, err := template.New("webhook").Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			errs.add(field+".template", "%v", err)
+		}
+		cfg.template = tmpl
+	}
+	return cfg
+}
+
+func validateWebhook(cfg webhookConfig, field string, errs *configErrors) {
+	requireURL(errs, field+".url", cfg.url, "webhook")
+	if cfg.method != http.MethodPost && cfg.method != http.MethodPut && cfg.method != http.MethodPatch {
+		errs.add(field+".method", "unsupported method %q (use POST, PUT or PATCH)", cfg.method)
+	}
+}
+
+// templateFuncs are available in notifier templates.
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"rfc3339": func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+	"ms":      func(d time.Duration) string { return fmt.Sprintf("%d ms", d.Milliseconds()) },
+	"upper":   func(v any) string { return strings.ToUpper(fmt.Sprint(v)) },
+}
+
+// webhookPayload is the default webhook body. See the README for the schema.
+type webhookPayload struct {
+	Version         string            `json:"version"`
+	Name            string            `json:"name"`
+	URL             string            `json:"url"`
+	State           AlertState        `json:"state"`
+	Reason          string            `json:"reason"`
+	FailureCount    int               `json:"failure_count"`
+	Since           time.Time         `json:"since"`
+	At              time.Time         `json:"at"`
+	DowntimeSeconds int64             `json:"downtime_seconds"`
+	Reminder        int               `json:"reminder"`
+	Escalated       bool              `json:"escalated"`
+	Parent          string            `json:"parent,omitempty"`
+	AckURL          string            `json:"ack_url,omitempty"`
+	AckedBy         string            `json:"acked_by,omitempty"`
+	AckedAt         time.Time         `json:"acked_at,omitzero"`
+	Level           int               `json:"level,omitempty"`
+	OnCall          *OnCallPerson     `json:"oncall,omitempty"`
+	FlapRate        float64           `json:"flap_rate,omitempty"`
+	FlapEnded       bool              `json:"flap_ended,omitempty"`
+	SlowChecks      int               `json:"slow_checks,omitempty"`
+	Severity        string            `json:"severity,omitempty"`
+	From            AlertState        `json:"from,omitempty"`
+	Labels          map[string]string `json:"labels"`
+	DashboardURL    string            `json:"dashboard_url,omitempty"`
+}
+
+// webhookDigestPayload is the default webhook body of digests. Storm
+// digests only carry the counts of each group.
+type webhookDigestPayload struct {
+	Version string                `json:"version"`
+	Type    string                `json:"type"` // Always "digest"
+	Storm   bool                  `json:"storm"`
+	At      time.Time             `json:"at"`
+	Groups  []webhookGroupPayload `json:"groups"`
+}
+
+type webhookGroupPayload struct {
+	Key    string           `json:"key"`
+	Down   int              `json:"down"`
+	Up     int              `json:"up"`
+	Events []webhookPayload `json:"events,omitempty"`
+}
+
+// webhookNotifier sends events to an arbitrary HTTP endpoint, optionally
+// signing the body with HMAC-SHA256.
+type webhookNotifier struct {
+	cfg    webhookConfig
+	client *http.Client
+}
+
+func newWebhookNotifier(nc notifierConfig) *webhookNotifier {
+	return &webhookNotifier{cfg: nc.webhook, client: &http.Client{Timeout: nc.timeout}}
+}
+
+func (n *webhookNotifier) Notify(ev AlertEvent) error {
+	body, err := n.render(ev)
+	if err != nil {
+		return err
+	}
+	return n.send(body)
+}
+
+// NotifyDigest posts the default digest payload. Webhooks with a custom
+// template get the events one by one instead, see digestNotifier.
+func (n *webhookNotifier) NotifyDigest(d AlertDigest) error {
+	payload := webhookDigestPayload{Version: "1", Type: "digest", Storm: d.Storm, At: d.At.UTC()}
+	for _, g := range d.Groups {
+		gd := AlertDigest{Groups: []AlertGroup{g}}
+		group := webhookGroupPayload{Key: g.Key, Down: gd.Count(StateDown), Up: gd.Count(StateUp)}
+		if !d.Storm {
+			for _, ev := range g.Events {
+				group.Events = append(group.Events, newWebhookPayload(ev))
+			}
+		}
+		payload.Groups = append(payload.Groups, group)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return n.send(body)
+}
+
+// send posts body with the configured headers and signature.
+func (n *webhookNotifier) send(body []byte) error {
+	headers := make(map[string]string, len(n.cfg.headers)+1)
+	for k, v := range n.cfg.headers {
+		headers[k] = v
+	}
+	if n.cfg.secret != "" {
+		headers[n.cfg.signatureHeader] = signBody(n.cfg.secret, body)
+	}
+	return send(n.client, n.cfg.method, n.cfg.url, n.cfg.contentType, headers, body)
+}
+
+// render executes the configured template or encodes the default payload.
+func (n *webhookNotifier) render(ev AlertEvent) ([]byte, error) {
+	if n.cfg.template == nil {
+		return json.Marshal(newWebhookPayload(ev))
+	}
+	var buf bytes.Buffer
+	if err := n.cfg.template.Execute(&buf, ev); err != nil {
+		return nil, fmt.Errorf("rendering webhook template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func newWebhookPayload(ev AlertEvent) webhookPayload {
+	return webhookPayload{
+		Version:         "1",
+		Name:            ev.Name,
+		URL:             ev.URL,
+		State:           ev.State,
+		Reason:          ev.Reason,
+		FailureCount:    ev.FailureCount,
+		Since:           ev.Since.UTC(),
+		At:              ev.At.UTC(),
+		DowntimeSeconds: int64(ev.Downtime().Seconds()),
+		Reminder:        ev.Reminder,
+		Escalated:       ev.Escalated,
+		Parent:          ev.Parent,
+		AckURL:          ev.AckURL,
+		AckedBy:         ev.AckedBy,
+		AckedAt:         ev.AckedAt.UTC(),
+		Level:           ev.Level,
+		OnCall:          ev.OnCall,
+		FlapRate:        ev.FlapRate,
+		FlapEnded:       ev.FlapEnded,
+		SlowChecks:      ev.SlowChecks,
+		Severity:        ev.Severity,
+		From:            ev.From,
+		Labels:          ev.Labels,
+		DashboardURL:    ev.DashboardURL,
+	}
+}
+
+// signBody returns the HMAC-SHA256 signature of body as "sha256=<hex>".
+func signBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifierDefaultPayload(t *testing.T) {
+	hook := newWebhookRecorder(t, http.StatusOK)
+	errs := &configErrors{}
+	cfg := parseWebhook(fileWebhook{URL: hook.URL, Headers: map[string]string{"X-Team": "ops"}}, "webhook", errs)
+	if err := errs.err(); err != nil {
+		t.Fatal(err)
+	}
+	n := newWebhookNotifier(notifierConfig{timeout: time.Second, webhook: cfg})
+
+	if err := n.Notify(testEvent(StateUp)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var payload map[string]any
+	hook.next(t, &payload)
+	if payload["version"] != "1" || payload["name"] != "checkout" || payload["state"] != "up" ||
+		payload["downtime_seconds"] != float64(42*60) || payload["since"] != "2024-06-01T12:00:00Z" {
+		t.Errorf("unexpected payload: %v", payload)
+	}
+	headers := <-hook.headers
+	if headers.Get("X-Team") != "ops" || headers.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected headers: %v", headers)
+	}
+	if headers.Get(defaultSignatureHeader) != "" {
+		t.Errorf("body should not be signed without a secret")
+	}
+}
+
+func TestWebhookNotifierTemplateAndSignature(t *testing.T) {
+	hook := newWebhookRecorder(t, http.StatusOK)
+	errs := &configErrors{}
+	cfg := parseWebhook(fileWebhook{
+		URL:             hook.URL,
+		Method:          "put",
+		Template:        `{"title": {{ printf "%s is %s" .Name (upper (printf "%s" .State)) | json }}, "labels": {{ json .Labels }}, "at": "{{ rfc3339 .At }}"}`,
+		Secret:          "s3cret",
+		SignatureHeader: "X-Hub-Signature-256",
+	}, "webhook", errs)
+	if err := errs.err(); err != nil {
+		t.Fatal(err)
+	}
+	n := newWebhookNotifier(notifierConfig{timeout: time.Second, webhook: cfg})
+
+	if err := n.Notify(testEvent(StateDown)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body := <-hook.bodies
+	headers := <-hook.headers
+	var payload map[string]any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("template did not render valid JSON: %s", body)
+	}
+	if payload["title"] != "checkout is DOWN" || payload["at"] != "2024-06-01T12:42:00Z" {
+		t.Errorf("unexpected payload: %s", body)
+	}
+	if got, want := headers.Get("X-Hub-Signature-256"), signBody("s3cret", body); got != want {
+		t.Errorf("expected signature %s, got %s", want, got)
+	}
+}
+
+func TestSignBody(t *testing.T) {
+	// Reference value computed with: printf 'hello' | openssl dgst -sha256 -hmac key
+	want := "sha256=9307b3b915efb5171ff14d8cb55fbcc798c6c0ef1456d66ded1a6aa723a58b7b"
+	if got := signBody("key", []byte("hello")); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestParseWebhookTemplateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "body.tmpl")
+	if err := os.WriteFile(path, []byte("{{ .Name }}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	errs := &configErrors{}
+	cfg := parseWebhook(fileWebhook{URL: "https://hooks.example.com", TemplateFile: path}, "webhook", errs)
+	if errs.err() != nil || cfg.template == nil {
+		t.Fatalf("template file not loaded: %v", errs.err())
+	}
+	body, err := (&webhookNotifier{cfg: cfg}).render(testEvent(StateDown))
+	if err != nil || string(body) != "checkout" {
+		t.Errorf("unexpected rendering %q: %v", body, err)
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	errs := &configErrors{}
+	cfg := parseWebhook(fileWebhook{Method: "GET", Template: "{{ .Name "}, "webhook", errs)
+	validateWebhook(cfg, "webhook", errs)
+	msg := errs.Error()
+	for _, want := range []string{"webhook.template", "webhook.url: required", "webhook.method: unsupported method \"GET\""} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("expected %q in %s", want, msg)
+		}
+	}
+}